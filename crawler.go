@@ -3,6 +3,7 @@ package wikiracer
 import (
 	"context"
 
+	"github.com/ihcsim/wikiracer/errors"
 	"github.com/ihcsim/wikiracer/internal/wiki"
 )
 
@@ -19,4 +20,24 @@ type Crawler interface {
 
 	// Error returns a channel which can be used to receive any errors encountered by Discover().
 	Error() <-chan error
+
+	// Warnings returns the non-fatal warnings reported by the wiki during the crawl.
+	Warnings() errors.Warnings
+
+	// Skipped returns the pages that the crawler gave up on because of errors.
+	// If it's empty, the crawl has covered all the pages it encountered.
+	Skipped() errors.List
+
+	// Visited returns the number of pages that the crawler has visited.
+	Visited() int
+
+	// Batches returns the number of batches of pages that the crawler has requested from the wiki.
+	Batches() int
+
+	// APICalls returns the number of calls that the crawler has sent to the wiki.
+	APICalls() int
+
+	// Observe registers an observer which receives the progress events of the crawl, like the visited pages and the found paths.
+	// It must be called before Run.
+	Observe(o wiki.Observer)
 }
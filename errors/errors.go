@@ -2,6 +2,7 @@ package errors
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/ihcsim/wikiracer/internal/wiki"
 )
@@ -36,6 +37,59 @@ func (e PageNotFound) Error() string {
 	return fmt.Sprintf("%s: %s", "Page not found", e.Title)
 }
 
+// PagesNotFound is the error used when some of the requested pages in a batch can't be found in the wiki.
+// It's a partial failure. The pages that are found are returned alongside this error.
+type PagesNotFound struct {
+	Titles []string
+}
+
+// Error returns the string representation of the PagesNotFound error.
+func (e PagesNotFound) Error() string {
+	return fmt.Sprintf("%s: %s", "Pages not found", strings.Join(e.Titles, ", "))
+}
+
+// Contains returns true if title is one of the missing pages.
+func (e PagesNotFound) Contains(title string) bool {
+	for _, t := range e.Titles {
+		if t == title {
+			return true
+		}
+	}
+	return false
+}
+
+// BatchSkipped is the error used when the crawler gives up on a batch of pages because the wiki failed to return them.
+type BatchSkipped struct {
+	Titles string
+	Err    error
+}
+
+// Error returns the string representation of the BatchSkipped error.
+func (e BatchSkipped) Error() string {
+	return fmt.Sprintf("%s: (%s): %s", "Batch skipped", e.Titles, e.Err)
+}
+
+// the budgets of a crawl.
+const (
+	BudgetPages    = "pages"
+	BudgetAPICalls = "api_calls"
+)
+
+// BudgetExhausted is the error used when the crawler stops because it has visited the maximum number of pages, or sent the maximum number of API calls, allowed for the crawl.
+type BudgetExhausted struct {
+	// Budget is the budget that ran out. It's one of BudgetPages and BudgetAPICalls.
+	Budget string
+	Limit  int
+
+	PagesVisited int
+	APICalls     int
+}
+
+// Error returns the string representation of the BudgetExhausted error.
+func (e BudgetExhausted) Error() string {
+	return fmt.Sprintf("%s: %d %s (Pages visited: %d, API calls: %d)", "Budget exhausted", e.Limit, strings.Replace(e.Budget, "_", " ", -1), e.PagesVisited, e.APICalls)
+}
+
 // InvalidEmptyInput is the error used when the provided inputs are invalid.
 type InvalidEmptyInput struct {
 	Origin      string
@@ -46,3 +100,108 @@ type InvalidEmptyInput struct {
 func (e InvalidEmptyInput) Error() string {
 	return fmt.Sprintf("%s: (%s, %s)", "The provided inputs must not be empty", e.Origin, e.Destination)
 }
+
+// InvalidHops is the error used when a puzzle is requested with a non-positive hop count.
+type InvalidHops struct {
+	Hops int
+}
+
+// Error returns the string representation of the InvalidHops error.
+func (e InvalidHops) Error() string {
+	return fmt.Sprintf("%s: %d", "The hop count must be greater than zero", e.Hops)
+}
+
+// PuzzleUnavailable is the error used when no destination page can be found at the requested hop distance from the origin.
+type PuzzleUnavailable struct {
+	Origin string
+	Hops   int
+}
+
+// Error returns the string representation of the PuzzleUnavailable error.
+func (e PuzzleUnavailable) Error() string {
+	if e.Origin == "" {
+		return fmt.Sprintf("%s: %d hop(s)", "Puzzle unavailable", e.Hops)
+	}
+	return fmt.Sprintf("%s: %d hop(s) from %s", "Puzzle unavailable", e.Hops, e.Origin)
+}
+
+// Warning is a non-fatal diagnostic message returned by the wiki.
+type Warning struct {
+	// Code identifies the kind of warning.
+	Code string
+
+	// Text is the warning message.
+	Text string
+}
+
+// String returns the string representation of the warning.
+func (w Warning) String() string {
+	if w.Code == "" {
+		return w.Text
+	}
+	return fmt.Sprintf("%s: %s", w.Code, w.Text)
+}
+
+// Warnings is the error used when the wiki returns warnings along with its results.
+// It's non-fatal. The results returned alongside this error are still valid.
+type Warnings []Warning
+
+// Error returns the string representation of the Warnings error.
+func (e Warnings) Error() string {
+	messages := []string{}
+	for _, w := range e {
+		messages = append(messages, w.String())
+	}
+	return fmt.Sprintf("%s: %s", "Warnings", strings.Join(messages, "; "))
+}
+
+// Merge returns the union of e and others. Duplicate warnings are omitted.
+func (e Warnings) Merge(others Warnings) Warnings {
+	merged := append(Warnings{}, e...)
+	for _, other := range others {
+		duplicate := false
+		for _, w := range merged {
+			if w == other {
+				duplicate = true
+				break
+			}
+		}
+
+		if !duplicate {
+			merged = append(merged, other)
+		}
+	}
+	return merged
+}
+
+// List is a collection of errors which occurred in the same operation.
+type List []error
+
+// Error returns the string representation of the List error.
+func (e List) Error() string {
+	messages := []string{}
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Flatten returns the errors contained in err.
+// If err is a List, its members are returned. Otherwise, a slice containing only err is returned.
+// Nil errors are omitted.
+func Flatten(err error) []error {
+	if err == nil {
+		return nil
+	}
+
+	list, ok := err.(List)
+	if !ok {
+		return []error{err}
+	}
+
+	flattened := []error{}
+	for _, e := range list {
+		flattened = append(flattened, Flatten(e)...)
+	}
+	return flattened
+}
@@ -0,0 +1,48 @@
+package crawler
+
+import (
+	"github.com/ihcsim/wikiracer/errors"
+)
+
+// Budget limits the work that the crawler does to find a path.
+// The zero value is unbounded.
+type Budget struct {
+	// MaxPages is the maximum number of pages that the crawler visits. It's unbounded if it's zero.
+	MaxPages int
+
+	// MaxAPICalls is the maximum number of calls that the crawler sends to the wiki. It's unbounded if it's zero.
+	// A streamed batch of pages counts as one call, unless it's served from a cache. A wiki which doesn't stream its pages counts one call per lookup.
+	MaxAPICalls int
+}
+
+// WithBudget limits the number of pages that the crawler visits, and the number of calls that it sends to the wiki.
+// When the budget runs out, the crawl stops with an errors.BudgetExhausted error.
+func WithBudget(b Budget) Option {
+	return func(f *Forward) {
+		f.budget = b
+	}
+}
+
+// exhausted returns an errors.BudgetExhausted error if the crawler has visited all the pages of its budget.
+func (f *Forward) exhausted() error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	return f.overBudget(false)
+}
+
+// overBudget returns an errors.BudgetExhausted error if the crawler can't visit another page, or, if calls is true, send another call to the wiki.
+// The caller must hold the mutex.
+func (f *Forward) overBudget(calls bool) error {
+	err := errors.BudgetExhausted{PagesVisited: f.pages, APICalls: f.apiCalls}
+	switch {
+	case f.budget.MaxPages > 0 && f.pages >= f.budget.MaxPages:
+		err.Budget, err.Limit = errors.BudgetPages, f.budget.MaxPages
+	case calls && f.budget.MaxAPICalls > 0 && f.apiCalls >= f.budget.MaxAPICalls:
+		err.Budget, err.Limit = errors.BudgetAPICalls, f.budget.MaxAPICalls
+	default:
+		return nil
+	}
+
+	return err
+}
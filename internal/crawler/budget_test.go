@@ -0,0 +1,154 @@
+package crawler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ihcsim/wikiracer/errors"
+	"github.com/ihcsim/wikiracer/internal/wiki"
+	"github.com/ihcsim/wikiracer/test"
+)
+
+func TestBudget(t *testing.T) {
+	// Michael Jordan is unreachable, so the crawler covers the entire mock wiki, unless its budget runs out.
+	var testCases = []struct {
+		name        string
+		budget      Budget
+		destination string
+		expected    string
+		exhausted   string
+	}{
+		{name: "Unbounded", destination: "Segment", expected: "Mike Tyson -> Alexander the Great -> Greek language -> Fruit anatomy -> Segment"},
+		{name: "Sufficient", budget: Budget{MaxPages: 100, MaxAPICalls: 100}, destination: "Segment", expected: "Mike Tyson -> Alexander the Great -> Greek language -> Fruit anatomy -> Segment"},
+		{name: "Pages", budget: Budget{MaxPages: 3}, destination: "Michael Jordan", exhausted: errors.BudgetPages},
+		{name: "API Calls", budget: Budget{MaxAPICalls: 2}, destination: "Michael Jordan", exhausted: errors.BudgetAPICalls},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var (
+				crawler         = NewForward(test.NewMockWiki(), WithBudget(testCase.budget))
+				ctx, cancelFunc = context.WithTimeout(context.Background(), time.Second)
+			)
+			defer cancelFunc()
+
+			go crawler.Run(ctx, "Mike Tyson", testCase.destination)
+
+			select {
+			case actual := <-crawler.Path():
+				if actual.String() != testCase.expected {
+					t.Errorf("Mismatch path.\nExpected: %s\nActual: %s", testCase.expected, actual)
+				}
+
+			case err := <-crawler.Error():
+				actual, ok := err.(errors.BudgetExhausted)
+				if !ok {
+					t.Fatalf("Mismatch error. Expected BudgetExhausted. Actual %T: %s", err, err)
+				}
+
+				if actual.Budget != testCase.exhausted {
+					t.Errorf("Mismatch exhausted budget. Expected %q. Actual %q", testCase.exhausted, actual.Budget)
+				}
+
+				if actual.PagesVisited == 0 || actual.APICalls == 0 {
+					t.Errorf("Missing statistics. %s", actual)
+				}
+
+			case <-ctx.Done():
+				t.Fatal("Test timed out")
+			}
+
+			if max := testCase.budget.MaxAPICalls; max > 0 && crawler.APICalls() > max {
+				t.Errorf("Mismatch API calls. Expected at most %d. Actual %d", max, crawler.APICalls())
+			}
+		})
+	}
+}
+
+func TestCachedBudget(t *testing.T) {
+	var (
+		w     = &countingStreamer{StreamingWiki: test.NewMockWiki(test.WithBatchSize(1)).Stream()}
+		cache = wiki.NewCache(100, 0)
+	)
+
+	// Michael Jordan is unreachable, so every crawl covers the entire mock wiki.
+	var testCases = []struct {
+		name string
+		cold bool
+	}{
+		{name: "Cold Cache", cold: true},
+		{name: "Warm Cache"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var (
+				calls           = w.count()
+				crawler         = NewForward(cache.Wrap(w), WithBudget(Budget{MaxAPICalls: 1000}))
+				ctx, cancelFunc = context.WithTimeout(context.Background(), 200*time.Millisecond)
+			)
+			defer cancelFunc()
+
+			go crawler.Run(ctx, "Mike Tyson", "Michael Jordan")
+
+			select {
+			case err := <-crawler.Error():
+				t.Fatalf("Unexpected error: %s", err)
+			case <-ctx.Done():
+			}
+
+			// only the batches which weren't served from the cache are counted, including the continuations.
+			made := w.count() - calls
+			if actual := crawler.APICalls(); actual != made {
+				t.Errorf("Mismatch API calls. Expected %d. Actual %d", made, actual)
+			}
+
+			if testCase.cold && made <= crawler.Batches() {
+				t.Errorf("Expected the continuations to be counted. Calls: %d. Batches: %d", made, crawler.Batches())
+			}
+
+			if !testCase.cold && made != 0 {
+				t.Errorf("Mismatch calls. Expected 0. Actual %d", made)
+			}
+		})
+	}
+}
+
+// countingStreamer counts the batches that it streams. Every batch is a call to the wiki.
+type countingStreamer struct {
+	*test.StreamingWiki
+
+	mux   sync.Mutex
+	calls int
+}
+
+func (w *countingStreamer) StreamPages(ctx context.Context, titles, nextBatch string) <-chan *wiki.Batch {
+	counted := make(chan *wiki.Batch)
+
+	go func() {
+		defer close(counted)
+
+		for batch := range w.StreamingWiki.StreamPages(ctx, titles, nextBatch) {
+			w.mux.Lock()
+			w.calls++
+			w.mux.Unlock()
+
+			select {
+			case counted <- batch:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return counted
+}
+
+func (w *countingStreamer) count() int {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	return w.calls
+}
@@ -0,0 +1,113 @@
+package crawler
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/ihcsim/wikiracer/internal/wiki"
+)
+
+var (
+	months = "(January|February|March|April|May|June|July|August|September|October|November|December)"
+
+	// dates matches the titles of year, decade, century and date pages, like "1984", "44 BC", "1980s", "19th century", "1984 in music" and "July 4".
+	dates = []*regexp.Regexp{
+		regexp.MustCompile(`^(AD )?\d{1,4}( (BC|BCE|AD|CE))?$`),
+		regexp.MustCompile(`^\d{1,4}0s( (BC|BCE))?$`),
+		regexp.MustCompile(`^\d{1,2}(st|nd|rd|th) (century|millennium)( (BC|BCE|AD|CE))?$`),
+		regexp.MustCompile(`^(AD )?\d{1,4}( (BC|BCE))? in .+$`),
+		regexp.MustCompile(`^` + months + ` \d{1,2}$`),
+		regexp.MustCompile(`^\d{1,2} ` + months + `$`),
+		regexp.MustCompile(`^` + months + ` \d{1,4}$`),
+	}
+
+	lists = []string{"List of ", "Lists of "}
+)
+
+// Filter decides whether the crawler can follow a link to a page.
+// It returns false if the page must not be part of a path.
+// Before a linked page is fetched, the filter is called with a page which only has its Title set. Filters which rely on the page's metadata must allow such pages.
+// The origin and destination pages are never filtered.
+type Filter func(page *wiki.Page) bool
+
+// WithFilters sets the filters that the crawler uses to decide which links to follow.
+// A link is followed only if all the filters allow it.
+func WithFilters(filters ...Filter) Option {
+	return func(f *Forward) {
+		f.filters = append(f.filters, filters...)
+	}
+}
+
+// SkipDisambiguation rejects disambiguation pages.
+// Pages are recognized by their metadata, or by the "(disambiguation)" suffix in their titles.
+func SkipDisambiguation(page *wiki.Page) bool {
+	return !page.Disambiguation && !strings.HasSuffix(page.Title, "(disambiguation)")
+}
+
+// SkipDates rejects year, decade, century and date pages.
+func SkipDates(page *wiki.Page) bool {
+	for _, date := range dates {
+		if date.MatchString(page.Title) {
+			return false
+		}
+	}
+	return true
+}
+
+// SkipLists rejects "List of ..." pages.
+func SkipLists(page *wiki.Page) bool {
+	for _, prefix := range lists {
+		if strings.HasPrefix(page.Title, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// Titles returns a filter which only allows pages whose titles match at least one of the allow patterns, and none of the deny patterns.
+// If allow is empty, all titles which don't match the deny patterns are allowed.
+func Titles(allow, deny []*regexp.Regexp) Filter {
+	return func(page *wiki.Page) bool {
+		for _, pattern := range deny {
+			if pattern.MatchString(page.Title) {
+				return false
+			}
+		}
+
+		if len(allow) == 0 {
+			return true
+		}
+
+		for _, pattern := range allow {
+			if pattern.MatchString(page.Title) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Categories returns a filter which rejects pages that belong to any of the blacklisted categories.
+// The categories are specified without their namespace prefix.
+func Categories(blacklist ...string) Filter {
+	return func(page *wiki.Page) bool {
+		for _, category := range page.Categories {
+			for _, blacklisted := range blacklist {
+				if category == blacklisted {
+					return false
+				}
+			}
+		}
+		return true
+	}
+}
+
+// allow returns true if all the filters allow page.
+func (f *Forward) allow(page *wiki.Page) bool {
+	for _, filter := range f.filters {
+		if !filter(page) {
+			return false
+		}
+	}
+	return true
+}
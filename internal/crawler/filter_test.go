@@ -0,0 +1,83 @@
+package crawler
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/ihcsim/wikiracer/internal/wiki"
+	"github.com/ihcsim/wikiracer/test"
+)
+
+func TestFilters(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		filter   Filter
+		page     *wiki.Page
+		expected bool
+	}{
+		{name: "Disambiguation", filter: SkipDisambiguation, page: &wiki.Page{Title: "Segment", Disambiguation: true}, expected: false},
+		{name: "Disambiguation Suffix", filter: SkipDisambiguation, page: &wiki.Page{Title: "Mercury (disambiguation)"}, expected: false},
+		{name: "Not Disambiguation", filter: SkipDisambiguation, page: &wiki.Page{Title: "Vancouver"}, expected: true},
+		{name: "Year", filter: SkipDates, page: &wiki.Page{Title: "1984"}, expected: false},
+		{name: "Year BC", filter: SkipDates, page: &wiki.Page{Title: "323 BC"}, expected: false},
+		{name: "Decade", filter: SkipDates, page: &wiki.Page{Title: "1980s"}, expected: false},
+		{name: "Century", filter: SkipDates, page: &wiki.Page{Title: "4th century BC"}, expected: false},
+		{name: "Year In Topic", filter: SkipDates, page: &wiki.Page{Title: "1984 in music"}, expected: false},
+		{name: "Date", filter: SkipDates, page: &wiki.Page{Title: "July 4"}, expected: false},
+		{name: "Month And Year", filter: SkipDates, page: &wiki.Page{Title: "March 1966"}, expected: false},
+		{name: "Not Date", filter: SkipDates, page: &wiki.Page{Title: "1984 Summer Olympics"}, expected: true},
+		{name: "List", filter: SkipLists, page: &wiki.Page{Title: "List of boxing champions"}, expected: false},
+		{name: "Lists", filter: SkipLists, page: &wiki.Page{Title: "Lists of Olympic medalists"}, expected: false},
+		{name: "Not List", filter: SkipLists, page: &wiki.Page{Title: "Listeria"}, expected: true},
+		{name: "Denied Title", filter: Titles(nil, []*regexp.Regexp{regexp.MustCompile(`^Greek`)}), page: &wiki.Page{Title: "Greek language"}, expected: false},
+		{name: "Allowed Title", filter: Titles([]*regexp.Regexp{regexp.MustCompile(`Olympics$`)}, nil), page: &wiki.Page{Title: "1984 Summer Olympics"}, expected: true},
+		{name: "Not Allowed Title", filter: Titles([]*regexp.Regexp{regexp.MustCompile(`Olympics$`)}, nil), page: &wiki.Page{Title: "Tea"}, expected: false},
+		{name: "Blacklisted Category", filter: Categories("Convenience stores"), page: &wiki.Page{Title: "7-Eleven", Categories: []string{"Convenience stores"}}, expected: false},
+		{name: "Unknown Categories", filter: Categories("Convenience stores"), page: &wiki.Page{Title: "7-Eleven"}, expected: true},
+	}
+
+	for _, testCase := range testCases {
+		if actual := testCase.filter(testCase.page); actual != testCase.expected {
+			t.Errorf("Test case %q failed. Expected %t. Actual %t", testCase.name, testCase.expected, actual)
+		}
+	}
+}
+
+func TestDiscoverFiltered(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		filters     []Filter
+		origin      string
+		destination string
+		expected    string
+	}{
+		{name: "Disambiguation", filters: []Filter{SkipDisambiguation}, origin: "Mike Tyson", destination: "Vancouver", expected: "Mike Tyson -> 1984 Summer Olympics -> 7-Eleven -> Big C -> Vancouver"},
+		{name: "Categories", filters: []Filter{Categories("Convenience stores")}, origin: "Mike Tyson", destination: "Vancouver", expected: "Mike Tyson -> Alexander the Great -> Greek language -> Fruit anatomy -> Segment -> Vancouver"},
+		{name: "Titles", filters: []Filter{Titles(nil, []*regexp.Regexp{regexp.MustCompile(`^Greek`)})}, origin: "Mike Tyson", destination: "Segment"},
+		{name: "Origin", filters: []Filter{Categories("American male boxers")}, origin: "Mike Tyson", destination: "Apepi", expected: "Mike Tyson -> Alexander the Great -> Apepi"},
+	}
+
+	for _, testCase := range testCases {
+		var (
+			crawler         = NewForward(test.NewMockWiki(), WithFilters(testCase.filters...))
+			ctx, cancelFunc = context.WithTimeout(context.Background(), timeout/10)
+		)
+		defer cancelFunc()
+
+		go crawler.Run(ctx, testCase.origin, testCase.destination)
+
+		select {
+		case actual := <-crawler.Path():
+			if actual.String() != testCase.expected {
+				t.Errorf("Test case %q failed.\nExpected: %q\nActual: %q", testCase.name, testCase.expected, actual)
+			}
+		case err := <-crawler.Error():
+			t.Errorf("Test case %q failed. Unexpected error: %s", testCase.name, err)
+		case <-ctx.Done():
+			if testCase.expected != "" {
+				t.Errorf("Test case %q timed out", testCase.name)
+			}
+		}
+	}
+}
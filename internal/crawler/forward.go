@@ -2,9 +2,11 @@ package crawler
 
 import (
 	"context"
+	"strings"
 	"sync"
 
 	"github.com/ihcsim/wikiracer/errors"
+	"github.com/ihcsim/wikiracer/internal/metrics"
 	"github.com/ihcsim/wikiracer/internal/wiki"
 	"github.com/ihcsim/wikiracer/log"
 )
@@ -14,21 +16,63 @@ const (
 	wikipediaMaxTitlesCount = 50
 )
 
+var crawlGoroutines = metrics.NewGauge("wikiracer_crawl_goroutines", "Number of running crawl goroutines.")
+
 // Forward is a crawler that attempts to find a path from an origin page to a destination page using an uni-directional traversal pattern.
 type Forward struct {
 	wiki.Wiki
 	path   chan *wiki.Path
 	errors chan error
 	v      sync.Map
+
+	tolerance Tolerance
+	filters   []Filter
+	observer  wiki.Observer
+
+	// workers limits the number of concurrent requests to the wiki. It's nil if the requests are unbounded.
+	workers chan struct{}
+
+	budget Budget
+
+	mux      sync.Mutex
+	warnings errors.Warnings
+	skipped  errors.List
+	batches  int
+	pages    int
+	apiCalls int
+
+	// pending is the number of batches of titles which are waiting to be crawled, or are being crawled.
+	pending int
+
+	// aborted is set once a skipped batch has failed the crawl.
+	aborted bool
 }
 
 // NewForward returns an new instance of the Forward crawler.
-func NewForward(w wiki.Wiki) *Forward {
-	return &Forward{
+func NewForward(w wiki.Wiki, options ...Option) *Forward {
+	f := &Forward{
 		Wiki:   w,
 		path:   make(chan *wiki.Path),
 		errors: make(chan error),
 		v:      sync.Map{},
+		mux:    sync.Mutex{},
+	}
+
+	for _, option := range options {
+		option(f)
+	}
+
+	return f
+}
+
+// WithWorkers limits the number of concurrent requests that the crawler sends to the wiki.
+// If n isn't positive, the number of requests is unbounded.
+func WithWorkers(n int) Option {
+	return func(f *Forward) {
+		f.workers = nil
+		if n > 0 {
+			f.workers = make(chan struct{}, n)
+		}
 	}
 }
 
@@ -37,7 +81,11 @@ func NewForward(w wiki.Wiki) *Forward {
 // All errors encountered can be retrieved using the Error() method.
 // ctx can be used to impose timeout on Run.
 func (f *Forward) Run(ctx context.Context, origin, destination string) {
-	go f.discover(ctx, origin, destination, nil)
+	f.schedule(1)
+	spawn(func() {
+		f.discover(ctx, origin, destination, nil)
+		f.crawled(ctx)
+	})
 }
 
 // Path returns a channel which receives the path result from the children goroutines.
@@ -50,6 +98,14 @@ func (f *Forward) Error() <-chan error {
 	return f.errors
 }
 
+// Warnings returns the non-fatal warnings that the wiki reported during the crawl.
+func (f *Forward) Warnings() errors.Warnings {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	return append(errors.Warnings{}, f.warnings...)
+}
+
 // discover crawls from origin to destination using all the links found in the pages.
 // For every page P that it encounters:
 // 1. `P` is appended to the sequence of pages in the _intermediate_ path.
@@ -57,85 +113,310 @@ func (f *Forward) Error() <-chan error {
 // 3. if `P` is the destination page, the _intermediate_ path is returned.
 // 4. if `P` isn't the destination page and has no links, the goroutine terminates.
 // 5. otherwise, for every link of `P`, the goroutine creates a new goroutine to crawl that linked page.
+// If the wiki supports streaming, the links of `P` are crawled batch by batch, as they are received.
 func (f *Forward) discover(ctx context.Context, titles, destination string, ancestors *wiki.Path) {
 	if ctx.Err() != nil {
 		log.Instance().Debugf("Canceling crawl operation. Reason=%q", ctx.Err().Error())
 		return
 	}
 
-	pages, err := f.FindPages(titles, "")
-	if err != nil {
-		if pageErr, ok := err.(errors.PageNotFound); ok {
-			if pageErr.Title != destination {
-				return
+	// the paths of the pages encountered in the previous batches of a stream.
+	paths := map[string]*wiki.Path{}
+
+	// exhausted is set if the page budget runs out while the pages are processed.
+	var exhausted error
+
+	err := f.find(ctx, titles, destination, func(pages []*wiki.Page) bool {
+		for _, page := range pages {
+			clonedAncestors, found := paths[page.Title]
+			if !found {
+				clonedAncestors = wiki.NewPath()
+				if ancestors != nil {
+					clonedAncestors.Clone(ancestors)
+				}
+				clonedAncestors.AddPage(page)
+
+				// skip this page if is previously visited
+				if f.visited(page.Title) {
+					log.Instance().Debugf("Loop detected. Title=%q Predecessors=%q", page.Title, clonedAncestors)
+					continue
+				}
+				f.addVisited(page.Title)
+				f.visit(page, clonedAncestors)
+				log.Instance().Debugf("Found page. Title=%q Predecessors=%q", page.Title, clonedAncestors)
+
+				// found destination
+				if page.Title == destination {
+					log.Instance().Infof("Found destination. Title=%q Predecessors=%q", page.Title, clonedAncestors)
+					f.found(ctx, clonedAncestors)
+					return false
+				}
+
+				if exhausted = f.exhausted(); exhausted != nil {
+					return false
+				}
+
+				// the links of a filtered page aren't crawled. The origin page is never filtered.
+				if ancestors != nil && !f.allow(page) {
+					log.Instance().Debugf("Filtered page. Title=%q Predecessors=%q", page.Title, clonedAncestors)
+					clonedAncestors = nil
+				}
+				paths[page.Title] = clonedAncestors
+			}
+
+			if clonedAncestors == nil {
+				continue
+			}
+
+			if !f.expand(ctx, page, destination, clonedAncestors) {
+				return false
 			}
 		}
 
-		log.Instance().Errorf("%s", err)
-		f.errors <- err
-		return
+		return true
+	})
+
+	if err == nil {
+		err = exhausted
 	}
 
-	for _, page := range pages {
-		clonedAncestors := wiki.NewPath()
-		if ancestors != nil {
-			clonedAncestors.Clone(ancestors)
+	if err != nil {
+		if ctx.Err() != nil {
+			return
 		}
-		clonedAncestors.AddPage(page)
 
-		// skip this page if is previously visited
-		if f.visited(page.Title) {
-			log.Instance().Debugf("Loop detected. Title=%q Predecessors=%q", page.Title, clonedAncestors)
-			continue
+		// a missing destination and an exhausted budget are definitive answers, while other failures are subjected to the tolerance policy.
+		switch err.(type) {
+		case errors.PageNotFound, errors.BudgetExhausted:
+		default:
+			err = f.skip(titles, err)
 		}
-		f.addVisited(page.Title)
-		log.Instance().Debugf("Found page. Title=%q Predecessors=%q", page.Title, clonedAncestors)
 
-		// found destination
-		if page.Title == destination {
-			log.Instance().Infof("Found destination. Title=%q Predecessors=%q", page.Title, clonedAncestors)
-			f.path <- clonedAncestors
-			return
+		if err != nil {
+			log.Instance().Errorf("%s", err)
+			select {
+			case f.errors <- err:
+			case <-ctx.Done():
+			}
 		}
+	}
+}
 
-		// this page is a dead end and the racer can't reach the destination from this path.
-		if len(page.Links) == 0 {
-			log.Instance().Debugf("Dead end page. Title=%q Predecessors=%q", page.Title, clonedAncestors)
+// expand creates a new goroutine to crawl the links of page.
+// It returns false if one of the links is the destination.
+func (f *Forward) expand(ctx context.Context, page *wiki.Page, destination string, clonedAncestors *wiki.Path) bool {
+	// this page is a dead end and the racer can't reach the destination from this path.
+	if len(page.Links) == 0 {
+		log.Instance().Debugf("Dead end page. Title=%q Predecessors=%q", page.Title, clonedAncestors)
+		return true
+	}
+
+	titles := []string{}
+	for _, link := range page.Links {
+		// if one of the linked pages is the destination and context is still alive,
+		// returns the destination
+		// clonedAncestors is shared with the goroutines of the previous batches of page, so the path to the destination is a copy of it.
+		if link == destination && ctx.Err() == nil {
+			path := wiki.NewPath()
+			path.Clone(clonedAncestors)
+			path.AddPage(&wiki.Page{Title: link})
+
+			f.addVisited(link)
+			f.visit(&wiki.Page{Title: link}, path)
+			log.Instance().Infof("Found destination. Title=%q Predecessors=%q", link, path)
+			f.found(ctx, path)
+			return false
+		}
+
+		if !f.allow(&wiki.Page{Title: link}) {
+			log.Instance().Debugf("Filtered link. Title=%q Predecessors=%q", link, clonedAncestors)
 			continue
 		}
 
-		// Since the Wikipedia API only supports 50 titles in one query,
-		// we have to break up the query into multiple calls.
-		batchCount := len(page.Links) / wikipediaMaxTitlesCount
-		links := make([]string, batchCount+1)
-		for index, link := range page.Links {
-			// if one of the linked pages is the destination and context is still alive,
-			// returns the destination
-			if link == destination && ctx.Err() == nil {
-				f.addVisited(link)
-				clonedAncestors.AddPage(&wiki.Page{Title: link})
-				log.Instance().Infof("Found destination. Title=%q Predecessors=%q", link, clonedAncestors)
-				f.path <- clonedAncestors
-				return
+		titles = append(titles, link)
+	}
+
+	// Since the Wikipedia API only supports 50 titles in one query,
+	// we have to break up the query into multiple calls.
+	f.schedule((len(titles) + wikipediaMaxTitlesCount - 1) / wikipediaMaxTitlesCount)
+	spawn(func() {
+		for start := 0; start < len(titles); start += wikipediaMaxTitlesCount {
+			end := start + wikipediaMaxTitlesCount
+			if end > len(titles) {
+				end = len(titles)
 			}
 
-			links[index/wikipediaMaxTitlesCount] += separator + link
+			links := strings.Join(titles[start:end], separator)
+			log.Instance().Debugf("Starting crawl operation. Titles=%q", links)
+			f.discover(ctx, links, destination, clonedAncestors)
+			f.crawled(ctx)
 		}
+	})
 
-		go func() {
-			log.Instance().Debugf("Starting crawl operation. Titles=%q", links)
-			for _, link := range links {
-				if link == "" {
-					continue
-				}
-				f.discover(ctx, link[1:], destination, clonedAncestors)
+	return true
+}
+
+// triage separates the fatal errors returned by the wiki from the non-fatal ones.
+// Red links are common in Wikipedia. The missing pages are skipped, while the crawl continues with the found pages. They only matter if one of them is the destination.
+// Warnings are recorded, and can be retrieved using the Warnings() method.
+// The first fatal error is returned. Otherwise, nil is returned.
+func (f *Forward) triage(err error, destination string) error {
+	for _, e := range errors.Flatten(err) {
+		switch cast := e.(type) {
+		case errors.PagesNotFound:
+			if cast.Contains(destination) {
+				return errors.PageNotFound{Page: wiki.Page{Title: destination}}
 			}
-		}()
+			log.Instance().Debugf("Skipping missing pages. Titles=%q", cast.Titles)
+
+		case errors.Warnings:
+			log.Instance().Debugf("%s", cast)
+			f.mux.Lock()
+			f.warnings = f.warnings.Merge(cast)
+			f.mux.Unlock()
+
+		default:
+			return e
+		}
+	}
+
+	return nil
+}
+
+// Visited returns the number of pages that the crawler has visited.
+func (f *Forward) Visited() int {
+	var count int
+	f.v.Range(func(key, value interface{}) bool {
+		count++
+		return true
+	})
+	return count
+}
+
+// Batches returns the number of batches of pages that the crawler has requested from the wiki.
+func (f *Forward) Batches() int {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	return f.batches
+}
+
+// APICalls returns the number of calls that the crawler has sent to the wiki.
+func (f *Forward) APICalls() int {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	return f.apiCalls
+}
+
+// Observe registers an observer which receives the progress events of the crawl.
+// It must be called before Run.
+func (f *Forward) Observe(o wiki.Observer) {
+	f.observer = o
+}
+
+// visit emits an EventVisit event for page, which is the last page of path.
+func (f *Forward) visit(page *wiki.Page, path *wiki.Path) {
+	if f.observer != nil {
+		f.observer(wiki.Event{Type: wiki.EventVisit, Page: page, Depth: path.Len() - 1})
+	}
+}
+
+// found emits an EventPath event for path, and sends path to the Path() channel.
+// The path is dropped if ctx is canceled before it's received, so that the goroutine doesn't outlive the race.
+func (f *Forward) found(ctx context.Context, path *wiki.Path) {
+	if f.observer != nil {
+		f.observer(wiki.Event{Type: wiki.EventPath, Path: path.Pages(), Depth: path.Len() - 1})
+	}
+
+	select {
+	case f.path <- path:
+	case <-ctx.Done():
+	}
+}
+
+// request counts a call to the wiki, and emits an EventRequest event.
+// It returns an errors.BudgetExhausted error, without counting the call, if the budget of the crawler doesn't allow it.
+func (f *Forward) request() error {
+	if err := f.reserve(); err != nil {
+		return err
+	}
+
+	f.settle(false)
+	return nil
+}
+
+// reserve counts a call to the wiki, or returns an errors.BudgetExhausted error if the budget doesn't allow another call.
+// The reserved call must be settled.
+func (f *Forward) reserve() error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	if err := f.overBudget(true); err != nil {
+		return err
+	}
+	f.apiCalls++
+	return nil
+}
+
+// settle reports a reserved call to the observer. If cached is true, the pages were served from a cache, and the call is given back to the budget instead.
+func (f *Forward) settle(cached bool) {
+	if cached {
+		f.mux.Lock()
+		f.apiCalls--
+		f.mux.Unlock()
+		return
+	}
+
+	if f.observer != nil {
+		f.observer(wiki.Event{Type: wiki.EventRequest})
+	}
+}
+
+// schedule adds n to the number of pending batches.
+func (f *Forward) schedule(n int) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	f.pending += n
+}
+
+// spawn runs fn in a new goroutine, which is counted by the crawlGoroutines gauge.
+func spawn(fn func()) {
+	crawlGoroutines.Inc()
+	go func() {
+		defer crawlGoroutines.Dec()
+		fn()
+	}()
+}
+
+// acquire blocks until a worker is available, or ctx is canceled. It returns false if ctx is canceled.
+func (f *Forward) acquire(ctx context.Context) bool {
+	if f.workers == nil {
+		return true
+	}
+
+	select {
+	case f.workers <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// release frees the worker held by the caller.
+func (f *Forward) release() {
+	if f.workers != nil {
+		<-f.workers
 	}
 }
 
 func (f *Forward) addVisited(title string) {
-	f.v.Store(title, struct{}{})
+	if _, loaded := f.v.LoadOrStore(title, struct{}{}); !loaded {
+		f.mux.Lock()
+		f.pages++
+		f.mux.Unlock()
+	}
 }
 
 func (f *Forward) visited(title string) bool {
@@ -2,18 +2,27 @@ package crawler
 
 import (
 	"context"
+	"os"
+	"reflect"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/ihcsim/wikiracer/errors"
+	"github.com/ihcsim/wikiracer/internal/wiki"
 	"github.com/ihcsim/wikiracer/log"
 	"github.com/ihcsim/wikiracer/test"
 )
 
 const timeout = 5 * time.Second
 
-func TestDiscover(t *testing.T) {
+// TestMain silences the logs once, since the crawl goroutines of a test can still log after the test has returned.
+func TestMain(m *testing.M) {
 	log.Instance().SetBackend(log.QuietBackend)
+	os.Exit(m.Run())
+}
 
+func TestDiscover(t *testing.T) {
 	t.Run("Visited Pages", func(t *testing.T) {
 		// these test cases only verify pages from origin to destination are marked as visited.
 		// other goroutines might have been created for to discover other paths and aren't included in these tests.
@@ -63,3 +72,310 @@ func TestDiscover(t *testing.T) {
 		}
 	})
 }
+
+func TestObserve(t *testing.T) {
+	var (
+		mux    sync.Mutex
+		events = map[string][]wiki.Event{}
+
+		crawler         = NewForward(test.NewMockWiki())
+		ctx, cancelFunc = context.WithTimeout(context.Background(), timeout)
+	)
+	defer cancelFunc()
+
+	crawler.Observe(func(e wiki.Event) {
+		mux.Lock()
+		defer mux.Unlock()
+		events[e.Type] = append(events[e.Type], e)
+	})
+
+	go crawler.Run(ctx, "Mike Tyson", "Apepi")
+
+	select {
+	case <-crawler.Path():
+	case <-ctx.Done():
+		t.Fatal("Timed out")
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+
+	if len(events[wiki.EventRequest]) == 0 {
+		t.Error("Expected request events to be emitted")
+	}
+
+	visits := events[wiki.EventVisit]
+	if len(visits) == 0 || visits[0].Page.Title != "Mike Tyson" || visits[0].Depth != 0 {
+		t.Errorf("Mismatch visit events. Got %+v", visits)
+	}
+
+	paths := events[wiki.EventPath]
+	if len(paths) == 0 {
+		t.Fatal("Expected path events to be emitted")
+	}
+
+	var titles []string
+	for _, page := range paths[0].Path {
+		titles = append(titles, page.Title)
+	}
+
+	if expected := []string{"Mike Tyson", "Alexander the Great", "Apepi"}; !reflect.DeepEqual(expected, titles) || paths[0].Depth != 2 {
+		t.Errorf("Mismatch path event.\nExpected: %q\nActual: %q, Depth: %d", expected, titles, paths[0].Depth)
+	}
+}
+
+func TestWorkers(t *testing.T) {
+	var (
+		w               = &concurrentWiki{MockWiki: test.NewMockWiki()}
+		crawler         = NewForward(w, WithWorkers(2))
+		ctx, cancelFunc = context.WithTimeout(context.Background(), timeout)
+	)
+	defer cancelFunc()
+
+	go crawler.Run(ctx, "Mike Tyson", "Segment")
+
+	select {
+	case <-crawler.Path():
+	case err := <-crawler.Error():
+		t.Fatalf("Unexpected error: %s", err)
+	case <-ctx.Done():
+		t.Fatal("Timed out")
+	}
+
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if w.max > 2 {
+		t.Errorf("Expected at most 2 concurrent requests. Actual %d", w.max)
+	}
+}
+
+// concurrentWiki records the maximum number of concurrent requests.
+type concurrentWiki struct {
+	*test.MockWiki
+	mux         sync.Mutex
+	active, max int
+}
+
+func (w *concurrentWiki) FindPages(titles, nextBatch string) ([]*wiki.Page, error) {
+	w.mux.Lock()
+	w.active++
+	if w.active > w.max {
+		w.max = w.active
+	}
+	w.mux.Unlock()
+
+	defer func() {
+		w.mux.Lock()
+		w.active--
+		w.mux.Unlock()
+	}()
+
+	time.Sleep(time.Millisecond)
+	return w.MockWiki.FindPages(titles, nextBatch)
+}
+
+func TestDiscoverMissingPages(t *testing.T) {
+	t.Run("Red Links", func(t *testing.T) {
+		var testCases = []struct {
+			origin      string
+			destination string
+			expected    string
+		}{
+			{origin: "Mike Tyson", destination: "Apepi", expected: "Mike Tyson -> Alexander the Great -> Apepi"},
+			{origin: "Mike Tyson", destination: "Segment", expected: "Mike Tyson -> Alexander the Great -> Greek language -> Fruit anatomy -> Segment"},
+			{origin: "Mike Tyson", destination: "Tea", expected: "Mike Tyson -> 1984 Summer Olympics -> 7-Eleven -> Eurocash -> Tea"},
+		}
+
+		for id, testCase := range testCases {
+			var (
+				crawler         = NewForward(&redLinkWiki{test.NewMockWiki()})
+				ctx, cancelFunc = context.WithTimeout(context.Background(), timeout)
+			)
+			defer cancelFunc()
+
+			go crawler.Run(ctx, testCase.origin, testCase.destination)
+
+			select {
+			case actual := <-crawler.Path():
+				if actual.String() != testCase.expected {
+					t.Errorf("Mismatch path. Test case: %d\nExpected: %s\nActual: %s", id, testCase.expected, actual)
+				}
+			case err := <-crawler.Error():
+				t.Errorf("Test case %d failed. Unexpected error: %s", id, err)
+			case <-ctx.Done():
+				t.Errorf("Test case %d timed out", id)
+			}
+		}
+	})
+
+	t.Run("Missing Destination", func(t *testing.T) {
+		var (
+			crawler         = NewForward(test.NewMockWiki())
+			destination     = "Aaron Pryor"
+			ctx, cancelFunc = context.WithTimeout(context.Background(), timeout)
+		)
+		defer cancelFunc()
+
+		go crawler.Run(ctx, "Mike Tyson|"+destination, destination)
+
+		expected := errors.PageNotFound{Page: wiki.Page{Title: destination}}
+		select {
+		case actual := <-crawler.Error():
+			if actual.Error() != expected.Error() {
+				t.Errorf("Mismatch error.\nExpected: %s\nActual: %s", expected, actual)
+			}
+		case <-crawler.Path():
+			t.Error("Expected error didn't occur")
+		case <-ctx.Done():
+			t.Error("Test timed out")
+		}
+	})
+}
+
+// redLinkWiki adds a missing page to every batch of titles.
+type redLinkWiki struct {
+	*test.MockWiki
+}
+
+func (w *redLinkWiki) FindPages(titles, nextBatch string) ([]*wiki.Page, error) {
+	return w.MockWiki.FindPages(titles+"|Red link", nextBatch)
+}
+
+func TestWarnings(t *testing.T) {
+	var (
+		crawler         = NewForward(&warningWiki{test.NewMockWiki()})
+		ctx, cancelFunc = context.WithTimeout(context.Background(), timeout)
+	)
+	defer cancelFunc()
+
+	go crawler.Run(ctx, "Mike Tyson", "Greek language")
+
+	select {
+	case <-crawler.Path():
+	case err := <-crawler.Error():
+		t.Fatal("Unexpected error: ", err)
+	case <-ctx.Done():
+		t.Fatal("Test timed out")
+	}
+
+	expected := errors.Warnings{deprecation}
+	if actual := crawler.Warnings(); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Mismatch warnings.\nExpected: %v\nActual: %v", expected, actual)
+	}
+}
+
+var deprecation = errors.Warning{Code: "deprecation", Text: "This parameter has been deprecated."}
+
+// warningWiki adds a deprecation warning to every response.
+type warningWiki struct {
+	*test.MockWiki
+}
+
+func (w *warningWiki) FindPages(titles, nextBatch string) ([]*wiki.Page, error) {
+	pages, err := w.MockWiki.FindPages(titles, nextBatch)
+	if err != nil {
+		return nil, err
+	}
+	return pages, errors.Warnings{deprecation}
+}
+
+func TestDiscoverStream(t *testing.T) {
+	// the path found by a concurrent crawl depends on the scheduling of its goroutines, so only its links are verified.
+	var testCases = []struct {
+		name        string
+		wiki        wiki.Wiki
+		origin      string
+		destination string
+	}{
+		{name: "Apepi", wiki: &streamingWiki{test.NewMockWiki()}, origin: "Mike Tyson", destination: "Apepi"},
+		{name: "Segment", wiki: &streamingWiki{test.NewMockWiki()}, origin: "Mike Tyson", destination: "Segment"},
+		{name: "Tea", wiki: &streamingWiki{test.NewMockWiki()}, origin: "Mike Tyson", destination: "Tea"},
+		{name: "Paginated", wiki: test.NewMockWiki(test.WithBatchSize(1), test.WithLatency(50*time.Microsecond)).Stream(), origin: "Mike Tyson", destination: "1984 Summer Olympics"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var (
+				crawler         = NewForward(testCase.wiki)
+				ctx, cancelFunc = context.WithTimeout(context.Background(), timeout)
+			)
+			defer cancelFunc()
+
+			go crawler.Run(ctx, testCase.origin, testCase.destination)
+
+			select {
+			case actual := <-crawler.Path():
+				verifyPath(t, test.NewMockWiki(), actual, testCase.origin, testCase.destination)
+			case err := <-crawler.Error():
+				t.Errorf("Unexpected error: %s", err)
+			case <-ctx.Done():
+				t.Error("Test timed out")
+			}
+		})
+	}
+}
+
+// verifyPath verifies that path goes from origin to destination, and that every hop of path is a link of w.
+func verifyPath(t *testing.T, w wiki.Wiki, path *wiki.Path, origin, destination string) {
+	pages := path.Pages()
+	if len(pages) < 2 || pages[0].Title != origin || pages[len(pages)-1].Title != destination {
+		t.Fatalf("Mismatch path. Expected a path from %q to %q. Actual %s", origin, destination, path)
+	}
+
+	for i := 0; i < len(pages)-1; i++ {
+		found, err := w.FindPages(pages[i].Title, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var linked bool
+		for _, page := range found {
+			for _, link := range page.Links {
+				linked = linked || link == pages[i+1].Title
+			}
+		}
+
+		if !linked {
+			t.Errorf("Mismatch path %s. %q doesn't link to %q", path, pages[i].Title, pages[i+1].Title)
+		}
+	}
+}
+
+// streamingWiki streams the links of every page, one link per batch.
+type streamingWiki struct {
+	*test.MockWiki
+}
+
+func (w *streamingWiki) StreamPages(ctx context.Context, titles, nextBatch string) <-chan *wiki.Batch {
+	batches := make(chan *wiki.Batch)
+
+	go func() {
+		defer close(batches)
+
+		pages, err := w.FindPages(titles, nextBatch)
+		for _, page := range pages {
+			links := page.Links
+			if len(links) == 0 {
+				links = []string{""}
+			}
+
+			for _, link := range links {
+				batch := &wiki.Batch{
+					Pages: []*wiki.Page{&wiki.Page{ID: page.ID, Title: page.Title}},
+					Err:   err,
+				}
+				if link != "" {
+					batch.Pages[0].Links = []string{link}
+				}
+
+				select {
+				case batches <- batch:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return batches
+}
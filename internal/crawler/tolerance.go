@@ -0,0 +1,246 @@
+package crawler
+
+import (
+	"context"
+	"time"
+
+	"github.com/ihcsim/wikiracer/errors"
+	"github.com/ihcsim/wikiracer/internal/wiki"
+	"github.com/ihcsim/wikiracer/log"
+)
+
+// DefaultTolerance is a tolerance policy which rides out transient failures of the wiki.
+var DefaultTolerance = Tolerance{
+	Retries:         2,
+	Backoff:         500 * time.Millisecond,
+	MaxFailures:     20,
+	MaxFailureRatio: 0.2,
+	MinBatches:      10,
+}
+
+// Tolerance is the policy that the crawler uses to handle the errors returned by the wiki.
+// A failed batch of titles is retried up to Retries times. If it still fails, the batch is skipped, and the crawl continues with the other batches.
+// The crawl is aborted when either the number of skipped batches exceeds MaxFailures, or the ratio of skipped batches to all the crawled batches exceeds MaxFailureRatio.
+// Errors which report themselves as fatal, like a replayed fixture which doesn't match the requests of the crawl, abort the crawl without being skipped.
+// When no batch is left to crawl after a batch is skipped, like when the batch of the origin is skipped, the crawl fails with the error of the last skipped batch.
+// The ratio is only enforced once MinBatches batches have been crawled, so that a failure early in the crawl doesn't abort it.
+// The zero value aborts the crawl on the first failure.
+type Tolerance struct {
+	// Retries is the number of times a failed batch is retried before it's skipped.
+	// Errors which aren't temporary aren't retried.
+	Retries int
+
+	// Backoff is the duration to wait before the first retry. It's doubled on every subsequent retry.
+	Backoff time.Duration
+
+	// MaxFailures is the maximum number of batches that can be skipped.
+	MaxFailures int
+
+	// MaxFailureRatio is the maximum ratio of skipped batches to all the crawled batches.
+	// The ratio isn't enforced if it's zero.
+	MaxFailureRatio float64
+
+	// MinBatches is the number of crawled batches below which MaxFailureRatio isn't enforced.
+	MinBatches int
+}
+
+// Option can be used to configure the Forward crawler.
+type Option func(*Forward)
+
+// WithTolerance sets the policy that the crawler uses to handle the errors returned by the wiki.
+func WithTolerance(t Tolerance) Option {
+	return func(f *Forward) {
+		f.tolerance = t
+	}
+}
+
+// Skipped returns the batches that were skipped because of errors.
+// If it's empty, the crawl has covered all the pages it encountered.
+func (f *Forward) Skipped() errors.List {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	return append(errors.List{}, f.skipped...)
+}
+
+// find passes the pages of titles to process, retrying the temporary failures according to the tolerance policy.
+// If the wiki supports streaming, process is called once for every batch of pages, as they are received. Otherwise, it's called once with all the pages.
+// process returns false to stop the stream.
+func (f *Forward) find(ctx context.Context, titles, destination string, process func([]*wiki.Page) bool) error {
+	f.mux.Lock()
+	f.batches++
+	f.mux.Unlock()
+
+	streamer, ok := f.Wiki.(wiki.Streamer)
+	if !ok {
+		return f.retry(ctx, func() error {
+			if !f.acquire(ctx) {
+				return ctx.Err()
+			}
+
+			if err := f.request(); err != nil {
+				f.release()
+				return err
+			}
+			pages, err := f.FindPages(titles, "")
+			f.release()
+
+			if err := f.triage(err, destination); err != nil {
+				return err
+			}
+
+			process(pages)
+			return nil
+		})
+	}
+
+	// a retried stream resumes after the last batch that was processed.
+	var nextBatch string
+	return f.retry(ctx, func() error {
+		if !f.acquire(ctx) {
+			return ctx.Err()
+		}
+		defer f.release()
+
+		// every batch of the stream is a call to the wiki, unless the first batch is served from a cache.
+		if err := f.reserve(); err != nil {
+			return err
+		}
+
+		streamCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		settled := false
+		defer func() {
+			if !settled {
+				f.settle(false)
+			}
+		}()
+
+		for batch := range streamer.StreamPages(streamCtx, titles, nextBatch) {
+			if !settled {
+				f.settle(batch.Cached)
+				settled = true
+			}
+
+			if err := f.triage(batch.Err, destination); err != nil {
+				return err
+			}
+			nextBatch = batch.Next
+
+			if !process(batch.Pages) {
+				break
+			}
+
+			if batch.Next != "" {
+				if err := f.request(); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// retry calls attempt until it succeeds, or until the number of retries specified by the tolerance policy is exhausted.
+func (f *Forward) retry(ctx context.Context, attempt func() error) error {
+	var (
+		err     error
+		backoff = f.tolerance.Backoff
+	)
+	for i := 0; i <= f.tolerance.Retries; i++ {
+		if i > 0 {
+			log.Instance().Debugf("Retrying batch. Attempt=%d Reason=%q", i, err)
+			select {
+			case <-time.After(backoff):
+				backoff *= 2
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		if err = attempt(); err == nil || !retryable(err) {
+			break
+		}
+	}
+
+	return err
+}
+
+// skip records titles as a skipped batch.
+// If err is fatal, or if the tolerance policy's thresholds are exceeded, err is returned, signaling that the crawl must be aborted. Otherwise, nil is returned.
+func (f *Forward) skip(titles string, err error) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	f.skipped = append(f.skipped, errors.BatchSkipped{Titles: titles, Err: err})
+
+	var (
+		failures = len(f.skipped)
+		ratio    = float64(failures) / float64(f.batches)
+	)
+	if fatal(err) || failures > f.tolerance.MaxFailures || (f.tolerance.MaxFailureRatio > 0 && f.batches >= f.tolerance.MinBatches && ratio > f.tolerance.MaxFailureRatio) {
+		log.Instance().Errorf("Aborting crawl operation. Failures=%d Batches=%d", failures, f.batches)
+		f.aborted = true
+		return err
+	}
+
+	log.Instance().Warningf("Skipping batch. Titles=%q Reason=%q", titles, err)
+	return nil
+}
+
+// crawled marks a pending batch as crawled.
+// If it was the last pending batch, and batches were skipped, the error of the last skipped batch is sent to the error channel, since the skipped batches might have led to the destination.
+func (f *Forward) crawled(ctx context.Context) {
+	f.mux.Lock()
+	f.pending--
+
+	var err error
+	if f.pending == 0 && len(f.skipped) > 0 && !f.aborted {
+		err = f.skipped[len(f.skipped)-1].(errors.BatchSkipped).Err
+		f.aborted = true
+	}
+	f.mux.Unlock()
+
+	if err == nil || ctx.Err() != nil {
+		return
+	}
+
+	log.Instance().Errorf("Aborting crawl operation. No batches left after the skipped batches. Reason=%q", err)
+	select {
+	case f.errors <- err:
+	case <-ctx.Done():
+	}
+}
+
+// retryable returns false if err is a definitive answer, or if it reports itself as not temporary.
+func retryable(err error) bool {
+	switch err.(type) {
+	case errors.PageNotFound, errors.BudgetExhausted:
+		return false
+	}
+
+	if fatal(err) {
+		return false
+	}
+
+	if t, ok := err.(interface {
+		Temporary() bool
+	}); ok {
+		return t.Temporary()
+	}
+
+	return true
+}
+
+// fatal returns true if err reports itself as fatal.
+func fatal(err error) bool {
+	if f, ok := err.(interface {
+		Fatal() bool
+	}); ok {
+		return f.Fatal()
+	}
+
+	return false
+}
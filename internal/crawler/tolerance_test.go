@@ -0,0 +1,205 @@
+package crawler
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ihcsim/wikiracer/internal/wiki"
+	"github.com/ihcsim/wikiracer/internal/wiki/wikipedia"
+	"github.com/ihcsim/wikiracer/test"
+	"github.com/ihcsim/wikiracer/test/apiserver"
+)
+
+func TestTolerance(t *testing.T) {
+	// when the destination is unreachable, the crawler covers the entire mock wiki, and fails with the error of the skipped batch.
+	var testCases = []struct {
+		name        string
+		tolerance   Tolerance
+		title       string
+		failures    int
+		temporary   bool
+		destination string
+		expected    string
+		aborted     bool
+		skipped     int
+		calls       int
+	}{
+		{name: "Retry", tolerance: Tolerance{Retries: 2}, failures: 2, temporary: true, destination: "Segment", expected: "Mike Tyson -> Alexander the Great -> Greek language -> Fruit anatomy -> Segment", calls: 3},
+		{name: "Skip", tolerance: Tolerance{Retries: 1, MaxFailures: 1}, failures: -1, temporary: true, destination: "Michael Jordan", aborted: true, skipped: 1, calls: 2},
+		{name: "Skip Non-Temporary", tolerance: Tolerance{Retries: 2, MaxFailures: 1}, failures: -1, temporary: false, destination: "Michael Jordan", aborted: true, skipped: 1, calls: 1},
+		{name: "Abort", tolerance: Tolerance{}, failures: -1, temporary: true, destination: "Segment", aborted: true, skipped: 1, calls: 1},
+		{name: "Abort Ratio", tolerance: Tolerance{MaxFailures: 10, MaxFailureRatio: 0.1}, failures: -1, temporary: true, destination: "Segment", aborted: true, skipped: 1, calls: 1},
+		{name: "Early Failure", tolerance: Tolerance{MaxFailures: 10, MaxFailureRatio: 0.1, MinBatches: 100}, failures: -1, temporary: true, destination: "Michael Jordan", aborted: true, skipped: 1, calls: 1},
+		{name: "Origin Failure", tolerance: DefaultTolerance, title: "Mike Tyson", failures: -1, temporary: false, destination: "Michael Jordan", aborted: true, skipped: 1, calls: 1},
+		{name: "Last Pending Failure", tolerance: DefaultTolerance, title: "Alexander the Great", failures: -1, temporary: false, destination: "Michael Jordan", aborted: true, skipped: 1, calls: 1},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			title := testCase.title
+			if title == "" {
+				title = "Greek language"
+			}
+
+			var (
+				flaky = &flakyWiki{
+					MockWiki:  test.NewMockWiki(),
+					title:     title,
+					failures:  testCase.failures,
+					temporary: testCase.temporary,
+				}
+				crawler         = NewForward(flaky, WithTolerance(testCase.tolerance))
+				ctx, cancelFunc = context.WithTimeout(context.Background(), 200*time.Millisecond)
+			)
+			defer cancelFunc()
+
+			go crawler.Run(ctx, "Mike Tyson", testCase.destination)
+
+			select {
+			case actual := <-crawler.Path():
+				if actual.String() != testCase.expected {
+					t.Errorf("Mismatch path.\nExpected: %s\nActual: %s", testCase.expected, actual)
+				}
+
+			case err := <-crawler.Error():
+				if !testCase.aborted {
+					t.Errorf("Unexpected error: %s", err)
+				}
+
+				if _, ok := err.(*flakyError); !ok {
+					t.Errorf("Mismatch error. Got %T", err)
+				}
+
+			case <-ctx.Done():
+				if testCase.expected != "" || testCase.aborted {
+					t.Fatal("Test timed out")
+				}
+			}
+
+			if actual := len(crawler.Skipped()); actual != testCase.skipped {
+				t.Errorf("Mismatch skipped batches count. Expected %d. Actual %d", testCase.skipped, actual)
+			}
+
+			if actual := flaky.count(); actual != testCase.calls {
+				t.Errorf("Mismatch calls count. Expected %d. Actual %d", testCase.calls, actual)
+			}
+		})
+	}
+}
+
+func TestFixtureMismatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fixture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the fixture records the race to Apepi, which doesn't crawl the links of Alexander the Great.
+	var (
+		path = filepath.Join(dir, "race.json")
+		stub = httptest.NewServer(apiserver.New(test.NewMockWiki()))
+	)
+	recorder, err := wikipedia.NewClient(wikipedia.WithEndpoint(stub.URL), wikipedia.WithFixture(wikipedia.RecordFixture(path)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := recorder.FindPages("Mike Tyson", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := recorder.FindPages("Alexander the Great|1984 Summer Olympics", ""); err != nil {
+		t.Fatal(err)
+	}
+	stub.Close()
+
+	fixture, err := wikipedia.ReplayFixture(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replayer, err := wikipedia.NewClient(wikipedia.WithEndpoint(stub.URL), wikipedia.WithFixture(fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		crawler         = NewForward(replayer, WithTolerance(DefaultTolerance))
+		ctx, cancelFunc = context.WithTimeout(context.Background(), timeout)
+	)
+	defer cancelFunc()
+
+	go crawler.Run(ctx, "Mike Tyson", "Segment")
+
+	select {
+	case actual := <-crawler.Path():
+		t.Errorf("Expected the race to fail. Actual path %s", actual)
+
+	case err := <-crawler.Error():
+		if _, ok := err.(*wikipedia.UnexpectedRequest); !ok {
+			t.Errorf("Mismatch error. Expected UnexpectedRequest. Actual %T: %s", err, err)
+		}
+
+	case <-ctx.Done():
+		t.Fatal("Test timed out")
+	}
+
+	// the mismatch aborts the crawl, even if the tolerance policy allows the batch to be skipped.
+	tolerant := NewForward(test.NewMockWiki(), WithTolerance(Tolerance{MaxFailures: 10}))
+	tolerant.batches = 10
+	if err := tolerant.skip("Vancouver", &wikipedia.UnexpectedRequest{Fixture: path}); err == nil {
+		t.Error("Expected the mismatch to abort the crawl")
+	}
+}
+
+// flakyWiki fails the batches containing title for the first few calls.
+// If failures is negative, the batches always fail.
+type flakyWiki struct {
+	*test.MockWiki
+	title     string
+	failures  int
+	temporary bool
+
+	mux   sync.Mutex
+	calls int
+}
+
+func (w *flakyWiki) FindPages(titles, nextBatch string) ([]*wiki.Page, error) {
+	if !strings.Contains(titles, w.title) {
+		return w.MockWiki.FindPages(titles, nextBatch)
+	}
+
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	w.calls++
+	if w.failures < 0 || w.calls <= w.failures {
+		return nil, &flakyError{temporary: w.temporary}
+	}
+	return w.MockWiki.FindPages(titles, nextBatch)
+}
+
+func (w *flakyWiki) count() int {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	return w.calls
+}
+
+type flakyError struct {
+	temporary bool
+}
+
+func (e *flakyError) Error() string {
+	return "Service unavailable"
+}
+
+func (e *flakyError) Temporary() bool {
+	return e.temporary
+}
@@ -0,0 +1,352 @@
+// Package metrics provides counters, gauges and histograms which are exposed in the Prometheus text exposition format.
+// It's a small subset of the Prometheus client library. The metrics created with the package-level functions are registered to the Default registry.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// contentType is the content type of the Prometheus text exposition format.
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// labelSeparator separates the label values of a series in the keys of the series maps. It can't be part of a valid UTF-8 string.
+const labelSeparator = "\xff"
+
+// DefaultBuckets are the default upper bounds of the buckets of a histogram, suitable for durations in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 180}
+
+// escaper escapes the label values of the text format.
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Default is the registry of the metrics created with the package-level functions.
+var Default = NewRegistry()
+
+// Registry is a collection of metrics. It's an http.Handler which serves the metrics in the Prometheus text exposition format.
+type Registry struct {
+	mux     sync.Mutex
+	metrics []metric
+}
+
+// metric is a metric family, which has a series for every combination of label values.
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(m metric) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.metrics = append(r.metrics, m)
+}
+
+// Write writes all the metrics of the registry to w, in the order they were created.
+func (r *Registry) Write(w io.Writer) error {
+	r.mux.Lock()
+	metrics := append([]metric{}, r.metrics...)
+	r.mux.Unlock()
+
+	buf := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(buf)
+	}
+	return buf.Flush()
+}
+
+// ServeHTTP writes the metrics of the registry to the response.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+	r.Write(w)
+}
+
+// desc describes a metric family.
+type desc struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+func (d *desc) header(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, strings.Replace(d.help, "\n", " ", -1), d.name, d.kind)
+}
+
+// key returns the key of the series with the given label values. It panics if the number of values doesn't match the number of labels.
+func (d *desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s has %d labels, but got %d values", d.name, len(d.labels), len(values)))
+	}
+	return strings.Join(values, labelSeparator)
+}
+
+// series returns the labels of the series with the given key, in the text format. extra is appended to the labels.
+func (d *desc) series(key string, extra ...string) string {
+	var pairs []string
+	if len(d.labels) > 0 {
+		for i, value := range strings.Split(key, labelSeparator) {
+			pairs = append(pairs, fmt.Sprintf(`%s="%s"`, d.labels[i], escaper.Replace(value)))
+		}
+	}
+
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extra[i], escaper.Replace(extra[i+1])))
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Counter is a cumulative metric which can only increase.
+type Counter struct {
+	desc
+	mux    sync.Mutex
+	values map[string]float64
+}
+
+// NewCounter returns a new counter registered to the Default registry.
+func NewCounter(name, help string, labels ...string) *Counter {
+	return Default.NewCounter(name, help, labels...)
+}
+
+// NewCounter returns a new counter registered to r. The counter has a series for every combination of the values of the labels.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{desc: desc{name: name, help: help, kind: "counter", labels: labels}, values: map[string]float64{}}
+	if len(labels) == 0 {
+		c.values[""] = 0
+	}
+
+	r.register(c)
+	return c
+}
+
+// Inc increments the series with the given label values by one.
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds v to the series with the given label values. It panics if v is negative.
+func (c *Counter) Add(v float64, values ...string) {
+	if v < 0 {
+		panic(fmt.Sprintf("metrics: counter %s can't decrease", c.name))
+	}
+
+	key := c.key(values)
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.values[key] += v
+}
+
+// Value returns the value of the series with the given label values.
+func (c *Counter) Value(values ...string) float64 {
+	key := c.key(values)
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return c.values[key]
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.header(w)
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.series(key), format(c.values[key]))
+	}
+}
+
+// Gauge is a metric which can go up and down.
+type Gauge struct {
+	desc
+	mux    sync.Mutex
+	values map[string]float64
+}
+
+// NewGauge returns a new gauge registered to the Default registry.
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return Default.NewGauge(name, help, labels...)
+}
+
+// NewGauge returns a new gauge registered to r. The gauge has a series for every combination of the values of the labels.
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{desc: desc{name: name, help: help, kind: "gauge", labels: labels}, values: map[string]float64{}}
+	if len(labels) == 0 {
+		g.values[""] = 0
+	}
+
+	r.register(g)
+	return g
+}
+
+// Inc increments the series with the given label values by one.
+func (g *Gauge) Inc(values ...string) {
+	g.Add(1, values...)
+}
+
+// Dec decrements the series with the given label values by one.
+func (g *Gauge) Dec(values ...string) {
+	g.Add(-1, values...)
+}
+
+// Add adds v to the series with the given label values.
+func (g *Gauge) Add(v float64, values ...string) {
+	key := g.key(values)
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	g.values[key] += v
+}
+
+// Set sets the series with the given label values to v.
+func (g *Gauge) Set(v float64, values ...string) {
+	key := g.key(values)
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	g.values[key] = v
+}
+
+// Value returns the value of the series with the given label values.
+func (g *Gauge) Value(values ...string) float64 {
+	key := g.key(values)
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	return g.values[key]
+}
+
+func (g *Gauge) write(w *bufio.Writer) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	g.header(w)
+	for _, key := range sortedKeys(g.values) {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, g.series(key), format(g.values[key]))
+	}
+}
+
+// Histogram samples observations, and counts them in buckets.
+type Histogram struct {
+	desc
+	buckets []float64
+
+	mux    sync.Mutex
+	series map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram returns a new histogram registered to the Default registry.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return Default.NewHistogram(name, help, buckets, labels...)
+}
+
+// NewHistogram returns a new histogram registered to r. buckets are the upper bounds of the buckets, in increasing order. The +Inf bucket is implicit.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{
+		desc:    desc{name: name, help: help, kind: "histogram", labels: labels},
+		buckets: append([]float64{}, buckets...),
+		series:  map[string]*histogramSeries{},
+	}
+	sort.Float64s(h.buckets)
+
+	if len(labels) == 0 {
+		h.series[""] = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+	}
+
+	r.register(h)
+	return h
+}
+
+// Observe adds v to the series with the given label values.
+func (h *Histogram) Observe(v float64, values ...string) {
+	key := h.key(values)
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+
+	for i, bound := range h.buckets {
+		if v <= bound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+// Count returns the number of observations of the series with the given label values.
+func (h *Histogram) Count(values ...string) uint64 {
+	key := h.key(values)
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	if s, ok := h.series[key]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	h.header(w)
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := h.series[key]
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.desc.series(key, "le", format(bound)), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.desc.series(key, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.desc.series(key), format(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.desc.series(key), s.count)
+	}
+}
+
+func sortedKeys(values map[string]float64) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func format(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	registry := NewRegistry()
+	counter := registry.NewCounter("races_total", "Number of races.", "error")
+	gauge := registry.NewGauge("goroutines", "Number of goroutines.")
+	histogram := registry.NewHistogram("hops", "Number of hops.", []float64{1, 2})
+
+	counter.Inc("PageNotFound")
+	counter.Add(2, `quoted "error"`)
+	gauge.Inc()
+	gauge.Inc()
+	gauge.Dec()
+	histogram.Observe(1)
+	histogram.Observe(3)
+
+	expected := `# HELP races_total Number of races.
+# TYPE races_total counter
+races_total{error="PageNotFound"} 1
+races_total{error="quoted \"error\""} 2
+# HELP goroutines Number of goroutines.
+# TYPE goroutines gauge
+goroutines 1
+# HELP hops Number of hops.
+# TYPE hops histogram
+hops_bucket{le="1"} 1
+hops_bucket{le="2"} 1
+hops_bucket{le="+Inf"} 2
+hops_sum 4
+hops_count 2
+`
+
+	var actual bytes.Buffer
+	if err := registry.Write(&actual); err != nil {
+		t.Fatal(err)
+	}
+
+	if actual.String() != expected {
+		t.Errorf("Mismatch metrics.\nExpected:\n%s\nActual:\n%s", expected, actual.String())
+	}
+
+	if actual := counter.Value("PageNotFound"); actual != 1 {
+		t.Errorf("Mismatch counter value. Expected 1. Actual %v", actual)
+	}
+
+	if actual := histogram.Count(); actual != 2 {
+		t.Errorf("Mismatch histogram count. Expected 2. Actual %d", actual)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewCounter("races_total", "Number of races.").Inc()
+
+	res := httptest.NewRecorder()
+	registry.ServeHTTP(res, httptest.NewRequest("GET", "/metrics", nil))
+
+	if actual := res.Header().Get("Content-Type"); actual != contentType {
+		t.Errorf("Mismatch content type. Expected %q. Actual %q", contentType, actual)
+	}
+
+	if expected := "races_total 1\n"; !bytes.HasSuffix(res.Body.Bytes(), []byte(expected)) {
+		t.Errorf("Mismatch response body. Expected suffix %q. Actual %q", expected, res.Body.String())
+	}
+}
+
+func TestCounterPanics(t *testing.T) {
+	var testCases = []struct {
+		name string
+		f    func(c *Counter)
+	}{
+		{name: "Negative", f: func(c *Counter) { c.Add(-1, "PageNotFound") }},
+		{name: "Missing Label", f: func(c *Counter) { c.Inc() }},
+	}
+
+	for _, testCase := range testCases {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Test case %q failed. Expected panic", testCase.name)
+				}
+			}()
+
+			testCase.f(NewRegistry().NewCounter("races_total", "Number of races.", "error"))
+		}()
+	}
+}
@@ -0,0 +1,202 @@
+package puzzle
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/ihcsim/wikiracer/errors"
+	"github.com/ihcsim/wikiracer/internal/wiki"
+	"github.com/ihcsim/wikiracer/log"
+)
+
+const (
+	separator               = "|"
+	wikipediaMaxTitlesCount = 50
+	defaultAttempts         = 5
+)
+
+// Puzzle is a pair of origin and destination pages where the shortest path from the origin to the destination is exactly Hops links long.
+type Puzzle struct {
+	// Origin is the title of the starting page.
+	Origin string
+
+	// Destination is the title of the target page.
+	Destination string
+
+	// Hops is the number of links on the shortest path from the origin to the destination.
+	Hops int
+}
+
+// String returns a string representation of a puzzle.
+func (p Puzzle) String() string {
+	return fmt.Sprintf("Origin: %q, Destination: %q, Hops: %d", p.Origin, p.Destination, p.Hops)
+}
+
+// Generator produces puzzles with a controlled difficulty.
+// The difficulty of a puzzle is measured by the number of hops between its origin and destination.
+type Generator struct {
+	wiki.Wiki
+	wiki.Randomizer
+
+	attempts int
+	rand     *rand.Rand
+}
+
+// NewGenerator returns a new instance of Generator.
+// w is used to expand the links of the pages. r is used to draw the random origins.
+func NewGenerator(w wiki.Wiki, r wiki.Randomizer) *Generator {
+	return &Generator{
+		Wiki:       w,
+		Randomizer: r,
+		attempts:   defaultAttempts,
+		rand:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Seed re-seeds the generator's source of randomness, so that the choice of destination pages becomes reproducible.
+func (g *Generator) Seed(seed int64) {
+	g.rand = rand.New(rand.NewSource(seed))
+}
+
+// Generate returns a puzzle whose destination is exactly hops links away from a randomly selected origin.
+// If the randomly selected origin doesn't have any pages at the requested distance, a new origin is drawn.
+// A PuzzleUnavailable error is returned if no puzzle can be generated after a few attempts.
+// Use ctx to impose timeout on Generate.
+func (g *Generator) Generate(ctx context.Context, hops int) (*Puzzle, error) {
+	for i := 0; i < g.attempts; i++ {
+		pages, err := g.RandomPages(1)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(pages) == 0 {
+			break
+		}
+
+		puzzle, err := g.GenerateFrom(ctx, pages[0].Title, hops)
+		if err == nil {
+			return puzzle, nil
+		}
+
+		if _, ok := err.(errors.PuzzleUnavailable); !ok {
+			return nil, err
+		}
+		log.Instance().Debugf("Retrying puzzle generation. Reason=%q", err)
+	}
+
+	return nil, errors.PuzzleUnavailable{Hops: hops}
+}
+
+// GenerateFrom returns a puzzle whose destination is exactly hops links away from origin.
+// The pages are expanded in a breadth-first manner, so that every page is discovered at its shortest distance from origin.
+// A PuzzleUnavailable error is returned if no pages can be found at the requested distance.
+// Use ctx to impose timeout on GenerateFrom.
+func (g *Generator) GenerateFrom(ctx context.Context, origin string, hops int) (*Puzzle, error) {
+	if hops < 1 {
+		return nil, errors.InvalidHops{Hops: hops}
+	}
+
+	var (
+		depths   = map[string]int{origin: 0}
+		frontier = []string{origin}
+	)
+	for depth := 1; depth <= hops; depth++ {
+		next := []string{}
+		for _, titles := range batch(frontier) {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+
+			// the missing pages are skipped, while the found pages are expanded.
+			pages, err := g.FindPages(titles, "")
+			if _, err := fatal(err); err != nil {
+				return nil, err
+			}
+
+			for _, page := range pages {
+				if _, exist := depths[page.Title]; !exist {
+					depths[page.Title] = depth - 1
+				}
+
+				for _, link := range page.Links {
+					if _, exist := depths[link]; exist {
+						continue
+					}
+					depths[link] = depth
+					next = append(next, link)
+				}
+			}
+		}
+
+		if len(next) == 0 {
+			return nil, errors.PuzzleUnavailable{Origin: origin, Hops: hops}
+		}
+		frontier = next
+	}
+
+	// the linked pages might be missing or redirected to pages that are closer to the origin.
+	// so the candidates are resolved one by one until one is found at the exact distance.
+	for _, index := range g.rand.Perm(len(frontier)) {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		pages, err := g.FindPages(frontier[index], "")
+		missing, err := fatal(err)
+		if err != nil {
+			return nil, err
+		}
+
+		if missing {
+			continue
+		}
+
+		if len(pages) == 0 {
+			continue
+		}
+
+		destination := pages[0].Title
+		if depth, exist := depths[destination]; exist && depth < hops {
+			continue
+		}
+
+		return &Puzzle{Origin: origin, Destination: destination, Hops: hops}, nil
+	}
+
+	return nil, errors.PuzzleUnavailable{Origin: origin, Hops: hops}
+}
+
+// fatal separates the fatal errors returned by the wiki from the non-fatal ones.
+// It reports whether some of the requested pages are missing, and returns the first fatal error, if any.
+func fatal(err error) (bool, error) {
+	missing := false
+	for _, e := range errors.Flatten(err) {
+		switch e.(type) {
+		case errors.PagesNotFound:
+			missing = true
+		case errors.Warnings:
+			log.Instance().Debugf("%s", e)
+		default:
+			return missing, e
+		}
+	}
+
+	return missing, nil
+}
+
+// batch groups titles into '|'-delimited batches of up to 50 titles, which is the maximum number of titles the Wikipedia API supports in one query.
+func batch(titles []string) []string {
+	batches := []string{}
+	for start := 0; start < len(titles); start += wikipediaMaxTitlesCount {
+		end := start + wikipediaMaxTitlesCount
+		if end > len(titles) {
+			end = len(titles)
+		}
+		batches = append(batches, strings.Join(titles[start:end], separator))
+	}
+
+	return batches
+}
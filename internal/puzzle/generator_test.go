@@ -0,0 +1,127 @@
+package puzzle
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ihcsim/wikiracer/errors"
+	"github.com/ihcsim/wikiracer/internal/wiki"
+	"github.com/ihcsim/wikiracer/log"
+	"github.com/ihcsim/wikiracer/test"
+)
+
+const timeout = 5 * time.Second
+
+func TestGenerateFrom(t *testing.T) {
+	log.Instance().SetBackend(log.QuietBackend)
+
+	t.Run("Exact Distance", func(t *testing.T) {
+		var testCases = []struct {
+			origin   string
+			hops     int
+			expected []string
+		}{
+			{origin: "Mike Tyson", hops: 1, expected: []string{"Alexander the Great", "1984 Summer Olympics"}},
+			{origin: "Mike Tyson", hops: 2, expected: []string{"Apepi", "Greek language", "Diodotus I", "7-Eleven", "Afghanistan"}},
+			{origin: "Mike Tyson", hops: 3, expected: []string{"Fruit anatomy", "Big C", "Calgary", "Eurocash"}},
+			{origin: "Mike Tyson", hops: 4, expected: []string{"Segment", "Vancouver", "Małpka Express", "Tea"}},
+			{origin: "Mike Tyson", hops: 5, expected: []string{"2010 Winter Olympics"}},
+		}
+
+		generator := NewGenerator(test.NewMockWiki(), test.NewMockWiki())
+		for id, testCase := range testCases {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			actual, err := generator.GenerateFrom(ctx, testCase.origin, testCase.hops)
+			if err != nil {
+				t.Fatalf("Test case %d failed. Unexpected error: %s", id, err)
+			}
+
+			passed := false
+			for _, option := range testCase.expected {
+				if option == actual.Destination {
+					passed = true
+					break
+				}
+			}
+
+			if !passed {
+				t.Errorf("Mismatch destination. Test case: %d\nExpected either one of: %v\nActual: %s", id, testCase.expected, actual.Destination)
+			}
+		}
+	})
+
+	t.Run("Unavailable", func(t *testing.T) {
+		var (
+			generator = NewGenerator(test.NewMockWiki(), test.NewMockWiki())
+			expected  = errors.PuzzleUnavailable{Origin: "Mike Tyson", Hops: 6}
+		)
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		if _, actual := generator.GenerateFrom(ctx, "Mike Tyson", 6); actual != expected {
+			t.Errorf("Mismatch error.\nExpected: %s\nActual: %v", expected, actual)
+		}
+	})
+
+	t.Run("Invalid Hops", func(t *testing.T) {
+		var (
+			generator = NewGenerator(test.NewMockWiki(), test.NewMockWiki())
+			expected  = errors.InvalidHops{Hops: 0}
+		)
+
+		if _, actual := generator.GenerateFrom(context.Background(), "Mike Tyson", 0); actual != expected {
+			t.Errorf("Mismatch error.\nExpected: %s\nActual: %v", expected, actual)
+		}
+	})
+}
+
+func TestGenerate(t *testing.T) {
+	log.Instance().SetBackend(log.QuietBackend)
+
+	// every page of the cycle has exactly one page at every distance from 1 to 2, so a puzzle always exists, whichever origin is drawn.
+	var (
+		mockWiki = test.FromPages([]*wiki.Page{
+			&wiki.Page{ID: 1, Title: "Boxing", Links: []string{"Mike Tyson"}},
+			&wiki.Page{ID: 2, Title: "Mike Tyson", Links: []string{"Tea"}},
+			&wiki.Page{ID: 3, Title: "Tea", Links: []string{"Boxing"}},
+		})
+		next = map[string]string{"Boxing": "Mike Tyson", "Mike Tyson": "Tea", "Tea": "Boxing"}
+	)
+
+	var testCases = []struct {
+		hops int
+	}{
+		{hops: 1},
+		{hops: 2},
+	}
+
+	for id, testCase := range testCases {
+		generator := NewGenerator(mockWiki, mockWiki)
+		generator.Seed(int64(id))
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		actual, err := generator.Generate(ctx, testCase.hops)
+		if err != nil {
+			t.Fatalf("Test case %d failed. Unexpected error: %s", id, err)
+		}
+
+		expected := actual.Origin
+		for i := 0; i < testCase.hops; i++ {
+			expected = next[expected]
+		}
+
+		if actual.Destination != expected {
+			t.Errorf("Test case %d failed. Mismatch destination from %q.\nExpected: %s\nActual: %s", id, actual.Origin, expected, actual.Destination)
+		}
+
+		if actual.Hops != testCase.hops {
+			t.Errorf("Test case %d failed. Mismatch hops. Expected %d. Actual %d", id, testCase.hops, actual.Hops)
+		}
+	}
+}
@@ -22,14 +22,31 @@ func (v *InputValidator) Validate(origin, destination string) error {
 		return errors.InvalidEmptyInput{Origin: origin, Destination: destination}
 	}
 
-	if _, err := v.FindPages(origin, ""); err != nil {
+	if err := v.exist(origin); err != nil {
 		return err
 	}
 
-	if _, err := v.FindPages(destination, ""); err != nil {
+	if err := v.exist(destination); err != nil {
 		return err
 	}
 
 	return nil
 
 }
+
+// exist returns a PageNotFound error if the page with the given title doesn't exist.
+// Warnings reported by the wiki are ignored.
+func (v *InputValidator) exist(title string) error {
+	_, err := v.FindPages(title, "")
+	for _, e := range errors.Flatten(err) {
+		switch e.(type) {
+		case errors.PagesNotFound:
+			return errors.PageNotFound{Page: wiki.Page{Title: title}}
+		case errors.Warnings:
+		default:
+			return e
+		}
+	}
+
+	return nil
+}
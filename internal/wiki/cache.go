@@ -0,0 +1,288 @@
+package wiki
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ihcsim/wikiracer/internal/metrics"
+)
+
+const titleSeparator = "|"
+
+var (
+	cacheHits   = metrics.NewCounter("wikiracer_cache_hits_total", "Number of pages served by the cache.")
+	cacheMisses = metrics.NewCounter("wikiracer_cache_misses_total", "Number of pages missing from the cache, including the expired pages.")
+)
+
+// Cache is a LRU cache of pages which can be shared by the wikis of many races.
+// A cached page expires after the TTL of the cache, so that the edits to the page are eventually picked up.
+type Cache struct {
+	mux   sync.Mutex
+	pages map[string]*list.Element
+	lru   *list.List
+	size  int
+	ttl   time.Duration
+}
+
+type cacheEntry struct {
+	Page    *Page     `json:"page"`
+	Expires time.Time `json:"expires"`
+}
+
+// NewCache returns a new cache which holds up to size pages. If ttl is zero, the pages don't expire.
+func NewCache(size int, ttl time.Duration) *Cache {
+	return &Cache{
+		pages: map[string]*list.Element{},
+		lru:   list.New(),
+		size:  size,
+		ttl:   ttl,
+	}
+}
+
+// Wrap returns a wiki which serves the cached pages, and only fetches the missing pages from w.
+// The returned wiki is also a Streamer if w is one, and a Randomizer if w is one. Random pages aren't cached.
+func (c *Cache) Wrap(w Wiki) Wiki {
+	cached := &cachedWiki{Wiki: w, cache: c}
+
+	r, randomizer := w.(Randomizer)
+	s, streamer := w.(Streamer)
+	switch {
+	case streamer && randomizer:
+		return &cachedStreamingRandomizer{cachedStreamer: &cachedStreamer{cachedWiki: cached, streamer: s}, Randomizer: r}
+	case streamer:
+		return &cachedStreamer{cachedWiki: cached, streamer: s}
+	case randomizer:
+		return &cachedRandomizer{cachedWiki: cached, Randomizer: r}
+	}
+	return cached
+}
+
+// Len returns the number of pages in the cache.
+func (c *Cache) Len() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return c.lru.Len()
+}
+
+func (c *Cache) get(title string) (*Page, bool) {
+	page, ok := c.lookup(title)
+	if ok {
+		cacheHits.Inc()
+	} else {
+		cacheMisses.Inc()
+	}
+	return page, ok
+}
+
+func (c *Cache) lookup(title string) (*Page, bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	element, ok := c.pages[title]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*cacheEntry)
+	if c.ttl > 0 && time.Now().After(entry.Expires) {
+		c.remove(element)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(element)
+	return entry.Page, true
+}
+
+func (c *Cache) add(page *Page) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.put(&cacheEntry{Page: page, Expires: time.Now().Add(c.ttl)})
+}
+
+// put adds entry as the most recently used page. The caller must hold the mutex.
+func (c *Cache) put(entry *cacheEntry) {
+	if element, ok := c.pages[entry.Page.Title]; ok {
+		element.Value = entry
+		c.lru.MoveToFront(element)
+		return
+	}
+
+	c.pages[entry.Page.Title] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *Cache) remove(element *list.Element) {
+	c.lru.Remove(element)
+	delete(c.pages, element.Value.(*cacheEntry).Page.Title)
+}
+
+// MarshalJSON returns the JSON representation of the unexpired pages of the cache, from the least recently used to the most recently used.
+// It can be used to persist the cache.
+func (c *Cache) MarshalJSON() ([]byte, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	entries := []*cacheEntry{}
+	for element := c.lru.Back(); element != nil; element = element.Prev() {
+		entry := element.Value.(*cacheEntry)
+		if c.ttl > 0 && time.Now().After(entry.Expires) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	return json.Marshal(entries)
+}
+
+// UnmarshalJSON adds the pages of a cache persisted with MarshalJSON. The expired pages are skipped.
+// The pages keep their expiry times, and the size of the cache is enforced.
+func (c *Cache) UnmarshalJSON(data []byte) error {
+	var entries []*cacheEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	for _, entry := range entries {
+		if entry.Page == nil || (c.ttl > 0 && time.Now().After(entry.Expires)) {
+			continue
+		}
+		c.put(entry)
+	}
+
+	return nil
+}
+
+// cachedWiki serves the pages of the cache, and fetches the missing pages from the embedded wiki.
+type cachedWiki struct {
+	Wiki
+	cache *Cache
+}
+
+// FindPages returns the pages of the given titles. The pages which aren't in the cache are fetched together in one query.
+// The continuation of a query isn't cached.
+func (w *cachedWiki) FindPages(titles, nextBatch string) ([]*Page, error) {
+	if nextBatch != "" {
+		return w.Wiki.FindPages(titles, nextBatch)
+	}
+
+	pages, missing := w.lookup(titles)
+	if len(missing) == 0 {
+		return pages, nil
+	}
+
+	fetched, err := w.Wiki.FindPages(missing, "")
+	for _, page := range fetched {
+		w.cache.add(page)
+	}
+
+	return append(pages, fetched...), err
+}
+
+// lookup returns the cached pages of the given titles, and the '|'-delimited titles of the pages which aren't cached.
+func (w *cachedWiki) lookup(titles string) ([]*Page, string) {
+	var (
+		pages   []*Page
+		missing []string
+	)
+	for _, title := range strings.Split(titles, titleSeparator) {
+		if page, ok := w.cache.get(title); ok {
+			pages = append(pages, page)
+			continue
+		}
+		missing = append(missing, title)
+	}
+
+	return pages, strings.Join(missing, titleSeparator)
+}
+
+// cachedStreamer serves the pages of the cache, and streams the missing pages from the streamer.
+type cachedStreamer struct {
+	*cachedWiki
+	streamer Streamer
+}
+
+// StreamPages sends the pages of the given titles to the returned channel in batches.
+// If all the pages are cached, they are sent in one batch, which is marked as Cached. Otherwise, the cached pages are sent with the first batch of the missing pages, so that every other batch is a call to the wiki.
+// The missing pages are cached once all their batches are received. The continuation of a stream isn't cached.
+func (w *cachedStreamer) StreamPages(ctx context.Context, titles, nextBatch string) <-chan *Batch {
+	if nextBatch != "" {
+		return w.streamer.StreamPages(ctx, titles, nextBatch)
+	}
+
+	pages, missing := w.lookup(titles)
+	batches := make(chan *Batch)
+
+	go func() {
+		defer close(batches)
+
+		if missing == "" {
+			select {
+			case batches <- &Batch{Pages: pages, Cached: true}:
+			case <-ctx.Done():
+			}
+			return
+		}
+
+		var (
+			fetched = map[string]*Page{}
+			titles  []string
+			last    *Batch
+		)
+		for batch := range w.streamer.StreamPages(ctx, missing, "") {
+			for _, page := range batch.Pages {
+				if merged, ok := fetched[page.Title]; ok {
+					merged.Links = append(merged.Links, page.Links...)
+					continue
+				}
+
+				merged := *page
+				merged.Links = append([]string(nil), page.Links...)
+				fetched[page.Title] = &merged
+				titles = append(titles, page.Title)
+			}
+
+			if last == nil && len(pages) > 0 {
+				batch = &Batch{Pages: append(append([]*Page{}, pages...), batch.Pages...), Next: batch.Next, Err: batch.Err}
+			}
+			last = batch
+
+			select {
+			case batches <- batch:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		// a stream which is canceled, or which fails before its last batch, has incomplete pages.
+		if ctx.Err() != nil || last == nil || last.Next != "" || (last.Err != nil && len(last.Pages) == 0) {
+			return
+		}
+
+		for _, title := range titles {
+			w.cache.add(fetched[title])
+		}
+	}()
+
+	return batches
+}
+
+type cachedRandomizer struct {
+	*cachedWiki
+	Randomizer
+}
+
+type cachedStreamingRandomizer struct {
+	*cachedStreamer
+	Randomizer
+}
@@ -0,0 +1,252 @@
+package wiki
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	t.Run("Hits", func(t *testing.T) {
+		var (
+			w     = &countingWiki{}
+			cache = NewCache(10, 0)
+			wiki  = cache.Wrap(w)
+
+			hits   = cacheHits.Value()
+			misses = cacheMisses.Value()
+		)
+
+		var testCases = []struct {
+			titles    string
+			requested []string
+		}{
+			{titles: "A|B", requested: []string{"A|B"}},
+			{titles: "A|B", requested: []string{"A|B"}},
+			{titles: "B|C", requested: []string{"A|B", "C"}},
+			{titles: "A|B|C", requested: []string{"A|B", "C"}},
+		}
+
+		for id, testCase := range testCases {
+			pages, err := wiki.FindPages(testCase.titles, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if expected, actual := strings.Split(testCase.titles, "|"), titles(pages); !reflect.DeepEqual(expected, actual) {
+				t.Errorf("Test case %d failed. Mismatch pages.\nExpected: %q\nActual: %q", id, expected, actual)
+			}
+
+			if !reflect.DeepEqual(testCase.requested, w.requested) {
+				t.Errorf("Test case %d failed. Mismatch requests.\nExpected: %q\nActual: %q", id, testCase.requested, w.requested)
+			}
+		}
+
+		if actual := cacheHits.Value() - hits; actual != 6 {
+			t.Errorf("Mismatch cache hits. Expected 6. Actual %v", actual)
+		}
+
+		if actual := cacheMisses.Value() - misses; actual != 3 {
+			t.Errorf("Mismatch cache misses. Expected 3. Actual %v", actual)
+		}
+	})
+
+	t.Run("Eviction", func(t *testing.T) {
+		var (
+			w     = &countingWiki{}
+			cache = NewCache(2, 0)
+			wiki  = cache.Wrap(w)
+		)
+
+		for _, titles := range []string{"A", "B", "A", "C", "A", "B"} {
+			if _, err := wiki.FindPages(titles, ""); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// B is the least recently used page when C is added.
+		if expected := []string{"A", "B", "C", "B"}; !reflect.DeepEqual(expected, w.requested) {
+			t.Errorf("Mismatch requests.\nExpected: %q\nActual: %q", expected, w.requested)
+		}
+
+		if cache.Len() != 2 {
+			t.Errorf("Mismatch cache size. Expected 2. Actual %d", cache.Len())
+		}
+	})
+
+	t.Run("Stream", func(t *testing.T) {
+		var (
+			w     = &countingStreamer{}
+			cache = NewCache(10, 0)
+			wiki  = cache.Wrap(w).(Streamer)
+		)
+
+		// every title of the countingStreamer is streamed in its own batch.
+		var testCases = []struct {
+			titles    string
+			batches   int
+			cached    bool
+			requested []string
+		}{
+			{titles: "A|B", batches: 2, requested: []string{"A|B"}},
+			{titles: "A|B", batches: 1, cached: true, requested: []string{"A|B"}},
+			{titles: "B|C", batches: 1, requested: []string{"A|B", "C"}},
+			{titles: "A|B|C", batches: 1, cached: true, requested: []string{"A|B", "C"}},
+		}
+
+		for id, testCase := range testCases {
+			var (
+				pages   []*Page
+				batches int
+			)
+			for batch := range wiki.StreamPages(context.Background(), testCase.titles, "") {
+				if batch.Err != nil {
+					t.Fatal(batch.Err)
+				}
+
+				if batch.Cached != testCase.cached {
+					t.Errorf("Test case %d failed. Mismatch cached. Expected %t. Actual %t", id, testCase.cached, batch.Cached)
+				}
+
+				pages = append(pages, batch.Pages...)
+				batches++
+			}
+
+			if batches != testCase.batches {
+				t.Errorf("Test case %d failed. Mismatch batches. Expected %d. Actual %d", id, testCase.batches, batches)
+			}
+
+			if expected, actual := strings.Split(testCase.titles, "|"), titles(pages); !reflect.DeepEqual(expected, actual) {
+				t.Errorf("Test case %d failed. Mismatch pages.\nExpected: %q\nActual: %q", id, expected, actual)
+			}
+
+			if !reflect.DeepEqual(testCase.requested, w.requested) {
+				t.Errorf("Test case %d failed. Mismatch requests.\nExpected: %q\nActual: %q", id, testCase.requested, w.requested)
+			}
+		}
+
+		// a canceled stream isn't cached.
+		ctx, cancel := context.WithCancel(context.Background())
+		<-wiki.StreamPages(ctx, "D|E", "")
+		cancel()
+
+		for range wiki.StreamPages(context.Background(), "D", "") {
+		}
+
+		if expected := []string{"A|B", "C", "D|E", "D"}; !reflect.DeepEqual(expected, w.requested) {
+			t.Errorf("Mismatch requests.\nExpected: %q\nActual: %q", expected, w.requested)
+		}
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		var (
+			w     = &countingWiki{}
+			cache = NewCache(10, time.Millisecond)
+			wiki  = cache.Wrap(w)
+		)
+
+		wiki.FindPages("A", "")
+		time.Sleep(5 * time.Millisecond)
+		wiki.FindPages("A", "")
+
+		if expected := []string{"A", "A"}; !reflect.DeepEqual(expected, w.requested) {
+			t.Errorf("Mismatch requests.\nExpected: %q\nActual: %q", expected, w.requested)
+		}
+	})
+}
+
+func TestCachePersistence(t *testing.T) {
+	var (
+		w     = &countingWiki{}
+		cache = NewCache(10, time.Hour)
+	)
+
+	for _, titles := range []string{"A|B", "C", "A"} {
+		if _, err := cache.Wrap(w).FindPages(titles, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := json.Marshal(cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the restored cache only has room for the 2 most recently used pages.
+	restored := NewCache(2, time.Hour)
+	if err := json.Unmarshal(data, restored); err != nil {
+		t.Fatal(err)
+	}
+
+	w.requested = nil
+	pages, err := restored.Wrap(w).FindPages("A|B|C", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := []string{"B"}; !reflect.DeepEqual(expected, w.requested) {
+		t.Errorf("Mismatch requests.\nExpected: %q\nActual: %q", expected, w.requested)
+	}
+
+	if expected := []string{"A", "B", "C"}; !reflect.DeepEqual(expected, titles(pages)) {
+		t.Errorf("Mismatch pages.\nExpected: %q\nActual: %q", expected, titles(pages))
+	}
+}
+
+// countingWiki records the titles it's requested. Every page links to itself.
+type countingWiki struct {
+	requested []string
+}
+
+func (w *countingWiki) FindPages(titles, nextBatch string) ([]*Page, error) {
+	w.requested = append(w.requested, titles)
+
+	var pages []*Page
+	for _, title := range strings.Split(titles, "|") {
+		pages = append(pages, &Page{Title: title, Links: []string{title}})
+	}
+	return pages, nil
+}
+
+// countingStreamer is a countingWiki which streams every page in its own batch.
+type countingStreamer struct {
+	countingWiki
+}
+
+func (w *countingStreamer) StreamPages(ctx context.Context, titles, nextBatch string) <-chan *Batch {
+	pages, _ := w.FindPages(titles, nextBatch)
+	batches := make(chan *Batch)
+
+	go func() {
+		defer close(batches)
+
+		for i, page := range pages {
+			batch := &Batch{Pages: []*Page{page}}
+			if i < len(pages)-1 {
+				batch.Next = strconv.Itoa(i + 1)
+			}
+
+			select {
+			case batches <- batch:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return batches
+}
+
+func titles(pages []*Page) []string {
+	var titles []string
+	for _, page := range pages {
+		titles = append(titles, page.Title)
+	}
+	sort.Strings(titles)
+	return titles
+}
@@ -0,0 +1,31 @@
+package wiki
+
+// the types of the events emitted during a crawl.
+const (
+	// EventVisit is emitted when a page is visited for the first time.
+	EventVisit = "visit"
+
+	// EventRequest is emitted when the pages of a batch of titles are requested from the wiki. Retries and the batches of a stream are counted separately.
+	EventRequest = "request"
+
+	// EventPath is emitted when a path to the destination is found.
+	EventPath = "path"
+)
+
+// Event reports the progress of a crawl.
+type Event struct {
+	// Type is one of EventVisit, EventRequest and EventPath.
+	Type string
+
+	// Page is the visited page of an EventVisit event.
+	Page *Page
+
+	// Depth is the number of links followed from the origin to the visited page, or to the destination.
+	Depth int
+
+	// Path is the path of an EventPath event, from the origin page to the destination page.
+	Path []*Page
+}
+
+// Observer receives the events of a crawl. It's called by the crawling goroutines concurrently, so it must be safe for concurrent use, and it must not block.
+type Observer func(Event)
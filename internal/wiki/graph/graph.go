@@ -0,0 +1,97 @@
+package graph
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/ihcsim/wikiracer/errors"
+	"github.com/ihcsim/wikiracer/internal/wiki"
+)
+
+const separator = "|"
+
+// Wiki is an in-memory wiki, whose pages are the nodes of a graph, and whose links are its edges.
+// It's used by the offline backend of the server, and by the mock wikis of the tests.
+type Wiki struct {
+	pages     map[string]*wiki.Page
+	redirects map[string]string
+}
+
+// New returns a new instance of Wiki with the given pages.
+func New(pages []*wiki.Page) *Wiki {
+	w := &Wiki{
+		pages:     map[string]*wiki.Page{},
+		redirects: map[string]string{},
+	}
+	for _, page := range pages {
+		w.AddPage(page)
+	}
+	return w
+}
+
+// Len returns the number of pages in the wiki.
+func (w *Wiki) Len() int {
+	return len(w.pages)
+}
+
+// AddPage adds page to the wiki. An existing page with the same title is replaced.
+func (w *Wiki) AddPage(page *wiki.Page) {
+	w.pages[page.Title] = page
+}
+
+// AddRedirect redirects the from title to the page titled to.
+func (w *Wiki) AddRedirect(from, to string) {
+	w.redirects[from] = to
+}
+
+// FindPages returns the pages with the given '|'-delimited titles, after resolving the redirects.
+// If some of the pages don't exist, the found pages are returned together with a 'pages not found' error.
+// The links of the pages aren't paginated, so nextBatch is ignored.
+func (w *Wiki) FindPages(titles, nextBatch string) ([]*wiki.Page, error) {
+	var (
+		pages   = []*wiki.Page{}
+		missing = []string{}
+	)
+	for _, title := range strings.Split(titles, separator) {
+		if to, redirected := w.redirects[title]; redirected {
+			title = to
+		}
+
+		page, exist := w.pages[title]
+		if !exist {
+			missing = append(missing, title)
+			continue
+		}
+
+		pages = append(pages, page)
+	}
+
+	if len(missing) > 0 {
+		return pages, errors.PagesNotFound{Titles: missing}
+	}
+
+	return pages, nil
+}
+
+// RandomPages returns count randomly selected pages from the wiki.
+// If count exceeds the number of pages in the wiki, all the pages are returned in a random order.
+func (w *Wiki) RandomPages(count int) ([]*wiki.Page, error) {
+	titles := []string{}
+	for title := range w.pages {
+		titles = append(titles, title)
+	}
+	sort.Strings(titles)
+
+	pages := []*wiki.Page{}
+	for _, index := range rand.Perm(len(titles)) {
+		if len(pages) == count {
+			break
+		}
+
+		page := w.pages[titles[index]]
+		pages = append(pages, &wiki.Page{ID: page.ID, Title: page.Title, Namespace: page.Namespace})
+	}
+
+	return pages, nil
+}
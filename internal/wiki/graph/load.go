@@ -0,0 +1,333 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/ihcsim/wikiracer/internal/wiki"
+)
+
+// jsonGraph is the JSON representation of a wiki.
+type jsonGraph struct {
+	Pages     []*jsonPage       `json:"pages"`
+	Redirects map[string]string `json:"redirects"`
+}
+
+type jsonPage struct {
+	ID             int      `json:"id"`
+	Title          string   `json:"title"`
+	Links          []string `json:"links"`
+	Categories     []string `json:"categories"`
+	Length         int      `json:"length"`
+	Disambiguation bool     `json:"disambiguation"`
+}
+
+// Load returns a Wiki with the pages of the graph file at path.
+// Files with the .json extension are parsed by ParseJSON. Files with the .dot and .gv extensions are parsed by ParseDOT.
+func Load(path string) (*Wiki, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch ext := filepath.Ext(path); ext {
+	case ".json":
+		return ParseJSON(content)
+	case ".dot", ".gv":
+		return ParseDOT(content)
+	default:
+		return nil, fmt.Errorf("Unsupported graph file extension %q", ext)
+	}
+}
+
+// ParseJSON returns a Wiki with the pages of a JSON graph like:
+//
+//	{
+//	  "pages": [
+//	    {"id": 1, "title": "Mike Tyson", "links": ["Boxing"], "categories": ["American male boxers"], "length": 181410},
+//	    {"title": "Boxing", "disambiguation": false}
+//	  ],
+//	  "redirects": {"Iron Mike": "Mike Tyson"}
+//	}
+//
+// Pages without an ID are numbered in the order they appear. Links to titles without a page are red links.
+func ParseJSON(data []byte) (*Wiki, error) {
+	var g jsonGraph
+	if err := json.Unmarshal(data, &g); err != nil {
+		return nil, err
+	}
+
+	pages := []*wiki.Page{}
+	for i, p := range g.Pages {
+		if p.Title == "" {
+			return nil, fmt.Errorf("Page %d has no title", i)
+		}
+
+		id := p.ID
+		if id == 0 {
+			id = i + 1
+		}
+
+		pages = append(pages, &wiki.Page{
+			ID:             id,
+			Title:          p.Title,
+			Links:          p.Links,
+			Categories:     p.Categories,
+			Length:         p.Length,
+			Disambiguation: p.Disambiguation,
+		})
+	}
+
+	w := New(pages)
+	for from, to := range g.Redirects {
+		w.AddRedirect(from, to)
+	}
+	return w, nil
+}
+
+// ParseDOT returns a Wiki with the pages of a graph in the DOT language, like:
+//
+//	digraph wiki {
+//	  "Mike Tyson" -> "Alexander the Great" -> "Greek language";
+//	  "Mike Tyson" -> {"1984 Summer Olympics" Afghanistan};
+//	  Tea;
+//	}
+//
+// Every node is a page, and every edge is a link. The edges of an undirected graph are links in both directions.
+// The pages are numbered in the order they appear. Attributes, and the statements which set them, are ignored.
+func ParseDOT(data []byte) (*Wiki, error) {
+	tokens, err := tokenize(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	p := &dotParser{tokens: tokens, links: map[string][]string{}}
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+
+	pages := []*wiki.Page{}
+	for i, title := range p.titles {
+		pages = append(pages, &wiki.Page{ID: i + 1, Title: title, Links: p.links[title]})
+	}
+
+	return New(pages), nil
+}
+
+type dotParser struct {
+	tokens   []string
+	pos      int
+	directed bool
+
+	titles []string
+	links  map[string][]string
+}
+
+func (p *dotParser) parse() error {
+	if p.peek() == "strict" {
+		p.pos++
+	}
+
+	switch p.next() {
+	case "digraph":
+		p.directed = true
+	case "graph":
+	default:
+		return fmt.Errorf("Expected graph or digraph")
+	}
+
+	if p.peek() != "{" {
+		p.pos++
+	}
+	if p.next() != "{" {
+		return fmt.Errorf("Expected {")
+	}
+
+	for {
+		switch token := p.peek(); token {
+		case "":
+			return fmt.Errorf("Expected }")
+		case "}":
+			return nil
+		case ";", ",":
+			p.pos++
+		case "graph", "node", "edge":
+			// the default attributes are ignored.
+			p.pos++
+		default:
+			if err := p.statement(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// statement parses a node statement, an edge statement or an attribute assignment.
+func (p *dotParser) statement() error {
+	from, err := p.nodes()
+	if err != nil {
+		return err
+	}
+
+	if p.peek() == "=" {
+		p.pos += 2
+		return nil
+	}
+
+	for _, title := range from {
+		p.add(title)
+	}
+
+	for p.peek() == "->" || p.peek() == "--" {
+		p.pos++
+		to, err := p.nodes()
+		if err != nil {
+			return err
+		}
+
+		for _, source := range from {
+			for _, target := range to {
+				p.add(target)
+				p.link(source, target)
+				if !p.directed {
+					p.link(target, source)
+				}
+			}
+		}
+		from = to
+	}
+
+	return nil
+}
+
+// nodes parses a node ID, or a group of node IDs in braces.
+func (p *dotParser) nodes() ([]string, error) {
+	if p.peek() != "{" {
+		id := p.next()
+		if !isID(id) {
+			return nil, fmt.Errorf("Expected node ID, got %q", id)
+		}
+		return []string{id}, nil
+	}
+
+	p.pos++
+	ids := []string{}
+	for {
+		switch id := p.next(); {
+		case id == "}":
+			return ids, nil
+		case id == ";" || id == ",":
+		case isID(id):
+			ids = append(ids, id)
+		default:
+			return nil, fmt.Errorf("Expected node ID, got %q", id)
+		}
+	}
+}
+
+func (p *dotParser) add(title string) {
+	if _, exist := p.links[title]; !exist {
+		p.titles = append(p.titles, title)
+		p.links[title] = nil
+	}
+}
+
+func (p *dotParser) link(from, to string) {
+	for _, link := range p.links[from] {
+		if link == to {
+			return
+		}
+	}
+	p.links[from] = append(p.links[from], to)
+}
+
+func (p *dotParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *dotParser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+func isID(token string) bool {
+	switch token {
+	case "", "{", "}", ";", ",", "=", "->", "--":
+		return false
+	}
+	return true
+}
+
+// tokenize splits a DOT document into IDs and operators. Quoted IDs are unquoted, while comments and attribute lists are dropped.
+func tokenize(dot string) ([]string, error) {
+	var (
+		tokens = []string{}
+		runes  = []rune(dot)
+	)
+
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; {
+		case unicode.IsSpace(r):
+		case r == '#' || (r == '/' && i+1 < len(runes) && runes[i+1] == '/'):
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			for i += 2; i+1 < len(runes) && !(runes[i] == '*' && runes[i+1] == '/'); i++ {
+			}
+			if i+1 >= len(runes) {
+				return nil, fmt.Errorf("Unterminated comment")
+			}
+			i++
+		case r == '[':
+			for i < len(runes) && runes[i] != ']' {
+				if runes[i] == '"' {
+					i++
+					for i < len(runes) && runes[i] != '"' {
+						if runes[i] == '\\' {
+							i++
+						}
+						i++
+					}
+				}
+				i++
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("Unterminated attribute list")
+			}
+		case r == '"':
+			var id strings.Builder
+			for i++; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && runes[i+1] == '"' {
+					i++
+				}
+				id.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("Unterminated quoted ID")
+			}
+			tokens = append(tokens, id.String())
+		case r == '-' && i+1 < len(runes) && (runes[i+1] == '>' || runes[i+1] == '-'):
+			tokens = append(tokens, string(runes[i:i+2]))
+			i++
+		case strings.ContainsRune("{};,=", r):
+			tokens = append(tokens, string(r))
+		default:
+			start := i
+			for i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) && !strings.ContainsRune("{};,=[\"#", runes[i+1]) && !(runes[i+1] == '-' && i+2 < len(runes) && (runes[i+2] == '>' || runes[i+2] == '-')) {
+				i++
+			}
+			tokens = append(tokens, string(runes[start:i+1]))
+		}
+	}
+
+	return tokens, nil
+}
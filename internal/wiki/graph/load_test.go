@@ -0,0 +1,121 @@
+package graph
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ihcsim/wikiracer/internal/wiki"
+)
+
+func TestParseDOT(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		dot      string
+		expected []*wiki.Page
+	}{
+		{
+			name: "Directed",
+			dot: `strict digraph wiki {
+				// comments and attributes are ignored.
+				rankdir=LR;
+				node [shape=box, label="a ] b"];
+				"Mike Tyson" -> "Alexander the Great" -> Apepi [color=red]
+				"Mike Tyson" -> {"1984 Summer Olympics"; Afghanistan} /* edges to many pages */
+				Tea # an orphan page
+			}`,
+			expected: []*wiki.Page{
+				&wiki.Page{ID: 1, Title: "Mike Tyson", Links: []string{"Alexander the Great", "1984 Summer Olympics", "Afghanistan"}},
+				&wiki.Page{ID: 2, Title: "Alexander the Great", Links: []string{"Apepi"}},
+				&wiki.Page{ID: 3, Title: "Apepi"},
+				&wiki.Page{ID: 4, Title: "1984 Summer Olympics"},
+				&wiki.Page{ID: 5, Title: "Afghanistan"},
+				&wiki.Page{ID: 6, Title: "Tea"},
+			},
+		},
+		{
+			name: "Undirected",
+			dot:  `graph { a -- b -- c; }`,
+			expected: []*wiki.Page{
+				&wiki.Page{ID: 1, Title: "a", Links: []string{"b"}},
+				&wiki.Page{ID: 2, Title: "b", Links: []string{"a", "c"}},
+				&wiki.Page{ID: 3, Title: "c", Links: []string{"b"}},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		w, err := ParseDOT([]byte(testCase.dot))
+		if err != nil {
+			t.Fatalf("Test case %q failed. Unexpected error: %s", testCase.name, err)
+		}
+
+		if actual := w.Len(); actual != len(testCase.expected) {
+			t.Errorf("Test case %q failed. Mismatch pages count. Expected %d. Actual %d", testCase.name, len(testCase.expected), actual)
+		}
+
+		for _, expected := range testCase.expected {
+			actual, err := w.FindPages(expected.Title, "")
+			if err != nil {
+				t.Errorf("Test case %q failed. Unexpected error: %s", testCase.name, err)
+				continue
+			}
+
+			if !reflect.DeepEqual(actual, []*wiki.Page{expected}) {
+				t.Errorf("Test case %q failed. Mismatch page.\nExpected: %+v\nActual: %+v", testCase.name, expected, actual[0])
+			}
+		}
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, dot := range []string{`digraph { a -> }`, `digraph { a -> b`, `tree { a }`, `digraph { "a }`} {
+			if _, err := ParseDOT([]byte(dot)); err == nil {
+				t.Errorf("Expected error didn't occur. DOT: %s", dot)
+			}
+		}
+	})
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "graph")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "wiki.json")
+	content := `{
+		"pages": [
+			{"id": 10, "title": "Mike Tyson", "links": ["Boxing", "Red link"], "categories": ["American male boxers"], "length": 181410},
+			{"title": "Boxing", "disambiguation": true}
+		],
+		"redirects": {"Iron Mike": "Mike Tyson"}
+	}`
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := w.FindPages("Iron Mike|Boxing", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*wiki.Page{
+		&wiki.Page{ID: 10, Title: "Mike Tyson", Links: []string{"Boxing", "Red link"}, Categories: []string{"American male boxers"}, Length: 181410},
+		&wiki.Page{ID: 2, Title: "Boxing", Disambiguation: true},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Mismatch pages.\nExpected: %+v\nActual: %+v", expected, actual)
+	}
+
+	if _, err := Load(filepath.Join(dir, "wiki.txt")); err == nil {
+		t.Error("Expected error didn't occur")
+	}
+}
@@ -13,4 +13,16 @@ type Page struct {
 
 	// Links is the collection of all the links (to other pages) found in the page.
 	Links []string
+
+	// Categories is the collection of the names of the categories that the page belongs to, without the namespace prefix.
+	// It's only set if the wiki is configured to fetch the pages' metadata.
+	Categories []string
+
+	// Length is the page's size in bytes.
+	// It's only set if the wiki is configured to fetch the pages' metadata.
+	Length int
+
+	// Disambiguation is true if the page is a disambiguation page.
+	// It's only set if the wiki is configured to fetch the pages' metadata.
+	Disambiguation bool
 }
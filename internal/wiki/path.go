@@ -9,14 +9,14 @@ const pathDelimiter = " -> "
 
 // Path is an ordered sequence of pages which forms a path from the first page to the last page.
 type Path struct {
-	mux      sync.Mutex
+	mux      sync.RWMutex
 	sequence []*Page
 }
 
 // NewPath returns a new instance of path.
 func NewPath() *Path {
 	return &Path{
-		mux:      sync.Mutex{},
+		mux:      sync.RWMutex{},
 		sequence: []*Page{},
 	}
 }
@@ -29,10 +29,26 @@ func (p *Path) AddPage(page *Page) {
 	p.sequence = append(p.sequence, page)
 }
 
+// Pages returns the pages of the path, from the first page to the last page.
+func (p *Path) Pages() []*Page {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+
+	return append([]*Page{}, p.sequence...)
+}
+
+// Len returns the number of pages in the path.
+func (p *Path) Len() int {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+
+	return len(p.sequence)
+}
+
 // String returns the string representation of the path.
 func (p *Path) String() string {
-	p.mux.Lock()
-	defer p.mux.Unlock()
+	p.mux.RLock()
+	defer p.mux.RUnlock()
 
 	var s string
 	for _, page := range p.sequence {
@@ -45,6 +61,12 @@ func (p *Path) String() string {
 
 // Clone copies the sequence of p2 into p1.
 func (p *Path) Clone(p2 *Path) int {
+	p2.mux.RLock()
+	defer p2.mux.RUnlock()
+
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
 	p.sequence = make([]*Page, len(p2.sequence))
 	return copy(p.sequence, p2.sequence)
 }
@@ -1,8 +1,44 @@
 package wiki
 
+import "context"
+
 // Wiki provides a collection of methods to communicate with a wiki instance.
 type Wiki interface {
 
-	// FindPages returns the page of the given title.
+	// FindPages returns the pages of the given titles.
+	// If some of the pages can't be found, the found pages are returned together with an errors.PagesNotFound error which lists the missing titles.
 	FindPages(titles, nextBatch string) ([]*Page, error)
 }
+
+// Randomizer can draw random pages from a wiki.
+type Randomizer interface {
+
+	// RandomPages returns count randomly selected pages from the main namespace.
+	// The returned pages only have their ID, Title and Namespace set.
+	RandomPages(count int) ([]*Page, error)
+}
+
+// Streamer can stream the pages of the given titles, one batch at a time, as they are received from the wiki.
+type Streamer interface {
+
+	// StreamPages sends the pages of the given titles to the returned channel in batches.
+	// The pages in a batch only contain the links received in that batch.
+	// The channel is closed after the last batch is sent, after a batch with a fatal error is sent, or when ctx is canceled.
+	StreamPages(ctx context.Context, titles, nextBatch string) <-chan *Batch
+}
+
+// Batch is a partial result of a streamed query.
+type Batch struct {
+	// Pages are the pages received in this batch.
+	Pages []*Page
+
+	// Next, if not empty, is the nextBatch value which can be used to resume the stream after this batch.
+	Next string
+
+	// Err is the error encountered while retrieving this batch.
+	// Like FindPages, the pages might still be valid if the error is non-fatal.
+	Err error
+
+	// Cached is true if the batch was served from a cache, without a call to the wiki.
+	Cached bool
+}
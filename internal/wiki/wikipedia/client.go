@@ -1,68 +1,201 @@
 package wikipedia
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
-	"strings"
+	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/ihcsim/wikiracer/errors"
+	"github.com/ihcsim/wikiracer/internal/metrics"
 	"github.com/ihcsim/wikiracer/internal/wiki"
-	"github.com/sadbox/mediawiki"
+	"github.com/ihcsim/wikiracer/log"
 )
 
 const (
-	url                   = "https://en.wikipedia.org/w/api.php"
-	userAgent             = "wikiracer"
+	endpoint              = "https://en.wikipedia.org/w/api.php"
 	responseFormat        = "json"
 	responseFormatVersion = "2"
+	errorFormat           = "plaintext"
 	responseLimits        = "max"
 	namespace             = "0"
+	disambiguationProp    = "disambiguation"
 
-	wikipediaTooManyRequestsErr = "Error: 429, Too Many Requests"
-	coolDownDuration            = time.Second
+	// coolDownDuration is the duration to wait before a rate limited request is retried, if the Wikipedia doesn't specify it.
+	coolDownDuration = time.Second
+
+	// maxCoolDownDuration is the longest duration that the client waits for. A request which is rate limited for longer fails with the RateLimited error.
+	maxCoolDownDuration = time.Minute
+)
+
+var (
+	apiCalls           = metrics.NewCounter("wikiracer_wikipedia_api_calls_total", "Number of calls to the Wikipedia API, including the retries.", "action")
+	apiCallDuration    = metrics.NewHistogram("wikiracer_wikipedia_api_call_duration_seconds", "Latency of the calls to the Wikipedia API.", metrics.DefaultBuckets, "action")
+	apiTooManyRequests = metrics.NewCounter("wikiracer_wikipedia_too_many_requests_total", "Number of calls to the Wikipedia API which were rejected with a 429 status code.")
 )
 
 // Client can communicate with the Wikipedia URL.
 type Client struct {
-	client *mediawiki.MWApi
-	api    apiFunc
+	http      *http.Client
+	api       apiFunc
+	endpoint  string
+	transport Transport
+	throttle  Throttle
+	limiter   *limiter
+	metadata  bool
+	fixture   *Fixture
+
+	credentials *Credentials
+	session     *session
 }
 
-// NewClient creates a new instanc of Client.
-func NewClient() (*Client, error) {
-	c, err := mediawiki.New(url, userAgent)
-	return &Client{
-		client: c,
-		api:    (*mediawiki.MWApi).API,
-	}, err
+// session is the login session of a Client. It's shared by the copies of the Client.
+type session struct {
+	mux        sync.Mutex
+	generation int
 }
 
-type apiFunc func(api *mediawiki.MWApi, values ...map[string]string) ([]byte, error)
+// Option can be used to configure the Client.
+type Option func(*Client)
 
-// FindPages returns the page of the given title.
-func (c *Client) FindPages(titles, nextBatch string) ([]*wiki.Page, error) {
-	response, err := c.query(titles, nextBatch)
+// WithMetadata configures the Client to fetch the categories, length and disambiguation flag of the pages, in the same query as their links.
+func WithMetadata() Option {
+	return func(c *Client) {
+		c.metadata = true
+	}
+}
+
+// NewClient creates a new instanc of Client.
+// If the Client is configured with credentials, it logs in before it's returned.
+func NewClient(options ...Option) (*Client, error) {
+	client := &Client{
+		endpoint:  endpoint,
+		transport: DefaultTransport,
+		throttle:  DefaultThrottle,
+		session:   &session{},
+	}
+	client.api = client.post
+
+	for _, option := range options {
+		option(client)
+	}
+
+	c, err := client.transport.httpClient()
 	if err != nil {
 		return nil, err
 	}
+	client.http = c
+	if client.fixture != nil {
+		client.http.Transport = client.fixture.roundTripper(client.http.Transport)
+	}
+	client.limiter = newLimiter(client.throttle)
 
-	if response.Errors != nil {
-		err := handleErrors(response.Errors)
-		return nil, err
+	if client.credentials != nil {
+		if err := client.login(client.currentSession()); err != nil {
+			return nil, err
+		}
 	}
 
-	if response.Warnings != nil {
-		err := handleWarnings(response.Warnings)
-		return nil, err
+	return client, nil
+}
+
+// Metadata returns a copy of c which fetches the categories, length and disambiguation flag of the pages, like WithMetadata.
+// The copy shares the connections, the concurrency limiter and the login session of c, so it's safe to use both concurrently.
+func (c *Client) Metadata() *Client {
+	copied := *c
+	copied.metadata = true
+	return &copied
+}
+
+type apiFunc func(values ...map[string]string) ([]byte, error)
+
+// FindPages returns the pages of the given titles.
+// The links of the pages are received in batches, which are merged before the pages are returned. Use StreamPages to process the batches as they are received.
+// If some of the pages are missing, the found pages are returned together with an errors.PagesNotFound error.
+func (c *Client) FindPages(titles, nextBatch string) ([]*wiki.Page, error) {
+	var (
+		results  = []*wiki.Page{}
+		missing  = []string{}
+		warnings = errors.Warnings{}
+	)
+
+	for batch := range c.StreamPages(context.Background(), titles, nextBatch) {
+		for _, e := range errors.Flatten(batch.Err) {
+			switch cast := e.(type) {
+			case errors.PagesNotFound:
+				// the missing pages are reported in every batch.
+				if len(missing) == 0 {
+					missing = cast.Titles
+				}
+			case errors.Warnings:
+				warnings = warnings.Merge(cast)
+			default:
+				return nil, batch.Err
+			}
+		}
+
+		results = merge(results, batch.Pages)
 	}
 
-	results := []*wiki.Page{}
+	return results, partial(missing, warnings)
+}
+
+// StreamPages sends the pages of the given titles to the returned channel, one batch at a time, as they are received from the Wikipedia.
+// The pages in a batch only contain the links received in that batch. The missing pages are reported in every batch.
+// The channel is closed after the last batch is sent, after a batch with a fatal error is sent, or when ctx is canceled.
+func (c *Client) StreamPages(ctx context.Context, titles, nextBatch string) <-chan *wiki.Batch {
+	batches := make(chan *wiki.Batch)
+
+	go func() {
+		defer close(batches)
+
+		for {
+			batch := c.fetch(ctx, titles, nextBatch)
+
+			// select picks randomly between the ready cases, so a batch received after the cancellation isn't sent.
+			if ctx.Err() != nil {
+				return
+			}
+
+			select {
+			case batches <- batch:
+			case <-ctx.Done():
+				return
+			}
+
+			if batch.Next == "" {
+				return
+			}
+			nextBatch = batch.Next
+		}
+	}()
+
+	return batches
+}
+
+// fetch retrieves one batch of the pages of the given titles.
+func (c *Client) fetch(ctx context.Context, titles, plcontinue string) *wiki.Batch {
+	response, err := c.query(ctx, titles, plcontinue)
+	if err != nil {
+		return &wiki.Batch{Err: err}
+	}
+
+	if response.Errors != nil {
+		return &wiki.Batch{Err: classify(response.Errors)}
+	}
+
+	var (
+		pages   = []*wiki.Page{}
+		missing = []string{}
+	)
 
 	if response.Result != nil {
 		for _, page := range response.Result.Pages {
 			if page.Missing {
-				return nil, errors.PageNotFound{wiki.Page{Title: page.Title}}
+				missing = append(missing, page.Title)
+				continue
 			}
 
 			var links []string
@@ -70,47 +203,72 @@ func (c *Client) FindPages(titles, nextBatch string) ([]*wiki.Page, error) {
 				links = append(links, link.Title)
 			}
 
-			results = append(results, &wiki.Page{
-				ID:        page.Pageid,
-				Title:     page.Title,
-				Namespace: page.Ns,
-				Links:     links,
+			var categories []string
+			for _, category := range page.Categories {
+				categories = append(categories, category.Name())
+			}
+
+			_, disambiguation := page.Pageprops[disambiguationProp]
+			pages = append(pages, &wiki.Page{
+				ID:             page.Pageid,
+				Title:          page.Title,
+				Namespace:      page.Ns,
+				Links:          links,
+				Categories:     categories,
+				Length:         page.Length,
+				Disambiguation: disambiguation,
 			})
 		}
 	}
 
+	batch := &wiki.Batch{
+		Pages: pages,
+		Err:   partial(missing, handleWarnings(response.Warnings)),
+	}
+
 	// the links in a page are usually returned in batches.
 	// when `batchcomplete` is set in the response, it implies that the server has returned the last batch of links for this page.
 	// when `plcontinue` is set in the response, it implies that there are more links yet to be fetched.
-
-	if response.Batchcomplete {
-		return results, nil
+	if !response.Batchcomplete && response.Next != nil {
+		batch.Next = response.Next.Token()
 	}
 
-	if response.Next != nil && response.Next.Plcontinue != "" {
-		nextBatch, err := c.FindPages(titles, response.Next.Plcontinue)
-		if err != nil {
-			return nil, err
-		}
+	return batch
+}
 
-		for _, batchResult := range nextBatch {
-			for _, result := range results {
-				if result.ID == batchResult.ID {
-					result.Links = append(result.Links, batchResult.Links...)
+// merge appends the links and categories of the pages in batch to their counterparts in results.
+// Pages that aren't in results are appended to results.
+func merge(results, batch []*wiki.Page) []*wiki.Page {
+	for _, batchResult := range batch {
+		merged := false
+		for _, result := range results {
+			if result.ID == batchResult.ID {
+				result.Links = append(result.Links, batchResult.Links...)
+				result.Categories = append(result.Categories, batchResult.Categories...)
+				result.Disambiguation = result.Disambiguation || batchResult.Disambiguation
+				if batchResult.Length > 0 {
+					result.Length = batchResult.Length
 				}
+				merged = true
+				break
 			}
 		}
+
+		if !merged {
+			results = append(results, batchResult)
+		}
 	}
 
-	return results, nil
+	return results
 }
 
-func (c *Client) query(titles, plcontinue string) (*Response, error) {
+func (c *Client) query(ctx context.Context, titles, nextBatch string) (*Response, error) {
 	query := map[string]string{
 		"action":        "query",
 		"prop":          "links",
 		"format":        responseFormat,
 		"formatversion": responseFormatVersion,
+		"errorformat":   errorFormat,
 		"pllimit":       responseLimits,
 		"plnamespace":   namespace,
 		"titles":        titles,
@@ -118,55 +276,193 @@ func (c *Client) query(titles, plcontinue string) (*Response, error) {
 		"utf8":          "true",
 	}
 
-	if plcontinue != "" {
-		query["plcontinue"] = plcontinue
+	if c.metadata {
+		query["prop"] = "links|categories|info|pageprops"
+		query["cllimit"] = responseLimits
+		query["clshow"] = "!hidden"
+		query["ppprop"] = "disambiguation"
+	}
+
+	for key, value := range continuation(nextBatch) {
+		query[key] = value
+	}
+
+	return c.do(ctx, query)
+}
+
+// RandomPages returns count randomly selected pages from the main namespace.
+func (c *Client) RandomPages(count int) ([]*wiki.Page, error) {
+	query := map[string]string{
+		"action":        "query",
+		"list":          "random",
+		"format":        responseFormat,
+		"formatversion": responseFormatVersion,
+		"errorformat":   errorFormat,
+		"rnlimit":       strconv.Itoa(count),
+		"rnnamespace":   namespace,
+		"utf8":          "true",
+	}
+
+	response, err := c.do(context.Background(), query)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.Errors != nil {
+		return nil, classify(response.Errors)
+	}
+
+	if warnings := handleWarnings(response.Warnings); len(warnings) > 0 {
+		log.Instance().Warningf("%s", warnings)
+	}
+
+	results := []*wiki.Page{}
+	if response.Result != nil {
+		for _, page := range response.Result.Random {
+			results = append(results, &wiki.Page{
+				ID:        page.ID,
+				Title:     page.Title,
+				Namespace: page.Ns,
+			})
+		}
+	}
+
+	return results, nil
+}
+
+// do sends query to the Wikipedia API, and decodes its response.
+// The rate limited and lagged calls are retried after the duration requested by the Wikipedia, unless ctx is done first.
+func (c *Client) do(ctx context.Context, query map[string]string) (*Response, error) {
+	c.assert(query)
+	if c.throttle.MaxLag > 0 {
+		query["maxlag"] = c.throttle.maxLagParam()
 	}
 
 	var (
-		content []byte
-		err     error
+		content          []byte
+		err              error
+		response         Response
+		relogged         bool
+		lagRetries       int
+		rateLimitRetries int
 	)
 	for {
-		content, err = c.api(c.client, query)
+		session := c.currentSession()
+		start := c.limiter.acquire()
+		content, err = c.call(query)
 		if err != nil {
+			c.limiter.release(start, true)
+
+			limited, ok := err.(*RateLimited)
+			if !ok {
+				return nil, err
+			}
+			apiTooManyRequests.Inc()
+
+			wait := limited.RetryAfter
+			if wait <= 0 {
+				wait = coolDownDuration
+			}
+
+			if rateLimitRetries >= c.throttle.RateLimitRetries || wait > maxCoolDownDuration {
+				return nil, err
+			}
+
+			// retry the API call after the cooldown duration expires
+			log.Instance().Debugf("Wiki is rate limiting. Retrying in %s", wait)
+			if err := sleep(ctx, wait); err != nil {
+				return nil, err
+			}
+			rateLimitRetries++
+			continue
+		}
+
+		response = Response{}
+		if err := json.Unmarshal(content, &response); err != nil {
+			c.limiter.release(start, true)
 			return nil, err
 		}
 
-		// check if the wikipedia API returns a 429 error
-		if !strings.Contains(string(content), wikipediaTooManyRequestsErr) {
+		wait, lagged := c.throttle.lagged(response.Errors)
+		c.limiter.release(start, lagged || throttled(response.Errors))
+
+		// retry the API call after the reported replication lag, if the wiki is lagged
+		if lagged && lagRetries < c.throttle.MaxLagRetries {
+			log.Instance().Debugf("Wiki is lagged. Retrying in %s", wait)
+			if err := sleep(ctx, wait); err != nil {
+				return nil, err
+			}
+			lagRetries++
+			continue
+		}
+
+		// log in again, and retry the API call once, if the session has expired
+		if c.credentials == nil || relogged || !expired(response.Errors) {
 			break
 		}
 
-		// retry the API call after the cooldown duration expires
-		time.Sleep(coolDownDuration)
+		log.Instance().Infof("Session expired. Logging in again. User=%q", c.credentials.user())
+		if err := c.login(session); err != nil {
+			return nil, err
+		}
+		relogged = true
 	}
 
-	var response Response
-	if err := json.Unmarshal(content, &response); err != nil {
-		return nil, err
+	return &response, nil
+}
+
+// sleep waits for d, or until ctx is done. It returns the error of ctx if ctx is done first.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
+}
 
-	return &response, nil
+// call sends query to the Wikipedia API, and records the call and its latency.
+func (c *Client) call(query map[string]string) ([]byte, error) {
+	start := time.Now()
+	defer func() {
+		apiCalls.Inc(query["action"])
+		apiCallDuration.Observe(time.Since(start).Seconds(), query["action"])
+	}()
+
+	return c.api(query)
 }
 
-func handleErrors(errors []*ResponseError) error {
-	err := &serverError{}
-	for _, e := range errors {
-		err.msg += fmt.Sprintf("%s\n", e.Text)
+// handleWarnings converts the warnings returned by the Wikipedia into non-fatal diagnostics.
+func handleWarnings(warnings []*ResponseWarning) errors.Warnings {
+	diagnostics := errors.Warnings{}
+	for _, w := range warnings {
+		diagnostics = diagnostics.Merge(errors.Warnings{{Code: w.Code, Text: w.Text}})
 	}
 
-	return err
+	return diagnostics
 }
 
-func handleWarnings(warnings *ResponseWarnings) error {
-	err := &serverError{}
-	if warnings.Main != nil {
-		err.msg = warnings.Main.Warnings
+// partial returns the non-fatal errors of a query whose results are still valid.
+// It returns nil if all the requested pages are found, and there are no warnings.
+func partial(missing []string, warnings errors.Warnings) error {
+	var list errors.List
+	if len(missing) > 0 {
+		list = append(list, errors.PagesNotFound{Titles: missing})
 	}
 
-	if warnings.Query != nil {
-		err.msg += warnings.Query.Warnings
+	if len(warnings) > 0 {
+		list = append(list, warnings)
 	}
 
-	return err
+	switch len(list) {
+	case 0:
+		return nil
+	case 1:
+		return list[0]
+	default:
+		return list
+	}
 }
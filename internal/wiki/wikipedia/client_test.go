@@ -1,18 +1,21 @@
 package wikipedia
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"testing"
 
 	"github.com/ihcsim/wikiracer/errors"
 	"github.com/ihcsim/wikiracer/internal/wiki"
-	"github.com/sadbox/mediawiki"
 )
 
 const (
 	invalidAction = "invalidAction"
 	invalidParam  = "invalidParam"
+	rateLimited   = "rateLimited"
+	internalError = "internalError"
+	deprecated    = "deprecated"
 )
 
 func TestFindPage(t *testing.T) {
@@ -74,7 +77,7 @@ func TestFindPage(t *testing.T) {
 				}
 
 				if !reflect.DeepEqual(actual, expected) {
-					t.Errorf("Mismatch page.\nExpected %s\n  Actual %s\n", expected, actual)
+					t.Errorf("Mismatch page.\nExpected %+v\n  Actual %+v\n", expected, actual)
 				}
 			})
 		})
@@ -130,7 +133,7 @@ func TestFindPage(t *testing.T) {
 				}
 
 				if !reflect.DeepEqual(actual, expected) {
-					t.Errorf("Mismatch page.\nExpected %s\n  Actual %s\n", expected, actual)
+					t.Errorf("Mismatch page.\nExpected %+v\n  Actual %+v\n", expected, actual)
 				}
 			})
 		})
@@ -142,11 +145,38 @@ func TestFindPage(t *testing.T) {
 			)
 			_, actual := client.FindPages(title, nextBatch)
 
-			expected := errors.PageNotFound{wiki.Page{Title: title}}
-			if expected.Error() != actual.Error() {
+			expected := errors.PagesNotFound{Titles: []string{title}}
+			if !reflect.DeepEqual(expected, actual) {
 				t.Errorf("Mismatch result.\nExpected error: %v\nActual error: %v", expected, actual)
 			}
 		})
+
+		t.Run("Partial Batch", func(t *testing.T) {
+			var (
+				firstTitle  = "Mike Tyson"
+				secondTitle = "Missing Page"
+				titles      = firstTitle + "|" + secondTitle
+				nextBatch   = ""
+			)
+			actual, err := client.FindPages(titles, nextBatch)
+
+			expectedErr := errors.PagesNotFound{Titles: []string{secondTitle}}
+			if !reflect.DeepEqual(expectedErr, err) {
+				t.Errorf("Mismatch result.\nExpected error: %v\nActual error: %v", expectedErr, err)
+			}
+
+			expected := []*wiki.Page{
+				&wiki.Page{
+					ID:        39027,
+					Title:     firstTitle,
+					Namespace: 0,
+					Links:     []string{"1984 Summer Olympics", "20/20 (US television show)", "Aaron Pryor", "Abdullah the Butcher"},
+				},
+			}
+			if !reflect.DeepEqual(actual, expected) {
+				t.Errorf("Mismatch page.\nExpected %+v\n  Actual %+v\n", expected, actual)
+			}
+		})
 	})
 
 	t.Run("Error", func(t *testing.T) {
@@ -158,8 +188,9 @@ func TestFindPage(t *testing.T) {
 		client.api = mockAPIError
 
 		t.Run("Invalid action", func(t *testing.T) {
-			expected := &serverError{
-				msg: fmt.Sprintf("Unrecognized value for parameter \"action\": %s.\n", invalidAction),
+			expected := &BadRequest{
+				Code: "unknown_action",
+				Msg:  fmt.Sprintf("Unrecognized value for parameter \"action\": %s.\n", invalidAction),
 			}
 			_, actual := client.FindPages(invalidAction, "")
 			if !reflect.DeepEqual(actual, expected) {
@@ -167,19 +198,219 @@ func TestFindPage(t *testing.T) {
 			}
 		})
 
-		t.Run("Invalid param", func(t *testing.T) {
-			expected := &serverError{
-				msg: fmt.Sprintf("Unrecognized parameter: %s.Unrecognized value for parameter \"list\": raremodule.", invalidParam),
+		t.Run("Rate limited", func(t *testing.T) {
+			expected := &RateLimited{
+				Code: "ratelimited",
+				Msg:  "You've exceeded your rate limit. Please wait some time and try again.\n",
 			}
-			_, actual := client.FindPages(invalidParam, "")
+			_, actual := client.FindPages(rateLimited, "")
 			if !reflect.DeepEqual(actual, expected) {
 				t.Errorf("Expected error didn't occur. Got %q", actual)
 			}
 		})
+
+		t.Run("Server error", func(t *testing.T) {
+			expected := &ServerError{
+				Code: "internal_api_error_DBQueryError",
+				Msg:  "A database query error has occurred.\n",
+			}
+			_, actual := client.FindPages(internalError, "")
+			if !reflect.DeepEqual(actual, expected) {
+				t.Errorf("Expected error didn't occur. Got %q", actual)
+			}
+		})
+	})
+
+	t.Run("Warning", func(t *testing.T) {
+		client, err := NewClient()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		client.api = mockAPIError
+
+		t.Run("Invalid param", func(t *testing.T) {
+			actual, err := client.FindPages(invalidParam, "")
+
+			expectedErr := errors.Warnings{
+				{Code: "unrecognizedparams", Text: fmt.Sprintf("Unrecognized parameter: %s.", invalidParam)},
+				{Code: "deprecation", Text: "The parameter \"redirects\" has been deprecated."},
+			}
+			if !reflect.DeepEqual(err, expectedErr) {
+				t.Errorf("Mismatch warnings.\nExpected %+v\n  Actual %+v\n", expectedErr, err)
+			}
+
+			expected := []*wiki.Page{
+				&wiki.Page{ID: 1006, Title: invalidParam, Namespace: 0, Links: []string{"Greek language"}},
+			}
+			if !reflect.DeepEqual(actual, expected) {
+				t.Errorf("Mismatch page.\nExpected %+v\n  Actual %+v\n", expected, actual)
+			}
+		})
+
+		t.Run("Multi-Batch Result", func(t *testing.T) {
+			actual, err := client.FindPages(deprecated, "")
+
+			expectedErr := errors.List{
+				errors.PagesNotFound{Titles: []string{"Missing Page"}},
+				errors.Warnings{{Code: "deprecation", Text: "The parameter \"redirects\" has been deprecated."}},
+			}
+			if !reflect.DeepEqual(err, expectedErr) {
+				t.Errorf("Mismatch warnings.\nExpected %+v\n  Actual %+v\n", expectedErr, err)
+			}
+
+			expected := []*wiki.Page{
+				&wiki.Page{ID: 1007, Title: deprecated, Namespace: 0, Links: []string{"Apepi", "Diodotus I"}},
+			}
+			if !reflect.DeepEqual(actual, expected) {
+				t.Errorf("Mismatch page.\nExpected %+v\n  Actual %+v\n", expected, actual)
+			}
+		})
+	})
+}
+
+func TestStreamPages(t *testing.T) {
+	client, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.api = mockAPI
+
+	t.Run("Multi-Batch Result", func(t *testing.T) {
+		var (
+			title    = "Alexander the Great"
+			expected = []*wiki.Batch{
+				&wiki.Batch{
+					Pages: []*wiki.Page{&wiki.Page{ID: 783, Title: title, Links: []string{"Apepi", "Aahotepre", "Abbasid Caliphate", "Abdalonymus"}}},
+					Next:  (&NextBatch{Plcontinue: "783|0|Dutch_Empire", Continue: "||"}).Token(),
+				},
+				&wiki.Batch{
+					Pages: []*wiki.Page{&wiki.Page{ID: 783, Title: title, Links: []string{"Dutch Empire", "Dynamis (Bosporan queen)", "Dynasty", "Early Dynastic Period (Egypt)"}}},
+					Next:  (&NextBatch{Plcontinue: "783|0|Menander", Continue: "||"}).Token(),
+				},
+				&wiki.Batch{
+					Pages: []*wiki.Page{&wiki.Page{ID: 783, Title: title, Links: []string{"Menandar", "Menes", "Mental health", "Mentuhotep I"}}},
+				},
+			}
+		)
+
+		actual := []*wiki.Batch{}
+		for batch := range client.StreamPages(context.Background(), title, "") {
+			actual = append(actual, batch)
+		}
+
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Mismatch batches.\nExpected %+v\n  Actual %+v\n", expected, actual)
+		}
+	})
+
+	t.Run("Resume", func(t *testing.T) {
+		var (
+			title    = "Alexander the Great"
+			expected = []*wiki.Batch{
+				&wiki.Batch{
+					Pages: []*wiki.Page{&wiki.Page{ID: 783, Title: title, Links: []string{"Menandar", "Menes", "Mental health", "Mentuhotep I"}}},
+				},
+			}
+		)
+
+		actual := []*wiki.Batch{}
+		token := (&NextBatch{Plcontinue: "783|0|Menander", Continue: "||"}).Token()
+		for batch := range client.StreamPages(context.Background(), title, token) {
+			actual = append(actual, batch)
+		}
+
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Mismatch batches.\nExpected %+v\n  Actual %+v\n", expected, actual)
+		}
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		batches := client.StreamPages(ctx, "Alexander the Great", "")
+		<-batches
+		cancel()
+
+		// the stream might have sent one more batch before it noticed the cancellation.
+		count := 0
+		for range batches {
+			count++
+		}
+
+		if count > 1 {
+			t.Errorf("Expected the stream to stop after it's canceled. Received %d more batches", count)
+		}
 	})
 }
 
-func mockAPI(api *mediawiki.MWApi, values ...map[string]string) ([]byte, error) {
+func TestFindPagesMetadata(t *testing.T) {
+	client, err := NewClient(WithMetadata())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.api = mockMetadataAPI
+
+	plain, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain.api = mockMetadataAPI
+
+	// the copy shares the limiter and the session of the plain client, which doesn't fetch the metadata.
+	copied := plain.Metadata()
+	if plain.metadata || copied.limiter != plain.limiter || copied.session != plain.session {
+		t.Errorf("Expected the copy to share the state of the plain client")
+	}
+
+	for _, client := range []*Client{client, copied} {
+		assertMetadata(t, client)
+	}
+}
+
+func assertMetadata(t *testing.T, client *Client) {
+	actual, err := client.FindPages("Mercury", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*wiki.Page{
+		&wiki.Page{
+			ID:             19694,
+			Title:          "Mercury",
+			Namespace:      0,
+			Links:          []string{"Mercury (element)", "Mercury (planet)"},
+			Categories:     []string{"Disambiguation pages", "Place name disambiguation pages"},
+			Length:         1894,
+			Disambiguation: true,
+		},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Mismatch pages.\nExpected %+v\nActual %+v\n", expected[0], actual[0])
+	}
+}
+
+func TestRandomPages(t *testing.T) {
+	client, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.api = mockRandomAPI
+
+	actual, err := client.RandomPages(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*wiki.Page{
+		&wiki.Page{ID: 2213951, Title: "Tamil Nadu Legislative Assembly election, 1962", Namespace: 0},
+		&wiki.Page{ID: 38702291, Title: "Kakkanad", Namespace: 0},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Mismatch pages.\nExpected %+v\nActual %+v\n", expected, actual)
+	}
+}
+
+func mockAPI(values ...map[string]string) ([]byte, error) {
 	var json []byte
 	switch values[0]["titles"] {
 	case "Mike Tyson":
@@ -419,6 +650,31 @@ func mockAPI(api *mediawiki.MWApi, values ...map[string]string) ([]byte, error)
 }`)
 		}
 
+	case "Mike Tyson|Missing Page":
+		json = []byte(`
+{
+  "batchcomplete": true,
+  "query": {
+    "pages": [
+      {
+        "pageid": 39027,
+        "ns": 0,
+        "title": "Mike Tyson",
+        "links": [
+          {"ns": 0, "title": "1984 Summer Olympics"},
+          {"ns": 0, "title": "20\/20 (US television show)"},
+          {"ns": 0, "title": "Aaron Pryor"},
+          {"ns": 0, "title": "Abdullah the Butcher"}
+        ]
+      },
+      {"ns": 0, "title": "Missing Page", "missing": true}
+    ]
+  },
+  "limits": {
+    "links": 500
+  }
+}`)
+
 	case "Missing Page":
 		json = []byte(`
 {
@@ -436,7 +692,7 @@ func mockAPI(api *mediawiki.MWApi, values ...map[string]string) ([]byte, error)
 	return json, nil
 }
 
-func mockAPIError(api *mediawiki.MWApi, values ...map[string]string) ([]byte, error) {
+func mockAPIError(values ...map[string]string) ([]byte, error) {
 	var json []byte
 
 	switch values[0]["titles"] {
@@ -454,20 +710,196 @@ func mockAPIError(api *mediawiki.MWApi, values ...map[string]string) ([]byte, er
   "servedby": "mw1282"
 }`, invalidAction))
 
+	case rateLimited:
+		json = []byte(`
+{
+  "errors": [
+    {
+      "code": "ratelimited",
+      "text": "You've exceeded your rate limit. Please wait some time and try again.",
+      "module": "main"
+    }
+  ],
+  "servedby": "mw1282"
+}`)
+
+	case internalError:
+		json = []byte(`
+{
+  "errors": [
+    {
+      "code": "internal_api_error_DBQueryError",
+      "text": "A database query error has occurred.",
+      "module": "main"
+    }
+  ],
+  "servedby": "mw1282"
+}`)
+
 	case invalidParam:
 		json = []byte(fmt.Sprintf(`
 {
   "batchcomplete": true,
-  "warnings": {
-    "main": {
-      "warnings": "Unrecognized parameter: %s."
+  "warnings": [
+    {
+      "code": "unrecognizedparams",
+      "text": "Unrecognized parameter: %s.",
+      "module": "main"
     },
-    "query": {
-      "warnings": "Unrecognized value for parameter \"list\": raremodule."
+    {
+      "code": "deprecation",
+      "text": "The parameter \"redirects\" has been deprecated.",
+      "module": "query"
+    }
+  ],
+  "query": {
+    "pages": [
+      {
+        "pageid": 1006,
+        "ns": 0,
+        "title": "%s",
+        "links": [
+          {"ns": 0, "title": "Greek language"}
+        ]
+      }
+    ]
+  }
+}`, invalidParam, invalidParam))
+
+	case deprecated:
+		switch values[0]["plcontinue"] {
+		case "1007|0|Diodotus_I":
+			json = []byte(fmt.Sprintf(`
+{
+  "batchcomplete": true,
+  "warnings": [
+    {
+      "code": "deprecation",
+      "text": "The parameter \"redirects\" has been deprecated.",
+      "module": "query"
     }
+  ],
+  "query": {
+    "pages": [
+      {
+        "pageid": 1007,
+        "ns": 0,
+        "title": "%s",
+        "links": [
+          {"ns": 0, "title": "Diodotus I"}
+        ]
+      },
+      {"ns": 0, "title": "Missing Page", "missing": true}
+    ]
   }
-}`, invalidParam))
+}`, deprecated))
+
+		default:
+			json = []byte(fmt.Sprintf(`
+{
+  "continue": {
+    "plcontinue": "1007|0|Diodotus_I",
+    "continue": "||"
+  },
+  "warnings": [
+    {
+      "code": "deprecation",
+      "text": "The parameter \"redirects\" has been deprecated.",
+      "module": "query"
+    }
+  ],
+  "query": {
+    "pages": [
+      {
+        "pageid": 1007,
+        "ns": 0,
+        "title": "%s",
+        "links": [
+          {"ns": 0, "title": "Apepi"}
+        ]
+      },
+      {"ns": 0, "title": "Missing Page", "missing": true}
+    ]
+  }
+}`, deprecated))
+		}
 	}
 
 	return json, nil
 }
+
+func mockRandomAPI(values ...map[string]string) ([]byte, error) {
+	if values[0]["list"] != "random" || values[0]["rnnamespace"] != namespace {
+		return nil, fmt.Errorf("Unexpected query: %v", values[0])
+	}
+
+	return []byte(`
+{
+  "batchcomplete": true,
+  "continue": {
+    "rncontinue": "0.476290760829|0.476292137223|25262740|0",
+    "continue": "-||"
+  },
+  "query": {
+    "random": [
+      {"id": 2213951, "ns": 0, "title": "Tamil Nadu Legislative Assembly election, 1962"},
+      {"id": 38702291, "ns": 0, "title": "Kakkanad"}
+    ]
+  }
+}`), nil
+}
+
+func mockMetadataAPI(values ...map[string]string) ([]byte, error) {
+	if values[0]["prop"] != "links|categories|info|pageprops" || values[0]["ppprop"] != disambiguationProp {
+		return nil, fmt.Errorf("Unexpected query: %v", values[0])
+	}
+
+	if values[0]["clcontinue"] == "19694|Place_name_disambiguation_pages" {
+		return []byte(`
+{
+  "batchcomplete": true,
+  "query": {
+    "pages": [
+      {
+        "pageid": 19694,
+        "ns": 0,
+        "title": "Mercury",
+        "contentmodel": "wikitext",
+        "length": 1894,
+        "pageprops": {"disambiguation": ""},
+        "categories": [
+          {"ns": 14, "title": "Category:Place name disambiguation pages"}
+        ]
+      }
+    ]
+  }
+}`), nil
+	}
+
+	return []byte(`
+{
+  "continue": {
+    "clcontinue": "19694|Place_name_disambiguation_pages",
+    "continue": "||"
+  },
+  "query": {
+    "pages": [
+      {
+        "pageid": 19694,
+        "ns": 0,
+        "title": "Mercury",
+        "contentmodel": "wikitext",
+        "length": 1894,
+        "pageprops": {"disambiguation": ""},
+        "links": [
+          {"ns": 0, "title": "Mercury (element)"},
+          {"ns": 0, "title": "Mercury (planet)"}
+        ],
+        "categories": [
+          {"ns": 14, "title": "Category:Disambiguation pages"}
+        ]
+      }
+    ]
+  }
+}`), nil
+}
@@ -0,0 +1,184 @@
+package wikipedia
+
+import (
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ihcsim/wikiracer/errors"
+	"github.com/ihcsim/wikiracer/internal/wiki"
+	"github.com/ihcsim/wikiracer/log"
+	"github.com/ihcsim/wikiracer/test"
+	"github.com/ihcsim/wikiracer/test/apiserver"
+)
+
+func TestEndToEnd(t *testing.T) {
+	log.Instance().SetBackend(log.QuietBackend)
+
+	t.Run("Pagination", func(t *testing.T) {
+		server := apiserver.New(test.NewMockWiki(), apiserver.WithLinkLimit(1))
+		stub := httptest.NewServer(server)
+		defer stub.Close()
+
+		client, err := NewClient(WithEndpoint(stub.URL))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		actual, err := client.FindPages("Mike Tyson|Alexander the Great", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []*wiki.Page{
+			&wiki.Page{ID: 1000, Title: "Alexander the Great", Links: []string{"Apepi", "Diodotus I", "Greek language"}},
+			&wiki.Page{ID: 1003, Title: "Mike Tyson", Links: []string{"1984 Summer Olympics", "Alexander the Great"}},
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Mismatch pages.\nExpected: %+v\nActual: %+v", expected, actual)
+		}
+
+		if actual := server.Requests(); actual != 5 {
+			t.Errorf("Mismatch requests count. Expected 5. Actual %d", actual)
+		}
+	})
+
+	t.Run("Stream", func(t *testing.T) {
+		stub := httptest.NewServer(apiserver.New(test.NewMockWiki(), apiserver.WithLinkLimit(2)))
+		defer stub.Close()
+
+		client, err := NewClient(WithEndpoint(stub.URL))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var batches int
+		for batch := range client.StreamPages(context.Background(), "Mike Tyson|Alexander the Great", "") {
+			if batch.Err != nil {
+				t.Fatal(batch.Err)
+			}
+			batches++
+		}
+
+		if batches != 3 {
+			t.Errorf("Mismatch batches count. Expected 3. Actual %d", batches)
+		}
+	})
+
+	t.Run("Redirects And Missing Pages", func(t *testing.T) {
+		stub := httptest.NewServer(apiserver.New(test.NewMockWiki(), apiserver.WithRedirects(map[string]string{"Iron Mike": "Mike Tyson"})))
+		defer stub.Close()
+
+		client, err := NewClient(WithEndpoint(stub.URL))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		actual, err := client.FindPages("Iron Mike|Missing Page", "")
+		expectedErr := errors.PagesNotFound{Titles: []string{"Missing Page"}}
+		if !reflect.DeepEqual(err, expectedErr) {
+			t.Errorf("Mismatch error.\nExpected: %v\nActual: %v", expectedErr, err)
+		}
+
+		expected := []*wiki.Page{&wiki.Page{ID: 1003, Title: "Mike Tyson", Links: []string{"1984 Summer Olympics", "Alexander the Great"}}}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Mismatch pages.\nExpected: %+v\nActual: %+v", expected, actual)
+		}
+	})
+
+	t.Run("Metadata", func(t *testing.T) {
+		stub := httptest.NewServer(apiserver.New(test.NewMockWiki()))
+		defer stub.Close()
+
+		client, err := NewClient(WithEndpoint(stub.URL), WithMetadata())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		actual, err := client.FindPages("Segment", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []*wiki.Page{&wiki.Page{ID: 1004, Title: "Segment", Links: []string{"Vancouver"}, Categories: []string{"Disambiguation pages"}, Length: 1640, Disambiguation: true}}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Mismatch pages.\nExpected: %+v\nActual: %+v", expected, actual)
+		}
+	})
+
+	t.Run("Random", func(t *testing.T) {
+		stub := httptest.NewServer(apiserver.New(test.NewMockWiki()))
+		defer stub.Close()
+
+		client, err := NewClient(WithEndpoint(stub.URL))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pages, err := client.RandomPages(3)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(pages) != 3 {
+			t.Errorf("Mismatch pages count. Expected 3. Actual %d", len(pages))
+		}
+	})
+
+	t.Run("Rate Limited", func(t *testing.T) {
+		server := apiserver.New(test.NewMockWiki(), apiserver.WithRateLimit(1, 500*time.Millisecond))
+		stub := httptest.NewServer(server)
+		defer stub.Close()
+
+		client, err := NewClient(WithEndpoint(stub.URL))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 2; i++ {
+			if _, err := client.FindPages("Mike Tyson", ""); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// the second request is rejected once, and retried after the cool down.
+		if actual := server.Requests(); actual != 3 {
+			t.Errorf("Mismatch requests count. Expected 3. Actual %d", actual)
+		}
+	})
+
+	t.Run("Lagged", func(t *testing.T) {
+		server := apiserver.New(test.NewMockWiki(), apiserver.WithLag(10*time.Second))
+		stub := httptest.NewServer(server)
+		defer stub.Close()
+
+		client, err := NewClient(WithEndpoint(stub.URL), WithThrottle(Throttle{MaxLag: 5 * time.Second}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = client.FindPages("Mike Tyson", "")
+		if e, ok := err.(*ServerError); !ok || e.Code != maxLagCode {
+			t.Errorf("Mismatch error. Got %v", err)
+		}
+	})
+
+	t.Run("Latency", func(t *testing.T) {
+		stub := httptest.NewServer(apiserver.New(test.NewMockWiki(), apiserver.WithLatency(200*time.Millisecond)))
+		defer stub.Close()
+
+		client, err := NewClient(WithEndpoint(stub.URL), WithTransport(Transport{Timeout: 20 * time.Millisecond}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = client.FindPages("Mike Tyson", "")
+		if timeout, ok := err.(interface {
+			Timeout() bool
+		}); !ok || !timeout.Timeout() {
+			t.Errorf("Expected timeout error didn't occur. Got %v", err)
+		}
+	})
+}
@@ -1,11 +1,152 @@
 package wikipedia
 
-// serverError represents an 'invalid API action' error.
-type serverError struct {
-	msg string
+import (
+	"fmt"
+	"time"
+)
+
+// the error codes used by the MediaWiki API to report rate limiting.
+// For more information, refer to https://www.mediawiki.org/wiki/API:Errors_and_warnings
+var rateLimitedCodes = map[string]struct{}{
+	"ratelimited": struct{}{},
+}
+
+// the error codes used by the MediaWiki API to report invalid requests.
+var badRequestCodes = map[string]struct{}{
+	"assertnameduserfailed": struct{}{},
+	"assertuserfailed":      struct{}{},
+	"badinteger":            struct{}{},
+	"badvalue":              struct{}{},
+	"invalidparammix":       struct{}{},
+	"invalidtitle":          struct{}{},
+	"missingparam":          struct{}{},
+	"mustpostparams":        struct{}{},
+	"nosuchpageid":          struct{}{},
+	"paramempty":            struct{}{},
+	"toomanyvalues":         struct{}{},
+	"unknown_action":        struct{}{},
+	"unrecognizedparams":    struct{}{},
+}
+
+// RateLimited is the error used when the Wikipedia rejects a request because the client exceeded its rate limits.
+type RateLimited struct {
+	Code string
+	Msg  string
+
+	// RetryAfter is the duration that the Wikipedia asked the client to wait before it retries the request, from the Retry-After header of a 429 response.
+	// It's zero if the Wikipedia didn't specify it.
+	RetryAfter time.Duration
+}
+
+// Error returns the string representation of the error.
+func (e *RateLimited) Error() string {
+	return e.Msg
+}
+
+// Temporary returns true, since the request can be retried after the rate limit expires.
+func (e *RateLimited) Temporary() bool {
+	return true
+}
+
+// BadRequest is the error used when the Wikipedia rejects a request because it's invalid.
+// Retrying the same request won't succeed.
+type BadRequest struct {
+	Code string
+	Msg  string
+}
+
+// Error returns the string representation of the error.
+func (e *BadRequest) Error() string {
+	return e.Msg
+}
+
+// Temporary returns false, since retrying the same request won't succeed.
+func (e *BadRequest) Temporary() bool {
+	return false
+}
+
+// ServerError is the error used when the Wikipedia fails to process a request.
+type ServerError struct {
+	Code string
+	Msg  string
+}
+
+// Error returns the string representation of the error.
+func (e *ServerError) Error() string {
+	return e.Msg
+}
+
+// Temporary returns true, since the server might recover from the failure.
+func (e *ServerError) Temporary() bool {
+	return true
+}
+
+// LoginFailed is the error used when the wiki rejects the credentials of the Client.
+type LoginFailed struct {
+	Result string
+	Reason string
+}
+
+// Error returns the string representation of the error.
+func (e *LoginFailed) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("Login failed: %s", e.Result)
+	}
+	return fmt.Sprintf("Login failed: %s. %s", e.Result, e.Reason)
+}
+
+// Temporary returns false, since the same credentials will be rejected again.
+func (e *LoginFailed) Temporary() bool {
+	return false
+}
+
+// classify converts the errors returned by the Wikipedia into one typed error, based on their codes.
+// If the response has multiple errors, the most actionable error type wins, in this order: RateLimited, BadRequest, ServerError.
+func classify(errors []*ResponseError) error {
+	var msg string
+	for _, e := range errors {
+		msg += fmt.Sprintf("%s\n", e.Text)
+	}
+
+	var badRequest *BadRequest
+	for _, e := range errors {
+		if _, ok := rateLimitedCodes[e.Code]; ok {
+			return &RateLimited{Code: e.Code, Msg: msg}
+		}
+
+		if _, ok := badRequestCodes[e.Code]; ok && badRequest == nil {
+			badRequest = &BadRequest{Code: e.Code, Msg: msg}
+		}
+	}
+
+	if badRequest != nil {
+		return badRequest
+	}
+
+	serverError := &ServerError{Msg: msg}
+	if len(errors) > 0 {
+		serverError.Code = errors[0].Code
+	}
+	return serverError
+}
+
+// UnexpectedRequest is the error used when a Client replaying a Fixture sends a request which isn't recorded in the fixture.
+type UnexpectedRequest struct {
+	Fixture string
+	Request string
 }
 
 // Error returns the string representation of the error.
-func (e *serverError) Error() string {
-	return e.msg
+func (e *UnexpectedRequest) Error() string {
+	return fmt.Sprintf("Request isn't recorded in fixture %s: %s", e.Fixture, e.Request)
+}
+
+// Temporary returns false, since the fixture won't change.
+func (e *UnexpectedRequest) Temporary() bool {
+	return false
+}
+
+// Fatal returns true, since a fixture which doesn't match the requests of a race can't replay it.
+func (e *UnexpectedRequest) Fatal() bool {
+	return true
 }
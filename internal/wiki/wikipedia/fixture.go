@@ -0,0 +1,231 @@
+package wikipedia
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// redacted replaces the values of the secret parameters in the recorded requests.
+const redacted = "REDACTED"
+
+// the parameters whose values are never written to a fixture file.
+var secretParams = []string{"lgpassword"}
+
+// Fixture is a file of the requests that the Client sent to the wiki, and the responses it received.
+// A recording Fixture captures the interactions of the clients configured with it. A replaying Fixture serves the recorded responses, without sending the requests to the wiki.
+// A Fixture can be shared by many clients.
+type Fixture struct {
+	path   string
+	replay bool
+
+	mux          sync.Mutex
+	interactions []*Interaction
+	index        map[string][]*Interaction
+	served       map[string]int
+}
+
+// Interaction is a recorded request, and its response.
+type Interaction struct {
+	// Request is the URL-encoded form of the request, with its parameters sorted by name.
+	Request string `json:"request"`
+
+	// Status is the status code of the response.
+	Status int `json:"status"`
+
+	// Body is the body of the response.
+	Body string `json:"body"`
+}
+
+type fixtureFile struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// RecordFixture returns a Fixture which records the interactions of its clients to the file at path.
+// The file is rewritten after every interaction. The values of secret parameters, like the bot password, are redacted.
+func RecordFixture(path string) *Fixture {
+	return &Fixture{path: path}
+}
+
+// ReplayFixture returns a Fixture which serves the responses recorded in the file at path.
+// The requests are matched by their parameters. Identical requests are served the recorded responses in the order they were recorded, with the last response repeated once they are exhausted.
+// A request which isn't in the file fails with an UnexpectedRequest error.
+func ReplayFixture(path string) (*Fixture, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file fixtureFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, err
+	}
+
+	f := &Fixture{
+		path:         path,
+		replay:       true,
+		interactions: file.Interactions,
+		index:        map[string][]*Interaction{},
+		served:       map[string]int{},
+	}
+	for _, interaction := range file.Interactions {
+		f.index[interaction.Request] = append(f.index[interaction.Request], interaction)
+	}
+
+	return f, nil
+}
+
+// WithFixture configures the Client to record its interactions with the wiki to f, or to replay them from f.
+func WithFixture(f *Fixture) Option {
+	return func(c *Client) {
+		c.fixture = f
+	}
+}
+
+// Interactions returns the recorded interactions.
+func (f *Fixture) Interactions() []*Interaction {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	return append([]*Interaction{}, f.interactions...)
+}
+
+// roundTripper returns the http.RoundTripper which records the requests sent through next, or replays them.
+func (f *Fixture) roundTripper(next http.RoundTripper) http.RoundTripper {
+	return &fixtureTransport{fixture: f, next: next}
+}
+
+// replayed returns the recorded response of the request.
+func (f *Fixture) replayed(request string) (*Interaction, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	recorded, ok := f.index[request]
+	if !ok {
+		return nil, &UnexpectedRequest{Fixture: f.path, Request: request}
+	}
+
+	i := f.served[request]
+	if i < len(recorded)-1 {
+		f.served[request]++
+	}
+	return recorded[i], nil
+}
+
+// record appends the interaction to the fixture file.
+func (f *Fixture) record(interaction *Interaction) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	f.interactions = append(f.interactions, interaction)
+	content, err := json.MarshalIndent(fixtureFile{Interactions: f.interactions}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// the file is replaced atomically, so that it's never left half-written.
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), filepath.Base(f.path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.path)
+}
+
+type fixtureTransport struct {
+	fixture *Fixture
+	next    http.RoundTripper
+}
+
+// RoundTrip records or replays the request.
+func (t *fixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	request, err := canonical(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.fixture.replay {
+		interaction, err := t.fixture.replayed(request)
+		if err != nil {
+			return nil, err
+		}
+
+		return &http.Response{
+			Status:     fmt.Sprintf("%d %s", interaction.Status, http.StatusText(interaction.Status)),
+			StatusCode: interaction.Status,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     http.Header{"Content-Type": []string{"application/json; charset=utf-8"}},
+			Body:       ioutil.NopCloser(strings.NewReader(interaction.Body)),
+			Request:    req,
+		}, nil
+	}
+
+	response, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.fixture.record(&Interaction{Request: request, Status: response.StatusCode, Body: string(body)}); err != nil {
+		return nil, err
+	}
+
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return response, nil
+}
+
+// canonical returns the parameters of the request, sorted by name and with the secrets redacted.
+func canonical(req *http.Request) (string, error) {
+	params := req.URL.Query()
+	if req.GetBody != nil {
+		reader, err := req.GetBody()
+		if err != nil {
+			return "", err
+		}
+		defer reader.Close()
+
+		body, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return "", err
+		}
+
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return "", err
+		}
+		for key, values := range form {
+			params[key] = values
+		}
+	}
+
+	for _, key := range secretParams {
+		if _, ok := params[key]; ok {
+			params.Set(key, redacted)
+		}
+	}
+
+	return params.Encode(), nil
+}
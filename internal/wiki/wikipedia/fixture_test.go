@@ -0,0 +1,125 @@
+package wikipedia
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ihcsim/wikiracer/log"
+	"github.com/ihcsim/wikiracer/test"
+	"github.com/ihcsim/wikiracer/test/apiserver"
+)
+
+func TestFixture(t *testing.T) {
+	log.Instance().SetBackend(log.QuietBackend)
+
+	dir, err := ioutil.TempDir("", "fixture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	t.Run("Record And Replay", func(t *testing.T) {
+		path := filepath.Join(dir, "race.json")
+		stub := httptest.NewServer(apiserver.New(test.NewMockWiki(), apiserver.WithLinkLimit(2)))
+
+		recorder, err := NewClient(WithEndpoint(stub.URL), WithFixture(RecordFixture(path)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expectedPages, err := recorder.FindPages("Mike Tyson|Alexander the Great", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expectedRandom, err := recorder.RandomPages(2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stub.Close()
+
+		fixture, err := ReplayFixture(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if actual := len(fixture.Interactions()); actual != 4 {
+			t.Errorf("Mismatch interactions count. Expected 4. Actual %d", actual)
+		}
+
+		replayer, err := NewClient(WithEndpoint(stub.URL), WithFixture(fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		actualPages, err := replayer.FindPages("Mike Tyson|Alexander the Great", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(actualPages, expectedPages) {
+			t.Errorf("Mismatch pages.\nExpected: %+v\nActual: %+v", expectedPages, actualPages)
+		}
+
+		// the random pages are replayed too.
+		for i := 0; i < 2; i++ {
+			actualRandom, err := replayer.RandomPages(2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(actualRandom, expectedRandom) {
+				t.Errorf("Mismatch random pages.\nExpected: %+v\nActual: %+v", expectedRandom, actualRandom)
+			}
+		}
+
+		t.Run("Unexpected Request", func(t *testing.T) {
+			_, err := replayer.FindPages("Vancouver", "")
+			if unexpected, ok := err.(*UnexpectedRequest); !ok || unexpected.Fixture != path {
+				t.Errorf("Mismatch error. Got %v", err)
+			}
+		})
+	})
+
+	t.Run("Redacted Password", func(t *testing.T) {
+		path := filepath.Join(dir, "login.json")
+		stub := httptest.NewServer(&stubWiki{sessions: map[string]struct{}{}})
+
+		credentials := WithCredentials(Credentials{Username: botUsername, Password: botPassword})
+		recorder, err := NewClient(WithEndpoint(stub.URL), credentials, WithFixture(RecordFixture(path)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := recorder.FindPages("Mike Tyson", ""); err != nil {
+			t.Fatal(err)
+		}
+		stub.Close()
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if strings.Contains(string(content), botPassword) {
+			t.Errorf("Expected password to be redacted. Fixture: %s", content)
+		}
+
+		fixture, err := ReplayFixture(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		replayer, err := NewClient(WithEndpoint(stub.URL), credentials, WithFixture(fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := replayer.FindPages("Mike Tyson", ""); err != nil {
+			t.Error(err)
+		}
+	})
+}
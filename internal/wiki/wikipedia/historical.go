@@ -0,0 +1,259 @@
+package wikipedia
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ihcsim/wikiracer/errors"
+	"github.com/ihcsim/wikiracer/internal/wiki"
+)
+
+const (
+	mainSlot          = "main"
+	timestampFormat   = "2006-01-02T15:04:05Z"
+	categoryNamespace = "Category"
+)
+
+var (
+	// wikilink matches the internal links of the wikitext, like [[Title]], [[Title#Section|label]] and [[Category:Name]].
+	wikilink = regexp.MustCompile(`\[\[([^\[\]|#]+)(#[^\[\]|]*)?(\|[^\[\]]*)?\]\]`)
+
+	// redirect matches the wikitext of a redirect page.
+	redirect = regexp.MustCompile(`(?i)^\s*#redirect\s*:?\s*\[\[([^\[\]|#]+)`)
+
+	// interwikiPrefixes are the prefixes of the links to the sister projects of Wikipedia, in lower case.
+	interwikiPrefixes = map[string]struct{}{
+		"b":           struct{}{},
+		"c":           struct{}{},
+		"commons":     struct{}{},
+		"d":           struct{}{},
+		"m":           struct{}{},
+		"meta":        struct{}{},
+		"mw":          struct{}{},
+		"n":           struct{}{},
+		"q":           struct{}{},
+		"s":           struct{}{},
+		"species":     struct{}{},
+		"v":           struct{}{},
+		"voy":         struct{}{},
+		"w":           struct{}{},
+		"wikibooks":   struct{}{},
+		"wikidata":    struct{}{},
+		"wikinews":    struct{}{},
+		"wikiquote":   struct{}{},
+		"wikisource":  struct{}{},
+		"wikispecies": struct{}{},
+		"wikiversity": struct{}{},
+		"wikivoyage":  struct{}{},
+		"wikt":        struct{}{},
+		"wiktionary":  struct{}{},
+	}
+)
+
+// Historical is a wiki.Wiki whose pages are the revisions of the Wikipedia articles as of a given time.
+// The links are extracted from the wikitext of the revisions. Links added by templates, like navboxes, aren't included.
+// Pages which didn't exist at that time are reported as missing.
+// Since the 'revisions' module only accepts one page per request when a start time is specified, every title is fetched with a separate request.
+type Historical struct {
+	client *Client
+	at     time.Time
+}
+
+// NewHistorical creates a new instance of Historical which uses client to fetch the revisions of the pages as of at.
+func NewHistorical(client *Client, at time.Time) *Historical {
+	return &Historical{
+		client: client,
+		at:     at.UTC(),
+	}
+}
+
+// FindPages returns the revisions of the pages of the given titles, as of the time of the Historical wiki.
+// If a revision is a redirect, the revision of its target page is returned. The current redirects aren't followed, since they might not have existed at that time.
+// nextBatch is ignored, because the revisions aren't returned in batches.
+// If some of the pages are missing, the found pages are returned together with an errors.PagesNotFound error.
+func (h *Historical) FindPages(titles, nextBatch string) ([]*wiki.Page, error) {
+	var (
+		results  = []*wiki.Page{}
+		missing  = []string{}
+		warnings = errors.Warnings{}
+	)
+
+	for _, title := range strings.Split(titles, "|") {
+		page, w, err := h.revision(title)
+		if err != nil {
+			return nil, err
+		}
+		warnings = warnings.Merge(w)
+
+		// follow one redirect, as it was at that time.
+		if page != nil && page.redirect != "" {
+			page, w, err = h.revision(page.redirect)
+			if err != nil {
+				return nil, err
+			}
+			warnings = warnings.Merge(w)
+		}
+
+		if page == nil || page.redirect != "" {
+			missing = append(missing, title)
+			continue
+		}
+
+		results = append(results, page.Page)
+	}
+
+	return results, partial(missing, warnings)
+}
+
+// RandomPages returns count randomly selected pages from the main namespace.
+// The pages are selected from the current revision of the Wikipedia, so they might not have existed at the time of the Historical wiki.
+func (h *Historical) RandomPages(count int) ([]*wiki.Page, error) {
+	return h.client.RandomPages(count)
+}
+
+// historicalPage is a page parsed from the wikitext of a revision.
+type historicalPage struct {
+	*wiki.Page
+
+	// redirect is the title of the target page, if the revision is a redirect.
+	redirect string
+}
+
+// revision returns the page of the given title as of the time of the Historical wiki.
+// It returns nil if the page doesn't exist, or didn't exist at that time.
+func (h *Historical) revision(title string) (*historicalPage, errors.Warnings, error) {
+	query := map[string]string{
+		"action":        "query",
+		"prop":          "revisions",
+		"format":        responseFormat,
+		"formatversion": responseFormatVersion,
+		"errorformat":   errorFormat,
+		"titles":        title,
+		"rvprop":        "ids|timestamp|size|content",
+		"rvslots":       mainSlot,
+		"rvlimit":       "1",
+		"rvdir":         "older",
+		"rvstart":       h.at.Format(timestampFormat),
+		"utf8":          "true",
+	}
+
+	response, err := h.client.do(context.Background(), query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if response.Errors != nil {
+		return nil, nil, classify(response.Errors)
+	}
+
+	warnings := handleWarnings(response.Warnings)
+	if response.Result == nil || len(response.Result.Pages) == 0 {
+		return nil, warnings, nil
+	}
+
+	page := response.Result.Pages[0]
+	if page.Missing || len(page.Revisions) == 0 {
+		return nil, warnings, nil
+	}
+
+	var (
+		revision = page.Revisions[0]
+		content  string
+	)
+	if slot, ok := revision.Slots[mainSlot]; ok {
+		content = slot.Content
+	}
+
+	if match := redirect.FindStringSubmatch(content); match != nil {
+		return &historicalPage{redirect: normalize(match[1])}, warnings, nil
+	}
+
+	links, categories := parseWikitext(content)
+	return &historicalPage{
+		Page: &wiki.Page{
+			ID:         page.Pageid,
+			Title:      page.Title,
+			Namespace:  page.Ns,
+			Links:      links,
+			Categories: categories,
+			Length:     revision.Size,
+		},
+	}, warnings, nil
+}
+
+// parseWikitext returns the titles of the articles linked from the wikitext, in the order they appear, and the names of the categories that the wikitext assigns.
+func parseWikitext(content string) ([]string, []string) {
+	var (
+		links      = []string{}
+		categories []string
+		seen       = map[string]struct{}{}
+	)
+
+	for _, match := range wikilink.FindAllStringSubmatch(content, -1) {
+		target := strings.TrimSpace(match[1])
+
+		// a leading colon links to a page, instead of assigning a category or embedding a file.
+		colon := strings.HasPrefix(target, ":")
+		target = strings.TrimPrefix(target, ":")
+
+		if i := strings.Index(target, ":"); i >= 0 {
+			prefix := strings.TrimSpace(target[:i])
+			if normalize(prefix) == categoryNamespace && !colon {
+				categories = append(categories, normalize(target[i+1:]))
+				continue
+			}
+
+			// skip the other namespaces, and the interwiki and interlanguage links.
+			if _, ok := namespaces[normalize(prefix)]; ok || strings.HasSuffix(prefix, " talk") || interwiki(prefix, target[i+1:]) {
+				continue
+			}
+		}
+
+		title := normalize(target)
+		if title == "" {
+			continue
+		}
+
+		if _, exists := seen[title]; !exists {
+			seen[title] = struct{}{}
+			links = append(links, title)
+		}
+	}
+
+	return links, categories
+}
+
+// interwiki returns true if prefix is the prefix of an interwiki or interlanguage link to title.
+// The prefix is either a known interwiki prefix, or looks like a language code, which is made of letters and hyphens, and isn't followed by a space.
+// Titles like "2001: A Space Odyssey" and "3:10 to Yuma" are articles.
+func interwiki(prefix, title string) bool {
+	if _, ok := interwikiPrefixes[strings.ToLower(prefix)]; ok {
+		return true
+	}
+
+	if prefix == "" || strings.HasPrefix(title, " ") || strings.HasPrefix(title, "_") {
+		return false
+	}
+
+	for _, r := range prefix {
+		if !unicode.IsLetter(r) && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// normalize converts the target of a wikilink into a page title, by replacing the underscores with spaces, and capitalizing the first letter.
+func normalize(target string) string {
+	title := strings.Join(strings.Fields(strings.Replace(target, "_", " ", -1)), " ")
+	if title == "" {
+		return title
+	}
+
+	r, size := utf8.DecodeRuneInString(title)
+	return string(unicode.ToUpper(r)) + title[size:]
+}
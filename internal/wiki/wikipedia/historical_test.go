@@ -0,0 +1,157 @@
+package wikipedia
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ihcsim/wikiracer/errors"
+	"github.com/ihcsim/wikiracer/internal/wiki"
+)
+
+const historicalTimestamp = "2008-06-01T00:00:00Z"
+
+func TestHistorical(t *testing.T) {
+	client, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.api = mockRevisionsAPI
+
+	at, err := time.Parse(timestampFormat, historicalTimestamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	historical := NewHistorical(client, at)
+
+	var testCases = []struct {
+		name     string
+		titles   string
+		expected []*wiki.Page
+		err      error
+	}{
+		{
+			name:   "Revision",
+			titles: "Mike Tyson",
+			expected: []*wiki.Page{
+				&wiki.Page{ID: 39027, Title: "Mike Tyson", Links: []string{"Boxing", "1984 Summer Olympics", "Cus D'Amato", "Catskill, New York", "Las Vegas"}, Categories: []string{"American male boxers", "1966 births"}, Length: 812},
+			},
+		},
+		{
+			name:   "Redirect",
+			titles: "Iron Mike",
+			expected: []*wiki.Page{
+				&wiki.Page{ID: 39027, Title: "Mike Tyson", Links: []string{"Boxing", "1984 Summer Olympics", "Cus D'Amato", "Catskill, New York", "Las Vegas"}, Categories: []string{"American male boxers", "1966 births"}, Length: 812},
+			},
+		},
+		{
+			name:     "Not Yet Created",
+			titles:   "Mike Tyson|Instagram",
+			expected: []*wiki.Page{&wiki.Page{ID: 39027, Title: "Mike Tyson", Links: []string{"Boxing", "1984 Summer Olympics", "Cus D'Amato", "Catskill, New York", "Las Vegas"}, Categories: []string{"American male boxers", "1966 births"}, Length: 812}},
+			err:      errors.PagesNotFound{Titles: []string{"Instagram"}},
+		},
+		{
+			name:     "Missing Page",
+			titles:   "Missing Page",
+			expected: []*wiki.Page{},
+			err:      errors.PagesNotFound{Titles: []string{"Missing Page"}},
+		},
+	}
+
+	for _, testCase := range testCases {
+		actual, err := historical.FindPages(testCase.titles, "")
+		if !reflect.DeepEqual(err, testCase.err) {
+			t.Errorf("Test case %q failed. Mismatch error.\nExpected: %v\nActual: %v", testCase.name, testCase.err, err)
+		}
+
+		if !reflect.DeepEqual(actual, testCase.expected) {
+			t.Errorf("Test case %q failed. Mismatch pages.\nExpected: %+v\nActual: %+v", testCase.name, testCase.expected, actual)
+		}
+	}
+}
+
+func TestParseWikitext(t *testing.T) {
+	var testCases = []struct {
+		name       string
+		content    string
+		links      []string
+		categories []string
+	}{
+		{name: "Articles", content: "[[Alien]] [[alien|aliens]] [[Alien_(film)#Plot]]", links: []string{"Alien", "Alien (film)"}},
+		{name: "Colon In Title", content: "[[2001: A Space Odyssey]] [[3:10 to Yuma]] [[Alien]] [[fr:Paris]]", links: []string{"2001: A Space Odyssey", "3:10 to Yuma", "Alien"}},
+		{name: "Capitalized Prefix", content: "[[Star Wars: Episode IV]] [[Batman: Arkham Asylum]]", links: []string{"Star Wars: Episode IV", "Batman: Arkham Asylum"}},
+		{name: "Interlanguage", content: "[[fr:Paris]] [[zh-yue:Paris]] [[De:Paris]] [[Alien]]", links: []string{"Alien"}},
+		{name: "Interwiki", content: "[[wikt:alien]] [[Wiktionary: alien]] [[commons:Category:Aliens]] [[Alien]]", links: []string{"Alien"}},
+		{name: "Namespaces", content: "[[File:Alien.jpg|thumb]] [[Talk:Alien]] [[User talk:Ripley]] [[Template:Alien]] [[Alien]]", links: []string{"Alien"}},
+		{name: "Categories", content: "[[Category:1979 films]] [[:Category:Aliens]] [[Alien]]", links: []string{"Alien"}, categories: []string{"1979 films"}},
+	}
+
+	for _, testCase := range testCases {
+		links, categories := parseWikitext(testCase.content)
+		if !reflect.DeepEqual(links, testCase.links) {
+			t.Errorf("Test case %q failed. Mismatch links.\nExpected: %q\nActual: %q", testCase.name, testCase.links, links)
+		}
+
+		if !reflect.DeepEqual(categories, testCase.categories) {
+			t.Errorf("Test case %q failed. Mismatch categories.\nExpected: %q\nActual: %q", testCase.name, testCase.categories, categories)
+		}
+	}
+}
+
+func mockRevisionsAPI(values ...map[string]string) ([]byte, error) {
+	if values[0]["prop"] != "revisions" || values[0]["rvstart"] != historicalTimestamp || values[0]["rvdir"] != "older" {
+		return nil, fmt.Errorf("Unexpected query: %v", values[0])
+	}
+
+	var page map[string]interface{}
+	switch title := values[0]["titles"]; title {
+	case "Mike Tyson":
+		page = revisionPage(39027, title, 812, `'''Michael Gerard Tyson''' is a [[boxing|boxer]] who competed at the [[1984 Summer Olympics#Boxing|1984 Olympics]].
+He was trained by [[Cus_D'Amato]] in [[Catskill, New York]] and [[Cus D'Amato|D'Amato]].
+[[File:Mike Tyson.jpg|thumb|Tyson in [[Las Vegas]]]]
+{{Boxing navbox}}
+[[Category:American male boxers]]
+[[Category:1966 births]]
+[[fr:Mike Tyson]]`)
+
+	case "Iron Mike":
+		page = revisionPage(1234, title, 27, "#REDIRECT [[Mike_Tyson]]")
+
+	case "Instagram":
+		// the page exists, but it had no revisions before the timestamp.
+		page = map[string]interface{}{"pageid": 31591547, "ns": 0, "title": title}
+
+	default:
+		page = map[string]interface{}{"ns": 0, "title": title, "missing": true}
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"batchcomplete": true,
+		"query": map[string]interface{}{
+			"pages": []interface{}{page},
+		},
+	})
+}
+
+func revisionPage(id int, title string, size int, content string) map[string]interface{} {
+	return map[string]interface{}{
+		"pageid": id,
+		"ns":     0,
+		"title":  title,
+		"revisions": []interface{}{
+			map[string]interface{}{
+				"revid":     217044350,
+				"timestamp": "2008-05-30T12:00:00Z",
+				"size":      size,
+				"slots": map[string]interface{}{
+					mainSlot: map[string]interface{}{
+						"contentmodel": "wikitext",
+						"content":      content,
+					},
+				},
+			},
+		},
+	}
+}
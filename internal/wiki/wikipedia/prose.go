@@ -0,0 +1,292 @@
+package wikipedia
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/ihcsim/wikiracer/errors"
+	"github.com/ihcsim/wikiracer/internal/wiki"
+)
+
+const (
+	articlePath = "/wiki/"
+
+	// the error code used by the 'parse' action when the page doesn't exist.
+	missingTitleCode = "missingtitle"
+)
+
+var (
+	// excludedElements are the elements whose links a reader doesn't see as part of the article prose.
+	excludedElements = map[string]struct{}{
+		"table":  struct{}{},
+		"figure": struct{}{},
+		"style":  struct{}{},
+		"script": struct{}{},
+	}
+
+	// excludedClasses are the classes of the templates that wrap navboxes, infoboxes, hatnotes, thumbnails and references.
+	excludedClasses = map[string]struct{}{
+		"ambox":            struct{}{},
+		"hatnote":          struct{}{},
+		"infobox":          struct{}{},
+		"metadata":         struct{}{},
+		"mw-editsection":   struct{}{},
+		"navbox":           struct{}{},
+		"noprint":          struct{}{},
+		"reference":        struct{}{},
+		"reflist":          struct{}{},
+		"shortdescription": struct{}{},
+		"sidebar":          struct{}{},
+		"thumb":            struct{}{},
+		"toc":              struct{}{},
+		"vertical-navbox":  struct{}{},
+	}
+
+	// namespaces are the prefixes of the titles of pages which aren't articles.
+	namespaces = map[string]struct{}{
+		"Category":  struct{}{},
+		"Draft":     struct{}{},
+		"File":      struct{}{},
+		"Help":      struct{}{},
+		"Image":     struct{}{},
+		"Media":     struct{}{},
+		"MediaWiki": struct{}{},
+		"Module":    struct{}{},
+		"Portal":    struct{}{},
+		"Special":   struct{}{},
+		"Talk":      struct{}{},
+		"Template":  struct{}{},
+		"TimedText": struct{}{},
+		"User":      struct{}{},
+		"Wikipedia": struct{}{},
+	}
+)
+
+// Prose is a wiki.Wiki whose pages only contain the links that appear in the prose of the articles.
+// Unlike the Client, which returns all the links of a page, the links in navboxes, infoboxes, tables, hatnotes and references are ignored, so that the paths found with Prose can be followed by a human reader.
+// Since the 'parse' action only accepts one page per request, every title is fetched with a separate request.
+type Prose struct {
+	client *Client
+	lead   bool
+}
+
+// NewProse creates a new instance of Prose which uses client to communicate with the Wikipedia.
+// If lead is true, only the links in the lead section of the articles are returned.
+func NewProse(client *Client, lead bool) *Prose {
+	return &Prose{
+		client: client,
+		lead:   lead,
+	}
+}
+
+// FindPages returns the pages of the given titles, with the links that appear in their prose.
+// nextBatch is ignored, because the parsed pages aren't returned in batches.
+// If some of the pages are missing, the found pages are returned together with an errors.PagesNotFound error.
+func (p *Prose) FindPages(titles, nextBatch string) ([]*wiki.Page, error) {
+	var (
+		results  = []*wiki.Page{}
+		missing  = []string{}
+		warnings = errors.Warnings{}
+	)
+
+	for _, title := range strings.Split(titles, "|") {
+		response, err := p.parse(title)
+		if err != nil {
+			return nil, err
+		}
+
+		if response.Errors != nil {
+			if len(response.Errors) == 1 && response.Errors[0].Code == missingTitleCode {
+				missing = append(missing, title)
+				continue
+			}
+			return nil, classify(response.Errors)
+		}
+		warnings = warnings.Merge(handleWarnings(response.Warnings))
+
+		if response.Parsed == nil {
+			missing = append(missing, title)
+			continue
+		}
+
+		links, err := proseLinks(response.Parsed.Text)
+		if err != nil {
+			return nil, err
+		}
+
+		var categories []string
+		for _, category := range response.Parsed.Categories {
+			if !category.Hidden {
+				categories = append(categories, strings.Replace(category.Category, "_", " ", -1))
+			}
+		}
+
+		_, disambiguation := response.Parsed.Properties[disambiguationProp]
+		results = append(results, &wiki.Page{
+			ID:             response.Parsed.Pageid,
+			Title:          response.Parsed.Title,
+			Links:          links,
+			Categories:     categories,
+			Disambiguation: disambiguation,
+		})
+	}
+
+	return results, partial(missing, warnings)
+}
+
+// RandomPages returns count randomly selected pages from the main namespace.
+func (p *Prose) RandomPages(count int) ([]*wiki.Page, error) {
+	return p.client.RandomPages(count)
+}
+
+func (p *Prose) parse(title string) (*Response, error) {
+	query := map[string]string{
+		"action":             "parse",
+		"prop":               "text",
+		"format":             responseFormat,
+		"formatversion":      responseFormatVersion,
+		"errorformat":        errorFormat,
+		"page":               title,
+		"redirects":          "true",
+		"disableeditsection": "true",
+		"disabletoc":         "true",
+		"utf8":               "true",
+	}
+
+	if p.lead {
+		query["section"] = "0"
+	}
+
+	if p.client.metadata {
+		query["prop"] = "text|categories|properties"
+	}
+
+	return p.client.do(context.Background(), query)
+}
+
+// proseLinks returns the titles of the articles linked from the paragraphs of the given HTML, in the order they appear.
+// Links which are nested in tables, figures and the excluded templates are ignored, even if they are in a paragraph.
+func proseLinks(text string) ([]string, error) {
+	decoder := xml.NewDecoder(strings.NewReader(text))
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+
+	var (
+		links    = []string{}
+		seen     = map[string]struct{}{}
+		stack    = []string{}
+		excluded = 0
+		prose    = 0
+	)
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			name := strings.ToLower(element.Name.Local)
+			switch {
+			case exclude(element):
+				excluded++
+				name = "!" + name
+			case name == "p":
+				prose++
+			case name == "a" && excluded == 0 && prose > 0:
+				if title, ok := articleTitle(attr(element, "href")); ok {
+					if _, exists := seen[title]; !exists {
+						seen[title] = struct{}{}
+						links = append(links, title)
+					}
+				}
+			}
+			stack = append(stack, name)
+
+		case xml.EndElement:
+			// in non-strict mode, the decoder doesn't guarantee that the end elements match the start elements.
+			name := strings.ToLower(element.Name.Local)
+			for len(stack) > 0 {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+
+				switch {
+				case strings.HasPrefix(top, "!"):
+					excluded--
+				case top == "p":
+					prose--
+				}
+
+				if strings.TrimPrefix(top, "!") == name {
+					break
+				}
+			}
+		}
+	}
+
+	return links, nil
+}
+
+// exclude returns true if the links nested in element aren't part of the article prose.
+func exclude(element xml.StartElement) bool {
+	if _, ok := excludedElements[strings.ToLower(element.Name.Local)]; ok {
+		return true
+	}
+
+	if attr(element, "role") == "navigation" {
+		return true
+	}
+
+	for _, class := range strings.Fields(attr(element, "class")) {
+		if _, ok := excludedClasses[class]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func attr(element xml.StartElement, name string) string {
+	for _, a := range element.Attr {
+		if strings.ToLower(a.Name.Local) == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// articleTitle returns the title of the article that href points to.
+// It returns false if href points to a non-existent page, an external site, a fragment of the current page, or a page which isn't an article.
+func articleTitle(href string) (string, bool) {
+	if !strings.HasPrefix(href, articlePath) {
+		return "", false
+	}
+
+	path := strings.TrimPrefix(href, articlePath)
+	if i := strings.Index(path, "#"); i >= 0 {
+		path = path[:i]
+	}
+
+	title, err := url.PathUnescape(path)
+	if err != nil || title == "" {
+		return "", false
+	}
+	title = strings.Replace(title, "_", " ", -1)
+
+	if i := strings.Index(title, ":"); i >= 0 {
+		prefix := title[:i]
+		if _, ok := namespaces[prefix]; ok || strings.HasSuffix(prefix, " talk") {
+			return "", false
+		}
+	}
+
+	return title, true
+}
@@ -0,0 +1,130 @@
+package wikipedia
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/ihcsim/wikiracer/errors"
+	"github.com/ihcsim/wikiracer/internal/wiki"
+)
+
+const (
+	leadSection = `
+<div class="mw-parser-output">
+<div class="shortdescription nomobile noexcerpt noprint searchaux" style="display:none">American boxer</div>
+<div role="note" class="hatnote navigation-not-searchable">For other people named Mike Tyson, see <a href="/wiki/Mike_Tyson_(disambiguation)" title="Mike Tyson (disambiguation)">Mike Tyson (disambiguation)</a>.</div>
+<table class="infobox vcard"><tbody><tr><td><p><a href="/wiki/Catskill,_New_York" title="Catskill, New York">Catskill</a></p></td></tr></tbody></table>
+<p><b>Michael Gerard Tyson</b> is an American former professional <a href="/wiki/Boxing" title="Boxing">boxer</a> who competed at the <a href="/wiki/1984_Summer_Olympics#Boxing" title="1984 Summer Olympics">1984&nbsp;Summer Olympics</a> trials.<sup id="cite_ref-1" class="reference"><a href="#cite_note-1">[1]</a></sup> He was trained by <a href="/w/index.php?title=Unknown_Trainer&amp;action=edit&amp;redlink=1" class="new" title="Unknown Trainer (page does not exist)">an unknown trainer</a> and <a href="/wiki/Cus_D%27Amato" title="Cus D&#39;Amato">Cus D'Amato</a>.<br>
+He fought <a href="/wiki/Boxing" title="Boxing">again</a>.</p>
+<div class="thumb tright"><div class="thumbinner"><a href="/wiki/File:Mike_Tyson.jpg" class="image"><img src="Mike_Tyson.jpg" /></a><div class="thumbcaption"><p><a href="/wiki/Las_Vegas" title="Las Vegas">Las Vegas</a></p></div></div></div>
+<p>See <a href="/wiki/Category:Boxers" title="Category:Boxers">boxers</a> and the <a href="https://www.boxrec.com" class="external">BoxRec</a> record.</p>
+</div>`
+
+	bodySection = `
+<div class="mw-parser-output">
+<h2><span class="mw-headline" id="Career">Career</span></h2>
+<p>He fought <a href="/wiki/Evander_Holyfield" title="Evander Holyfield">Evander Holyfield</a> in <a href="/wiki/Las_Vegas" title="Las Vegas">Las Vegas</a>.</p>
+<ul><li><a href="/wiki/Aaron_Pryor" title="Aaron Pryor">Aaron Pryor</a></li></ul>
+<div role="navigation" class="navbox"><table><tr><td><p><a href="/wiki/Abdullah_the_Butcher" title="Abdullah the Butcher">Abdullah the Butcher</a></p></td></tr></table></div>
+<div class="reflist"><ol class="references"><li><p><a href="/wiki/20/20_(US_television_show)" title="20/20 (US television show)">20/20</a></p></li></ol></div>
+</div>`
+)
+
+func TestProse(t *testing.T) {
+	client, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.api = mockParseAPI
+
+	var testCases = []struct {
+		name     string
+		lead     bool
+		titles   string
+		expected []*wiki.Page
+		err      error
+	}{
+		{
+			name:   "All Sections",
+			titles: "Mike Tyson",
+			expected: []*wiki.Page{
+				&wiki.Page{ID: 39027, Title: "Mike Tyson", Links: []string{"Boxing", "1984 Summer Olympics", "Cus D'Amato", "Evander Holyfield", "Las Vegas"}},
+			},
+		},
+		{
+			name:   "Lead Section",
+			lead:   true,
+			titles: "Mike Tyson",
+			expected: []*wiki.Page{
+				&wiki.Page{ID: 39027, Title: "Mike Tyson", Links: []string{"Boxing", "1984 Summer Olympics", "Cus D'Amato"}},
+			},
+		},
+		{
+			name:   "Redirect",
+			lead:   true,
+			titles: "Iron Mike",
+			expected: []*wiki.Page{
+				&wiki.Page{ID: 39027, Title: "Mike Tyson", Links: []string{"Boxing", "1984 Summer Olympics", "Cus D'Amato"}},
+			},
+		},
+		{
+			name:   "Missing Page",
+			lead:   true,
+			titles: "Mike Tyson|Missing Page",
+			expected: []*wiki.Page{
+				&wiki.Page{ID: 39027, Title: "Mike Tyson", Links: []string{"Boxing", "1984 Summer Olympics", "Cus D'Amato"}},
+			},
+			err: errors.PagesNotFound{Titles: []string{"Missing Page"}},
+		},
+		{
+			name:   "Error",
+			titles: invalidParam,
+			err:    &BadRequest{Code: "invalidtitle", Msg: "Bad title \"invalidParam\".\n"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		prose := NewProse(client, testCase.lead)
+		actual, err := prose.FindPages(testCase.titles, "")
+		if !reflect.DeepEqual(err, testCase.err) {
+			t.Errorf("Test case %q failed. Mismatch error.\nExpected: %v\nActual: %v", testCase.name, testCase.err, err)
+		}
+
+		if testCase.err != nil && testCase.expected == nil {
+			continue
+		}
+
+		if !reflect.DeepEqual(actual, testCase.expected) {
+			t.Errorf("Test case %q failed. Mismatch pages.\nExpected: %+v\nActual: %+v", testCase.name, testCase.expected, actual)
+		}
+	}
+}
+
+func mockParseAPI(values ...map[string]string) ([]byte, error) {
+	if values[0]["action"] != "parse" {
+		return nil, fmt.Errorf("Unexpected query: %v", values[0])
+	}
+
+	switch values[0]["page"] {
+	case "Mike Tyson", "Iron Mike":
+		text := leadSection
+		if values[0]["section"] != "0" {
+			text += bodySection
+		}
+
+		return json.Marshal(map[string]interface{}{
+			"parse": map[string]interface{}{
+				"title":  "Mike Tyson",
+				"pageid": 39027,
+				"text":   text,
+			},
+		})
+
+	case invalidParam:
+		return []byte(`{"errors": [{"code": "invalidtitle", "text": "Bad title \"invalidParam\".", "module": "main"}]}`), nil
+	}
+
+	return []byte(`{"errors": [{"code": "missingtitle", "text": "The page you specified doesn't exist.", "module": "parse"}]}`), nil
+}
@@ -1,22 +1,33 @@
 package wikipedia
 
+import (
+	"net/url"
+	"strings"
+)
+
 // Response is the raw JSON response from the Wikipedia.
 type Response struct {
 	// Next, if presents, points to the next batch of result.
-	Next *NextBatch `json:"continue",omitempty`
+	Next *NextBatch `json:"continue,omitempty"`
 
 	// Result contains pages data received from the Wikipedia. If an URL redirect was performed by wikipedia before the result is retrieved, a 'Redirect' block will be included.
-	Result *Query `json:"query",omitempty`
+	Result *Query `json:"query,omitempty"`
+
+	// Parsed contains the parsed content of a page, as returned by the 'parse' action.
+	Parsed *Parsed `json:"parse,omitempty"`
+
+	// Login is the result of the 'login' action.
+	Login *Login `json:",omitempty"`
 
 	// Batchcomplete is true if there are no more subsequent batches.
 	// Otherwise, it's omitted.
-	Batchcomplete bool `json:"",omitempty`
+	Batchcomplete bool `json:",omitempty"`
 
 	// Errors is a list of errors returned by the Wikipedia.
-	Errors []*ResponseError `json:"",omitempty`
+	Errors []*ResponseError `json:",omitempty"`
 
 	// Warnings is a list of warnings returned by the Wikipedia.
-	Warnings *ResponseWarnings `json:"",omitempty`
+	Warnings []*ResponseWarning `json:",omitempty"`
 }
 
 // NextBatch points to the next batch of result.
@@ -25,18 +36,82 @@ type NextBatch struct {
 	// Plcontinue is the title of the first page of the next batch of result.
 	Plcontinue string
 
+	// Clcontinue points to the next batch of categories, if the categories are requested.
+	Clcontinue string
+
 	// Continue
 	Continue string
 }
 
+// Token encodes the next batch into an opaque value, which can be used to resume the query.
+func (n *NextBatch) Token() string {
+	values := url.Values{}
+	for key, value := range map[string]string{"continue": n.Continue, "plcontinue": n.Plcontinue, "clcontinue": n.Clcontinue} {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+
+	return values.Encode()
+}
+
+// continuation decodes a token returned by NextBatch.Token() into the query parameters that resume the query.
+// For backward compatibility, a token which isn't encoded is used as the 'plcontinue' value.
+func continuation(token string) map[string]string {
+	params := map[string]string{}
+	if token == "" {
+		return params
+	}
+
+	values, err := url.ParseQuery(token)
+	if err == nil {
+		for _, key := range []string{"continue", "plcontinue", "clcontinue"} {
+			if value := values.Get(key); value != "" {
+				params[key] = value
+			}
+		}
+	}
+
+	if len(params) == 0 {
+		params["plcontinue"] = token
+	}
+
+	return params
+}
+
 // Query contains pages data received from the Wikipedia.
 type Query struct {
 	// Redirects represents any URL redirect that Wikipedia performed before the result is retrieved. Wikipedia performs URL redirects for certain pages that may be known by multiple titles.
 	// For more information on how 'redirect' works, refer to https://en.wikipedia.org/wiki/Help:Redirect
-	Redirects []*Redirect `json:"",omitempty`
+	Redirects []*Redirect `json:",omitempty"`
 
 	// Pages is the batch of pages received from the Wikipedia.
 	Pages []*Page
+
+	// Random is the list of randomly selected pages returned by the 'random' list module.
+	Random []*RandomPage
+
+	// Tokens are the tokens returned by the 'tokens' meta module.
+	Tokens *Tokens `json:",omitempty"`
+}
+
+// Tokens are the tokens required by the actions which change the state of the session.
+type Tokens struct {
+	// Logintoken is the token required by the 'login' action.
+	Logintoken string
+}
+
+// Login is the result of the 'login' action.
+// For more information, refer to https://www.mediawiki.org/wiki/API:Login
+type Login struct {
+	// Result is 'Success' if the login succeeded.
+	Result string
+
+	// Reason explains why the login failed.
+	Reason string `json:",omitempty"`
+
+	// Lgusername is the name of the logged in user.
+	Lgusername string `json:",omitempty"`
 }
 
 // Redirect represents a single URL redirect performed by Wikipedia. Wikipedia performs URL redirects for certain pages that may be known by multiple titles.
@@ -62,8 +137,87 @@ type Page struct {
 	// Links is the collection of links found in the page.
 	Links []Link
 
+	// Categories is the collection of categories the page belongs to.
+	Categories []Category `json:",omitempty"`
+
+	// Length is the size of the page's latest revision in bytes.
+	Length int `json:",omitempty"`
+
+	// Pageprops are the page properties, such as 'disambiguation', defined in the page content.
+	Pageprops map[string]string `json:",omitempty"`
+
+	// Revisions is the collection of revisions of the page, if they are requested.
+	Revisions []*Revision `json:",omitempty"`
+
 	// Missing is true if there is no page with the given title.
-	Missing bool `json:'',omitempty`
+	Missing bool `json:",omitempty"`
+}
+
+// Revision is a single revision of a page, as returned by the 'revisions' prop module.
+type Revision struct {
+	// Revid is the revision ID.
+	Revid int
+
+	// Timestamp is the time when the revision was saved.
+	Timestamp string
+
+	// Size is the size of the revision in bytes.
+	Size int `json:",omitempty"`
+
+	// Slots contains the content of the revision, keyed by the slot role, such as 'main'.
+	Slots map[string]*Slot `json:",omitempty"`
+}
+
+// Slot is the content of a revision slot.
+type Slot struct {
+	// Contentmodel is the model of the content, such as 'wikitext'.
+	Contentmodel string
+
+	// Content is the raw content of the slot.
+	Content string
+}
+
+// RandomPage is a randomly selected page as returned by the 'random' list module.
+type RandomPage struct {
+	// ID is the page ID.
+	ID int
+
+	// Ns is the namespace where the page belongs.
+	Ns int
+
+	// Title is the page title.
+	Title string
+}
+
+// Parsed is a page as rendered by the 'parse' action.
+// For more information, refer to https://www.mediawiki.org/wiki/API:Parsing_wikitext
+type Parsed struct {
+	// Pageid is the page ID.
+	Pageid int
+
+	// Title is the page title. If the requested title is a redirect, this is the title of the target page.
+	Title string
+
+	// Redirects represents any URL redirect that Wikipedia performed before the page is parsed.
+	Redirects []*Redirect `json:",omitempty"`
+
+	// Text is the HTML of the page, or of the requested section.
+	Text string
+
+	// Categories is the collection of categories the page belongs to.
+	Categories []*ParsedCategory `json:",omitempty"`
+
+	// Properties are the page properties, such as 'disambiguation', defined in the page content.
+	Properties map[string]string `json:",omitempty"`
+}
+
+// ParsedCategory is a category that a parsed page belongs to.
+type ParsedCategory struct {
+	// Category is the name of the category, without its namespace prefix. Spaces are replaced by underscores.
+	Category string
+
+	// Hidden is true if the category is a maintenance category, which isn't shown to readers.
+	Hidden bool `json:",omitempty"`
 }
 
 // Link is a link to another page.
@@ -75,6 +229,23 @@ type Link struct {
 	Title string
 }
 
+// Category is a category that a page belongs to.
+type Category struct {
+	// Ns is the namespace of the category.
+	Ns int
+
+	// Title is the title of the category, including its namespace prefix.
+	Title string
+}
+
+// Name returns the title of the category without its namespace prefix.
+func (c Category) Name() string {
+	if i := strings.Index(c.Title, ":"); i >= 0 {
+		return c.Title[i+1:]
+	}
+	return c.Title
+}
+
 // ResponseError is a error returned by the Wikipedia.
 type ResponseError struct {
 	// Code is the error code.
@@ -82,25 +253,29 @@ type ResponseError struct {
 
 	// Text provides the error message.
 	Text string
-}
 
-// ResponseWarnings are warnings returned by the Wikipedia.
-type ResponseWarnings struct {
-	// Main is the main warning.
-	Main *MainWarning `json:"",omitempty`
+	// Module is the name of the API module that reported the error.
+	Module string
 
-	// Query is query-related warning
-	Query *QueryWarning `json:"",omitempty`
+	// Data provides the details of some errors, like the replication lag of a 'maxlag' error.
+	Data *ErrorData `json:",omitempty"`
 }
 
-// MainWarning is the warning returned by the Wikipedia 'main' module.
-type MainWarning struct {
-	// Warnings are warning messages.
-	Warnings string
+// ErrorData provides the details of an error returned by the Wikipedia.
+type ErrorData struct {
+	// Lag is the replication lag of the databases, in seconds, reported by a 'maxlag' error.
+	Lag float64 `json:",omitempty"`
 }
 
-// QueryWarning is the query-related warning
-type QueryWarning struct {
-	// Warnings are warning messages.
-	Warnings string
+// ResponseWarning is a warning returned by the Wikipedia.
+// Warnings don't invalidate the results of the query.
+type ResponseWarning struct {
+	// Code is the warning code.
+	Code string
+
+	// Text provides the warning message.
+	Text string
+
+	// Module is the name of the API module that reported the warning.
+	Module string
 }
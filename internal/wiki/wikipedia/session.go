@@ -0,0 +1,197 @@
+package wikipedia
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/ihcsim/wikiracer/log"
+)
+
+const (
+	// the environment variables that hold the bot password credentials.
+	envUsername    = "WIKIRACER_BOT_USERNAME"
+	envPassword    = "WIKIRACER_BOT_PASSWORD"
+	envCredentials = "WIKIRACER_BOT_CREDENTIALS"
+
+	loginSuccess = "Success"
+)
+
+// the error codes used by the MediaWiki API when the 'assert' and 'assertuser' checks fail, usually because the session expired.
+var sessionExpiredCodes = map[string]struct{}{
+	"assertuserfailed":      struct{}{},
+	"assertnameduserfailed": struct{}{},
+}
+
+// Credentials are the bot password credentials used to log in to the wiki.
+// For more information on bot passwords, refer to https://www.mediawiki.org/wiki/Manual:Bot_passwords
+type Credentials struct {
+	// Username is the login name of the bot password, in the form of 'User@BotName'.
+	Username string `json:"username"`
+
+	// Password is the bot password.
+	Password string `json:"password"`
+}
+
+// user returns the name of the user that owns the bot password.
+func (c *Credentials) user() string {
+	if i := strings.Index(c.Username, "@"); i >= 0 {
+		return c.Username[:i]
+	}
+	return c.Username
+}
+
+// CredentialsFromEnv returns the credentials specified by the WIKIRACER_BOT_USERNAME and WIKIRACER_BOT_PASSWORD environment variables.
+// If they aren't set, the credentials are read from the JSON file specified by the WIKIRACER_BOT_CREDENTIALS environment variable.
+// It returns nil if none of the environment variables are set.
+func CredentialsFromEnv() (*Credentials, error) {
+	username, password := os.Getenv(envUsername), os.Getenv(envPassword)
+	if username != "" || password != "" {
+		return &Credentials{Username: username, Password: password}, nil
+	}
+
+	if path := os.Getenv(envCredentials); path != "" {
+		return CredentialsFromFile(path)
+	}
+
+	return nil, nil
+}
+
+// CredentialsFromFile reads the credentials from a JSON file, like {"username": "User@BotName", "password": "..."}.
+func CredentialsFromFile(path string) (*Credentials, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var credentials Credentials
+	if err := json.Unmarshal(content, &credentials); err != nil {
+		return nil, err
+	}
+
+	return &credentials, nil
+}
+
+// WithEndpoint configures the Client to communicate with the api.php of another wiki, instead of the English Wikipedia.
+func WithEndpoint(url string) Option {
+	return func(c *Client) {
+		c.endpoint = url
+	}
+}
+
+// WithCredentials configures the Client to log in with the given bot password.
+// Once logged in, every request asserts that it's made by the user, and the Client logs in again when the session expires.
+func WithCredentials(credentials Credentials) Option {
+	return func(c *Client) {
+		c.credentials = &credentials
+	}
+}
+
+// login starts a new session, unless another session was started since the given generation.
+func (c *Client) login(generation int) error {
+	c.session.mux.Lock()
+	defer c.session.mux.Unlock()
+
+	if c.session.generation != generation {
+		return nil
+	}
+
+	token, err := c.loginToken()
+	if err != nil {
+		return err
+	}
+
+	content, err := c.api(map[string]string{
+		"action":        "login",
+		"format":        responseFormat,
+		"formatversion": responseFormatVersion,
+		"errorformat":   errorFormat,
+		"lgname":        c.credentials.Username,
+		"lgpassword":    c.credentials.Password,
+		"lgtoken":       token,
+	})
+	if err != nil {
+		return err
+	}
+
+	var response Response
+	if err := json.Unmarshal(content, &response); err != nil {
+		return err
+	}
+
+	if response.Errors != nil {
+		return classify(response.Errors)
+	}
+
+	if response.Login == nil || response.Login.Result != loginSuccess {
+		failed := &LoginFailed{}
+		if response.Login != nil {
+			failed.Result, failed.Reason = response.Login.Result, response.Login.Reason
+		}
+		return failed
+	}
+	c.session.generation++
+
+	log.Instance().Debugf("Logged in. User=%q Generation=%d", c.credentials.user(), c.session.generation)
+	return nil
+}
+
+// loginToken fetches the token required by the 'login' action.
+// The token is tied to the session cookie set by the response.
+func (c *Client) loginToken() (string, error) {
+	content, err := c.api(map[string]string{
+		"action":        "query",
+		"meta":          "tokens",
+		"type":          "login",
+		"format":        responseFormat,
+		"formatversion": responseFormatVersion,
+		"errorformat":   errorFormat,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var response Response
+	if err := json.Unmarshal(content, &response); err != nil {
+		return "", err
+	}
+
+	if response.Errors != nil {
+		return "", classify(response.Errors)
+	}
+
+	if response.Result == nil || response.Result.Tokens == nil {
+		return "", &LoginFailed{Result: "NoToken"}
+	}
+
+	return response.Result.Tokens.Logintoken, nil
+}
+
+// currentSession returns the generation of the current session.
+func (c *Client) currentSession() int {
+	c.session.mux.Lock()
+	defer c.session.mux.Unlock()
+
+	return c.session.generation
+}
+
+// assert adds the parameters which assert that the request is made by the logged in user.
+func (c *Client) assert(query map[string]string) {
+	if c.credentials == nil {
+		return
+	}
+
+	query["assert"] = "user"
+	query["assertuser"] = c.credentials.user()
+}
+
+// expired returns true if the errors indicate that the session has expired.
+func expired(errors []*ResponseError) bool {
+	for _, e := range errors {
+		if _, ok := sessionExpiredCodes[e.Code]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,200 @@
+package wikipedia
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/ihcsim/wikiracer/internal/wiki"
+	"github.com/ihcsim/wikiracer/log"
+)
+
+const (
+	botUsername = "Racer@wikiracer"
+	botPassword = "s3cr3t"
+	loginToken  = "b6a4c1d2+\\"
+)
+
+func TestSession(t *testing.T) {
+	log.Instance().SetBackend(log.QuietBackend)
+
+	server := &stubWiki{sessions: map[string]struct{}{}}
+	stub := httptest.NewServer(server)
+	defer stub.Close()
+
+	t.Run("Login", func(t *testing.T) {
+		client, err := NewClient(WithEndpoint(stub.URL), WithCredentials(Credentials{Username: botUsername, Password: botPassword}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		actual, err := client.FindPages("Mike Tyson", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []*wiki.Page{&wiki.Page{ID: 39027, Title: "Mike Tyson", Links: []string{"1984 Summer Olympics"}}}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Mismatch pages.\nExpected: %+v\nActual: %+v", expected, actual)
+		}
+
+		t.Run("Session Expired", func(t *testing.T) {
+			logins := server.count()
+			server.expire()
+
+			if _, err := client.FindPages("Mike Tyson", ""); err != nil {
+				t.Fatal(err)
+			}
+
+			if actual := server.count() - logins; actual != 1 {
+				t.Errorf("Mismatch logins count. Expected 1. Actual %d", actual)
+			}
+		})
+	})
+
+	t.Run("Anonymous", func(t *testing.T) {
+		client, err := NewClient(WithEndpoint(stub.URL))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := client.FindPages("Mike Tyson", ""); err == nil {
+			t.Error("Expected error didn't occur")
+		}
+	})
+
+	t.Run("Wrong Password", func(t *testing.T) {
+		_, err := NewClient(WithEndpoint(stub.URL), WithCredentials(Credentials{Username: botUsername, Password: "wrong"}))
+		expected := &LoginFailed{Result: "Failed", Reason: "Incorrect username or password entered."}
+		if !reflect.DeepEqual(err, expected) {
+			t.Errorf("Mismatch error.\nExpected: %v\nActual: %v", expected, err)
+		}
+	})
+}
+
+func TestCredentialsFromEnv(t *testing.T) {
+	defer os.Unsetenv(envUsername)
+	defer os.Unsetenv(envPassword)
+	defer os.Unsetenv(envCredentials)
+
+	t.Run("Unset", func(t *testing.T) {
+		actual, err := CredentialsFromEnv()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if actual != nil {
+			t.Errorf("Expected no credentials. Actual %+v", actual)
+		}
+	})
+
+	t.Run("File", func(t *testing.T) {
+		file, err := ioutil.TempFile("", "credentials")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(file.Name())
+
+		if _, err := fmt.Fprintf(file, `{"username": %q, "password": %q}`, botUsername, botPassword); err != nil {
+			t.Fatal(err)
+		}
+		file.Close()
+
+		os.Setenv(envCredentials, file.Name())
+		actual, err := CredentialsFromEnv()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := &Credentials{Username: botUsername, Password: botPassword}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Mismatch credentials.\nExpected: %+v\nActual: %+v", expected, actual)
+		}
+	})
+
+	t.Run("Variables", func(t *testing.T) {
+		os.Setenv(envUsername, "Other@wikiracer")
+		os.Setenv(envPassword, botPassword)
+		actual, err := CredentialsFromEnv()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := &Credentials{Username: "Other@wikiracer", Password: botPassword}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Mismatch credentials.\nExpected: %+v\nActual: %+v", expected, actual)
+		}
+	})
+}
+
+// stubWiki is a minimal api.php which supports the bot password login flow, and the 'assert' parameters.
+type stubWiki struct {
+	mux      sync.Mutex
+	sessions map[string]struct{}
+	logins   int
+}
+
+func (s *stubWiki) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if err := req.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	switch req.Form.Get("action") {
+	case "login":
+		if req.Form.Get("lgtoken") != loginToken || req.Form.Get("lgname") != botUsername || req.Form.Get("lgpassword") != botPassword {
+			fmt.Fprint(w, `{"login": {"result": "Failed", "reason": "Incorrect username or password entered."}}`)
+			return
+		}
+
+		s.logins++
+		session := strconv.Itoa(s.logins)
+		s.sessions[session] = struct{}{}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: session})
+		fmt.Fprint(w, `{"login": {"result": "Success", "lgusername": "Racer"}}`)
+
+	case "query":
+		if req.Form.Get("meta") == "tokens" && req.Form.Get("type") == "login" {
+			fmt.Fprintf(w, `{"batchcomplete": true, "query": {"tokens": {"logintoken": %q}}}`, loginToken)
+			return
+		}
+
+		var loggedIn bool
+		if cookie, err := req.Cookie("session"); err == nil {
+			_, loggedIn = s.sessions[cookie.Value]
+		}
+
+		if req.Form.Get("assert") != "user" || req.Form.Get("assertuser") != "Racer" || !loggedIn {
+			fmt.Fprint(w, `{"errors": [{"code": "assertuserfailed", "text": "You are no longer logged in, so the action could not be completed.", "module": "main"}]}`)
+			return
+		}
+
+		fmt.Fprint(w, `{"batchcomplete": true, "query": {"pages": [{"pageid": 39027, "ns": 0, "title": "Mike Tyson", "links": [{"ns": 0, "title": "1984 Summer Olympics"}]}]}}`)
+
+	default:
+		http.Error(w, "unknown action", http.StatusBadRequest)
+	}
+}
+
+func (s *stubWiki) expire() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.sessions = map[string]struct{}{}
+}
+
+func (s *stubWiki) count() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.logins
+}
@@ -0,0 +1,190 @@
+package wikipedia
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ihcsim/wikiracer/log"
+)
+
+const (
+	// the error code used by the MediaWiki API when the replication lag of its databases exceeds the maxlag parameter.
+	// For more information, refer to https://www.mediawiki.org/wiki/Manual:Maxlag_parameter
+	maxLagCode = "maxlag"
+
+	// maxLagBackoff bounds the time to wait before retrying a request rejected because of replication lag.
+	maxLagBackoff = 30 * time.Second
+)
+
+// DefaultThrottle is a throttling policy which honours the maxlag recommendations of Wikimedia, and adapts the number of in-flight requests to the load of the servers.
+var DefaultThrottle = Throttle{
+	MaxLag:           5 * time.Second,
+	MaxLagRetries:    3,
+	RateLimitRetries: 3,
+	MinConcurrency:   1,
+	MaxConcurrency:   16,
+	LatencyThreshold: 2 * time.Second,
+}
+
+// Throttle is the policy that the Client uses to reduce its load on the wiki when the wiki is busy.
+// The zero value disables throttling.
+type Throttle struct {
+	// MaxLag is sent as the maxlag parameter of every request. The wiki rejects the requests when the replication lag of its databases exceeds MaxLag.
+	// A rejected request is retried after the reported lag, up to MaxLagRetries times.
+	// The maxlag parameter isn't sent if it's zero.
+	MaxLag time.Duration
+
+	// MaxLagRetries is the number of times a request rejected because of replication lag is retried.
+	MaxLagRetries int
+
+	// RateLimitRetries is the number of times a request rejected with the 429 status code is retried, after a cool down.
+	// Once the retries are exhausted, the request fails with a RateLimited error.
+	RateLimitRetries int
+
+	// MinConcurrency and MaxConcurrency bound the number of in-flight requests.
+	// The limit starts at MaxConcurrency. It's halved when a request fails, or takes longer than LatencyThreshold, and it grows back by one request per round trip otherwise.
+	// The number of in-flight requests isn't limited if MaxConcurrency is zero.
+	MinConcurrency int
+	MaxConcurrency int
+
+	// LatencyThreshold is the latency above which a request is considered a sign of an overloaded wiki.
+	// Latency is ignored if it's zero.
+	LatencyThreshold time.Duration
+}
+
+// WithThrottle sets the policy that the Client uses to reduce its load on the wiki.
+func WithThrottle(t Throttle) Option {
+	return func(c *Client) {
+		c.throttle = t
+	}
+}
+
+// limiter is an additive increase, multiplicative decrease limit on the number of in-flight requests.
+type limiter struct {
+	min, max  int
+	threshold time.Duration
+
+	mux          sync.Mutex
+	cond         *sync.Cond
+	limit        float64
+	inflight     int
+	lastDecrease time.Time
+}
+
+func newLimiter(t Throttle) *limiter {
+	if t.MaxConcurrency <= 0 {
+		return nil
+	}
+
+	min := t.MinConcurrency
+	if min < 1 {
+		min = 1
+	}
+
+	l := &limiter{
+		min:       min,
+		max:       t.MaxConcurrency,
+		threshold: t.LatencyThreshold,
+		limit:     float64(t.MaxConcurrency),
+	}
+	l.cond = sync.NewCond(&l.mux)
+	return l
+}
+
+// acquire blocks until the number of in-flight requests is below the limit.
+// It returns the time when the request is allowed to start.
+func (l *limiter) acquire() time.Time {
+	if l == nil {
+		return time.Now()
+	}
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	for l.inflight >= int(l.limit) {
+		l.cond.Wait()
+	}
+	l.inflight++
+
+	return time.Now()
+}
+
+// release records the outcome of a request which started at start.
+// Only the requests which started after the last decrease can decrease the limit again, so that a burst of concurrent failures halves the limit only once.
+func (l *limiter) release(start time.Time, failed bool) {
+	if l == nil {
+		return
+	}
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	l.inflight--
+	defer l.cond.Broadcast()
+
+	slow := l.threshold > 0 && time.Since(start) > l.threshold
+	if !failed && !slow {
+		l.limit = math.Min(float64(l.max), l.limit+1/l.limit)
+		return
+	}
+
+	if start.Before(l.lastDecrease) {
+		return
+	}
+
+	l.limit = math.Max(float64(l.min), l.limit/2)
+	l.lastDecrease = time.Now()
+	log.Instance().Debugf("Throttling requests. Limit=%d Failed=%t Slow=%t", int(l.limit), failed, slow)
+}
+
+// current returns the current limit on the number of in-flight requests.
+func (l *limiter) current() int {
+	if l == nil {
+		return 0
+	}
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	return int(l.limit)
+}
+
+// maxLagParam returns the value of the maxlag parameter, in seconds.
+func (t Throttle) maxLagParam() string {
+	seconds := int(math.Ceil(t.MaxLag.Seconds()))
+	return strconv.Itoa(seconds)
+}
+
+// lagged returns the time to wait before a request rejected because of replication lag can be retried.
+// It returns false if the errors aren't caused by replication lag.
+func (t Throttle) lagged(errors []*ResponseError) (time.Duration, bool) {
+	for _, e := range errors {
+		if e.Code != maxLagCode {
+			continue
+		}
+
+		wait := t.MaxLag
+		if e.Data != nil && e.Data.Lag > 0 {
+			wait = time.Duration(e.Data.Lag * float64(time.Second))
+		}
+
+		if wait > maxLagBackoff {
+			wait = maxLagBackoff
+		}
+		return wait, true
+	}
+
+	return 0, false
+}
+
+// throttled returns true if any of the errors is caused by exceeding the rate limits.
+func throttled(errors []*ResponseError) bool {
+	for _, e := range errors {
+		if _, ok := rateLimitedCodes[e.Code]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,279 @@
+package wikipedia
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ihcsim/wikiracer/log"
+)
+
+func TestMaxLag(t *testing.T) {
+	log.Instance().SetBackend(log.QuietBackend)
+
+	var testCases = []struct {
+		name     string
+		retries  int
+		lagged   int
+		calls    int
+		expected string
+	}{
+		{name: "Retry", retries: 3, lagged: 2, calls: 3},
+		{name: "Exhausted", retries: 1, lagged: -1, calls: 2, expected: maxLagCode},
+	}
+
+	for _, testCase := range testCases {
+		client, err := NewClient(WithThrottle(Throttle{MaxLag: 5 * time.Second, MaxLagRetries: testCase.retries}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var calls int
+		client.api = func(values ...map[string]string) ([]byte, error) {
+			calls++
+			if values[0]["maxlag"] != "5" {
+				return nil, fmt.Errorf("Unexpected query: %v", values[0])
+			}
+
+			if testCase.lagged < 0 || calls <= testCase.lagged {
+				return []byte(`{"errors": [{"code": "maxlag", "text": "Waiting for 10.64.48.35: 0.01 seconds lagged.", "data": {"host": "10.64.48.35", "lag": 0.01, "type": "db"}, "module": "main"}]}`), nil
+			}
+			return []byte(linksResponse), nil
+		}
+
+		_, err = client.FindPages("Mike Tyson", "")
+		switch {
+		case testCase.expected == "" && err != nil:
+			t.Errorf("Test case %q failed. Unexpected error: %s", testCase.name, err)
+		case testCase.expected != "":
+			if e, ok := err.(*ServerError); !ok || e.Code != testCase.expected {
+				t.Errorf("Test case %q failed. Mismatch error. Got %v", testCase.name, err)
+			}
+		}
+
+		if calls != testCase.calls {
+			t.Errorf("Test case %q failed. Mismatch calls count. Expected %d. Actual %d", testCase.name, testCase.calls, calls)
+		}
+	}
+}
+
+func TestRateLimitRetries(t *testing.T) {
+	log.Instance().SetBackend(log.QuietBackend)
+
+	var testCases = []struct {
+		name    string
+		retries int
+		limited int
+		calls   int
+		err     bool
+	}{
+		{name: "Retry", retries: 1, limited: 1, calls: 2},
+		{name: "Exhausted", retries: 1, limited: -1, calls: 2, err: true},
+		{name: "No Retries", retries: 0, limited: -1, calls: 1, err: true},
+	}
+
+	for _, testCase := range testCases {
+		client, err := NewClient(WithThrottle(Throttle{RateLimitRetries: testCase.retries}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var calls int
+		client.api = func(values ...map[string]string) ([]byte, error) {
+			calls++
+			if testCase.limited < 0 || calls <= testCase.limited {
+				return nil, &RateLimited{Code: "429", Msg: "429 Too Many Requests", RetryAfter: time.Millisecond}
+			}
+			return []byte(linksResponse), nil
+		}
+
+		_, err = client.FindPages("Mike Tyson", "")
+		switch {
+		case !testCase.err && err != nil:
+			t.Errorf("Test case %q failed. Unexpected error: %s", testCase.name, err)
+		case testCase.err:
+			if _, ok := err.(*RateLimited); !ok {
+				t.Errorf("Test case %q failed. Mismatch error. Got %v", testCase.name, err)
+			}
+		}
+
+		if calls != testCase.calls {
+			t.Errorf("Test case %q failed. Mismatch calls count. Expected %d. Actual %d", testCase.name, testCase.calls, calls)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	log.Instance().SetBackend(log.QuietBackend)
+
+	t.Run("Header", func(t *testing.T) {
+		var testCases = []struct {
+			name     string
+			header   string
+			expected time.Duration
+		}{
+			{name: "Missing"},
+			{name: "Seconds", header: "2", expected: 2 * time.Second},
+			{name: "Negative", header: "-1"},
+			{name: "Invalid", header: "soon"},
+			{name: "Past Date", header: "Wed, 21 Oct 2015 07:28:00 GMT"},
+		}
+
+		for _, testCase := range testCases {
+			if actual := retryAfter(testCase.header); actual != testCase.expected {
+				t.Errorf("Test case %q failed. Mismatch duration. Expected %s. Actual %s", testCase.name, testCase.expected, actual)
+			}
+		}
+
+		date := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+		if actual := retryAfter(date); actual <= 59*time.Minute || actual > time.Hour {
+			t.Errorf("Mismatch duration of date %s. Actual %s", date, actual)
+		}
+	})
+
+	t.Run("Response", func(t *testing.T) {
+		stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer stub.Close()
+
+		client, err := NewClient(WithEndpoint(stub.URL), WithThrottle(Throttle{RateLimitRetries: 3}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the requested duration exceeds the longest cool down, so the request fails without waiting.
+		_, err = client.FindPages("Mike Tyson", "")
+		if e, ok := err.(*RateLimited); !ok || e.RetryAfter != 2*time.Minute {
+			t.Errorf("Mismatch error. Expected RateLimited with a 2m0s Retry-After. Actual %#v", err)
+		}
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		client, err := NewClient(WithThrottle(Throttle{RateLimitRetries: 3}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var calls int64
+		client.api = func(values ...map[string]string) ([]byte, error) {
+			atomic.AddInt64(&calls, 1)
+			return nil, &RateLimited{Code: "429", Msg: "429 Too Many Requests", RetryAfter: 30 * time.Second}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		for batch := range client.StreamPages(ctx, "Mike Tyson", "") {
+			if batch.Err != context.DeadlineExceeded {
+				t.Errorf("Mismatch error. Expected %q. Actual %v", context.DeadlineExceeded, batch.Err)
+			}
+		}
+
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Expected the cool down to stop when the context is done. Elapsed %s", elapsed)
+		}
+
+		if actual := atomic.LoadInt64(&calls); actual != 1 {
+			t.Errorf("Mismatch calls count. Expected 1. Actual %d", actual)
+		}
+	})
+}
+
+func TestLimiter(t *testing.T) {
+	log.Instance().SetBackend(log.QuietBackend)
+
+	t.Run("Decrease", func(t *testing.T) {
+		l := newLimiter(Throttle{MinConcurrency: 1, MaxConcurrency: 8})
+
+		// concurrent failures halve the limit only once.
+		var starts []time.Time
+		for i := 0; i < 4; i++ {
+			starts = append(starts, l.acquire())
+		}
+		for _, start := range starts {
+			l.release(start, true)
+		}
+
+		if actual := l.current(); actual != 4 {
+			t.Errorf("Mismatch limit. Expected 4. Actual %d", actual)
+		}
+
+		for i := 0; i < 5; i++ {
+			l.release(l.acquire(), true)
+		}
+
+		if actual := l.current(); actual != 1 {
+			t.Errorf("Mismatch limit. Expected the minimum of 1. Actual %d", actual)
+		}
+	})
+
+	t.Run("Increase", func(t *testing.T) {
+		l := newLimiter(Throttle{MinConcurrency: 1, MaxConcurrency: 4})
+		l.release(l.acquire(), true)
+		l.release(l.acquire(), true)
+
+		for i := 0; i < 100; i++ {
+			l.release(l.acquire(), false)
+		}
+
+		if actual := l.current(); actual != 4 {
+			t.Errorf("Mismatch limit. Expected the maximum of 4. Actual %d", actual)
+		}
+	})
+
+	t.Run("Slow", func(t *testing.T) {
+		l := newLimiter(Throttle{MinConcurrency: 1, MaxConcurrency: 4, LatencyThreshold: time.Millisecond})
+		start := l.acquire()
+		time.Sleep(5 * time.Millisecond)
+		l.release(start, false)
+
+		if actual := l.current(); actual != 2 {
+			t.Errorf("Mismatch limit. Expected 2. Actual %d", actual)
+		}
+	})
+
+	t.Run("In-Flight", func(t *testing.T) {
+		var (
+			l        = newLimiter(Throttle{MinConcurrency: 1, MaxConcurrency: 3})
+			wg       sync.WaitGroup
+			mux      sync.Mutex
+			inflight int
+			peak     int
+		)
+
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				start := l.acquire()
+				mux.Lock()
+				inflight++
+				if inflight > peak {
+					peak = inflight
+				}
+				mux.Unlock()
+
+				time.Sleep(time.Millisecond)
+
+				mux.Lock()
+				inflight--
+				mux.Unlock()
+				l.release(start, false)
+			}()
+		}
+		wg.Wait()
+
+		if peak > 3 {
+			t.Errorf("Mismatch peak in-flight requests. Expected at most 3. Actual %d", peak)
+		}
+	})
+}
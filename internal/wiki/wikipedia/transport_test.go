@@ -0,0 +1,179 @@
+package wikipedia
+
+import (
+	"compress/gzip"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const linksResponse = `{"batchcomplete": true, "query": {"pages": [{"pageid": 39027, "ns": 0, "title": "Mike Tyson", "links": [{"ns": 0, "title": "1984 Summer Olympics"}]}]}}`
+
+func TestTransport(t *testing.T) {
+	t.Run("User-Agent", func(t *testing.T) {
+		var actual string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			actual = req.Header.Get("User-Agent")
+			fmt.Fprint(w, linksResponse)
+		}))
+		defer server.Close()
+
+		var testCases = []struct {
+			transport Transport
+			expected  string
+		}{
+			{transport: Transport{}, expected: defaultUserAgent},
+			{transport: Transport{UserAgent: "racebot/1.0 (racebot@example.com)"}, expected: "racebot/1.0 (racebot@example.com)"},
+		}
+
+		for _, testCase := range testCases {
+			client, err := NewClient(WithEndpoint(server.URL), WithTransport(testCase.transport))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := client.FindPages("Mike Tyson", ""); err != nil {
+				t.Fatal(err)
+			}
+
+			if actual != testCase.expected {
+				t.Errorf("Mismatch User-Agent. Expected %q. Actual %q", testCase.expected, actual)
+			}
+		}
+	})
+
+	t.Run("Gzip", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if !strings.Contains(req.Header.Get("Accept-Encoding"), "gzip") {
+				fmt.Fprint(w, linksResponse)
+				return
+			}
+
+			w.Header().Set("Content-Encoding", "gzip")
+			writer := gzip.NewWriter(w)
+			defer writer.Close()
+			fmt.Fprint(writer, linksResponse)
+		}))
+		defer server.Close()
+
+		client, err := NewClient(WithEndpoint(server.URL))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pages, err := client.FindPages("Mike Tyson", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(pages) != 1 || pages[0].Title != "Mike Tyson" {
+			t.Errorf("Mismatch pages. Got %+v", pages)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		unblock := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			<-unblock
+		}))
+		defer server.Close()
+		defer close(unblock)
+
+		client, err := NewClient(WithEndpoint(server.URL), WithTransport(Transport{Timeout: 50 * time.Millisecond}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = client.FindPages("Mike Tyson", "")
+		timeout, ok := err.(interface {
+			Timeout() bool
+		})
+		if !ok || !timeout.Timeout() {
+			t.Errorf("Expected timeout error didn't occur. Got %v", err)
+		}
+	})
+
+	t.Run("Proxy", func(t *testing.T) {
+		var actual string
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			actual = req.URL.String()
+			fmt.Fprint(w, linksResponse)
+		}))
+		defer proxy.Close()
+
+		expected := "http://wiki.invalid/w/api.php"
+		client, err := NewClient(WithEndpoint(expected), WithTransport(Transport{Proxy: proxy.URL}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := client.FindPages("Mike Tyson", ""); err != nil {
+			t.Fatal(err)
+		}
+
+		if actual != expected {
+			t.Errorf("Mismatch proxied URL. Expected %q. Actual %q", expected, actual)
+		}
+	})
+
+	t.Run("CA Bundle", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			fmt.Fprint(w, linksResponse)
+		}))
+		defer server.Close()
+
+		bundle, err := ioutil.TempFile("", "ca-bundle")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(bundle.Name())
+
+		if err := pem.Encode(bundle, &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}); err != nil {
+			t.Fatal(err)
+		}
+		bundle.Close()
+
+		untrusted, err := NewClient(WithEndpoint(server.URL))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := untrusted.FindPages("Mike Tyson", ""); err == nil {
+			t.Error("Expected certificate error didn't occur")
+		}
+
+		trusted, err := NewClient(WithEndpoint(server.URL), WithTransport(Transport{CABundle: bundle.Name()}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := trusted.FindPages("Mike Tyson", ""); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Server Error", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			http.Error(w, "upstream connect error", http.StatusBadGateway)
+		}))
+		defer server.Close()
+
+		client, err := NewClient(WithEndpoint(server.URL))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, actual := client.FindPages("Mike Tyson", "")
+		expected := &ServerError{Code: "502", Msg: "502 Bad Gateway"}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Mismatch error.\nExpected: %v\nActual: %v", expected, actual)
+		}
+	})
+}
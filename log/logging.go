@@ -1,9 +1,16 @@
 package log
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
 	"io/ioutil"
+	stdlog "log"
 	"os"
+	"path/filepath"
+	"runtime"
 	"sync"
+	"time"
 
 	logging "gopkg.in/op/go-logging.v1"
 )
@@ -13,12 +20,21 @@ const (
 	logLevelDefault  = logging.INFO
 	logLevelEnvVar   = "WIKIRACER_LOG_LEVEL"
 	logFormatDefault = "%{color}[%{level:.4s}] - %{id} (%{shortpkg}/%{shortfile}) ▶ %{color:reset}%{message}"
+
+	// FormatText is the default human-readable log format.
+	FormatText = "text"
+
+	// FormatJSON logs every message as a JSON object on its own line.
+	FormatJSON = "json"
 )
 
 var (
 	log    *logging.Logger
 	logMux sync.Mutex
 
+	// logLevel is the log level set with SetLevel. It takes precedence over the env var.
+	logLevel string
+
 	once      = &sync.Once{}
 	formatter = logging.MustStringFormatter(logFormatDefault)
 
@@ -34,20 +50,56 @@ func Instance() *logging.Logger {
 		logging.SetLevel(logLevelDefault, logModule)
 	})
 
-	// read the log level from env var, if specified.
+	// read the log level from env var, if specified and if it isn't overridden with SetLevel.
 	// otherwise, use the default log level.
-	logLevel, exist := os.LookupEnv(logLevelEnvVar)
-	if exist {
-		logMux.Lock()
-		if err := setLogLevel(logLevel); err != nil {
+	logMux.Lock()
+	defer logMux.Unlock()
+	if envLogLevel, exist := os.LookupEnv(logLevelEnvVar); exist && logLevel == "" {
+		if err := setLogLevel(envLogLevel); err != nil {
 			log.Warning("Can't change log level. ", err.Error())
 		}
-		logMux.Unlock()
 	}
 
 	return log
 }
 
+// SetLevel sets the log level, overriding the WIKIRACER_LOG_LEVEL env var.
+func SetLevel(level string) error {
+	Instance()
+
+	logMux.Lock()
+	defer logMux.Unlock()
+
+	if err := setLogLevel(level); err != nil {
+		return err
+	}
+	logLevel = level
+	return nil
+}
+
+// SetFormat sets the format of the log messages to either FormatText or FormatJSON. The messages are written to stderr.
+func SetFormat(format string) error {
+	Instance()
+
+	var backend logging.Backend
+	switch format {
+	case FormatText:
+		backend = logging.NewBackendFormatter(logging.NewLogBackend(os.Stderr, "", stdlog.LstdFlags), formatter)
+	case FormatJSON:
+		backend = logging.NewBackendFormatter(logging.NewLogBackend(os.Stderr, "", 0), jsonFormatter{})
+	default:
+		return fmt.Errorf("Unknown log format: %q", format)
+	}
+
+	logMux.Lock()
+	defer logMux.Unlock()
+
+	level := logging.GetLevel(logModule)
+	logging.SetBackend(backend)
+	logging.SetLevel(level, logModule)
+	return nil
+}
+
 func setLogLevel(l string) error {
 	logLevel, err := logging.LogLevel(l)
 	if err != nil {
@@ -56,3 +108,32 @@ func setLogLevel(l string) error {
 	logging.SetLevel(logLevel, logModule)
 	return nil
 }
+
+// jsonFormatter formats a log record as a JSON object.
+type jsonFormatter struct{}
+
+type jsonRecord struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Module  string    `json:"module"`
+	File    string    `json:"file,omitempty"`
+	Message string    `json:"message"`
+}
+
+// Format writes the JSON representation of r, followed by a newline, to w.
+func (jsonFormatter) Format(calldepth int, r *logging.Record, w io.Writer) error {
+	record := jsonRecord{
+		Time:    r.Time,
+		Level:   r.Level.String(),
+		Module:  r.Module,
+		Message: r.Message(),
+	}
+
+	if _, file, line, ok := runtime.Caller(calldepth + 1); ok {
+		record.File = fmt.Sprintf("%s:%d", filepath.Base(file), line)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(record)
+}
@@ -1,7 +1,10 @@
 package log
 
 import (
+	"bytes"
+	"encoding/json"
 	"os"
+	"strings"
 	"sync"
 	"testing"
 
@@ -73,3 +76,46 @@ func TestInstance(t *testing.T) {
 	})
 
 }
+
+func TestSetLevel(t *testing.T) {
+	os.Setenv(logLevelEnvVar, "ERROR")
+	defer os.Unsetenv(logLevelEnvVar)
+	defer func() {
+		logLevel = ""
+	}()
+
+	if err := SetLevel("DEBUG"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the level set with SetLevel overrides the env var.
+	if log := Instance(); !log.IsEnabledFor(logging.DEBUG) {
+		t.Error("Log level mismatched. Expected logging level to be ", logging.DEBUG)
+	}
+
+	if err := SetLevel("VERBOSE"); err == nil {
+		t.Error("Expected an error for an unknown log level")
+	}
+}
+
+func TestJSONFormat(t *testing.T) {
+	var (
+		buf    bytes.Buffer
+		logger = logging.MustGetLogger("test")
+	)
+	logger.SetBackend(logging.AddModuleLevel(logging.NewBackendFormatter(logging.NewLogBackend(&buf, "", 0), jsonFormatter{})))
+	logger.Warningf("Skipping batch. Titles=%q", "Mike Tyson")
+
+	var actual jsonRecord
+	if err := json.Unmarshal(buf.Bytes(), &actual); err != nil {
+		t.Fatal(err)
+	}
+
+	if actual.Level != "WARNING" || actual.Module != "test" || actual.Message != `Skipping batch. Titles="Mike Tyson"` || !strings.HasPrefix(actual.File, "logging_test.go:") {
+		t.Errorf("Mismatch record. Got %+v", actual)
+	}
+
+	if err := SetFormat("xml"); err == nil {
+		t.Error("Expected an error for an unknown log format")
+	}
+}
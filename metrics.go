@@ -0,0 +1,41 @@
+package wikiracer
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/ihcsim/wikiracer/internal/metrics"
+)
+
+var (
+	racesStarted   = metrics.NewCounter("wikiracer_races_started_total", "Number of races started.")
+	racesSucceeded = metrics.NewCounter("wikiracer_races_succeeded_total", "Number of races which found a path.")
+	racesFailed    = metrics.NewCounter("wikiracer_races_failed_total", "Number of races which failed, by error type.", "error")
+	raceDuration   = metrics.NewHistogram("wikiracer_race_duration_seconds", "Duration of the races.", metrics.DefaultBuckets)
+	pathHops       = metrics.NewHistogram("wikiracer_path_hops", "Number of links followed by the paths found.", []float64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
+)
+
+// record records the outcome of a race which started at start.
+func record(result *Result, start time.Time) {
+	raceDuration.Observe(time.Since(start).Seconds())
+	if result.Err != nil {
+		racesFailed.Inc(errorType(result.Err))
+		return
+	}
+
+	racesSucceeded.Inc()
+	pathHops.Observe(float64(result.Hops()))
+}
+
+// errorType returns the name of the type of err, e.g. DestinationUnreachable.
+func errorType(err error) string {
+	t := reflect.TypeOf(err)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Name() == "" {
+		return "unknown"
+	}
+	return t.Name()
+}
@@ -0,0 +1,58 @@
+package wikiracer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ihcsim/wikiracer/internal/crawler"
+	"github.com/ihcsim/wikiracer/internal/validator"
+	"github.com/ihcsim/wikiracer/log"
+)
+
+func TestMetrics(t *testing.T) {
+	log.Instance().SetBackend(log.QuietBackend)
+
+	var (
+		started     = racesStarted.Value()
+		succeeded   = racesSucceeded.Value()
+		unreachable = racesFailed.Value("DestinationUnreachable")
+		notFound    = racesFailed.Value("PageNotFound")
+		durations   = raceDuration.Count()
+		hops        = pathHops.Count()
+	)
+
+	racer := New(crawler.NewForward(mockWiki), &validator.InputValidator{Wiki: mockWiki})
+	if result := racer.FindPath(context.Background(), "Mike Tyson", "Apepi"); result.Err != nil {
+		t.Fatal("Unexpected error: ", result.Err)
+	}
+
+	if result := racer.FindPath(context.Background(), "Mike Tyson", "Nonexistent"); result.Err == nil {
+		t.Fatal("Expected error")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	racer = New(crawler.NewForward(mockWiki), &validator.InputValidator{Wiki: mockWiki})
+	if result := racer.FindPath(ctx, "Segment", "Mike Tyson"); result.Err == nil {
+		t.Fatal("Expected error")
+	}
+
+	var testCases = []struct {
+		name     string
+		actual   float64
+		expected float64
+	}{
+		{name: "Started", actual: racesStarted.Value() - started, expected: 3},
+		{name: "Succeeded", actual: racesSucceeded.Value() - succeeded, expected: 1},
+		{name: "Unreachable", actual: racesFailed.Value("DestinationUnreachable") - unreachable, expected: 1},
+		{name: "Not Found", actual: racesFailed.Value("PageNotFound") - notFound, expected: 1},
+		{name: "Durations", actual: float64(raceDuration.Count() - durations), expected: 3},
+		{name: "Hops", actual: float64(pathHops.Count() - hops), expected: 1},
+	}
+
+	for _, testCase := range testCases {
+		if testCase.actual != testCase.expected {
+			t.Errorf("Test case %q failed. Mismatch value. Expected %v. Actual %v", testCase.name, testCase.expected, testCase.actual)
+		}
+	}
+}
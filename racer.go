@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/ihcsim/wikiracer/errors"
+	"github.com/ihcsim/wikiracer/internal/wiki"
 )
 
 // WikiRacer traverses from a wiki page to another using only links.
@@ -35,7 +36,13 @@ func (r *WikiRacer) TimedFindPath(ctx context.Context, origin, destination strin
 // Otherwise, if a path isn't found, a DestinationUnreachable error is returned.
 // The destination page is considered unreachable if racer can't find it before the context timed out.
 // Use ctx to impose timeout on FindPath.
-func (r *WikiRacer) FindPath(ctx context.Context, origin, destination string) *Result {
+func (r *WikiRacer) FindPath(ctx context.Context, origin, destination string) (result *Result) {
+	racesStarted.Inc()
+	start := time.Now()
+	defer func() {
+		record(result, start)
+	}()
+
 	cancelCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
@@ -44,7 +51,7 @@ func (r *WikiRacer) FindPath(ctx context.Context, origin, destination string) *R
 	}
 
 	if origin == destination {
-		return &Result{Path: []byte(origin)}
+		return &Result{Path: []byte(origin), Pages: []*wiki.Page{&wiki.Page{Title: origin}}}
 	}
 
 	go r.Run(cancelCtx, origin, destination)
@@ -52,13 +59,23 @@ func (r *WikiRacer) FindPath(ctx context.Context, origin, destination string) *R
 	for {
 		select {
 		case path := <-r.Path():
-			return &Result{Path: []byte(path.String())}
+			return r.result(&Result{Path: []byte(path.String()), Pages: path.Pages()})
 
 		case err := <-r.Error():
-			return &Result{Err: err}
+			return r.result(&Result{Err: err})
 
 		case <-cancelCtx.Done():
-			return &Result{Err: errors.DestinationUnreachable{Destination: destination}}
+			return r.result(&Result{Err: errors.DestinationUnreachable{Destination: destination}})
 		}
 	}
 }
+
+// result attaches the crawl diagnostics to result.
+func (r *WikiRacer) result(result *Result) *Result {
+	result.Warnings = r.Warnings()
+	result.Skipped = r.Skipped()
+	result.Visited = r.Visited()
+	result.Batches = r.Batches()
+	result.APICalls = r.APICalls()
+	return result
+}
@@ -2,6 +2,7 @@ package wikiracer
 
 import (
 	"context"
+	"reflect"
 	"testing"
 	"time"
 
@@ -47,7 +48,7 @@ func TestFindPath(t *testing.T) {
 
 			for id, testCase := range testCases {
 				var (
-					racer           = New(crawler.NewForward(mockWiki), &validator.InputValidator{mockWiki})
+					racer           = New(crawler.NewForward(mockWiki), &validator.InputValidator{Wiki: mockWiki})
 					result          = make(chan *Result)
 					ctx, cancelFunc = context.WithTimeout(context.Background(), timeout)
 				)
@@ -83,7 +84,7 @@ func TestFindPath(t *testing.T) {
 
 			for id, testCase := range testCases {
 				var (
-					racer           = New(crawler.NewForward(mockWiki), &validator.InputValidator{mockWiki})
+					racer           = New(crawler.NewForward(mockWiki), &validator.InputValidator{Wiki: mockWiki})
 					ctx, cancelFunc = context.WithTimeout(context.Background(), timeout)
 					result          = make(chan *Result)
 				)
@@ -121,14 +122,14 @@ func TestFindPath(t *testing.T) {
 		}{
 			{origin: "", expected: errors.InvalidEmptyInput{}},
 			{origin: "123456789", expected: errors.InvalidEmptyInput{Origin: "123456789"}},
-			{origin: "123456789", destination: "Mike Tyson", expected: errors.PageNotFound{wiki.Page{Title: "123456789"}}},
-			{origin: "Mike Tyson", destination: "123456789", expected: errors.PageNotFound{wiki.Page{Title: "123456789"}}},
+			{origin: "123456789", destination: "Mike Tyson", expected: errors.PageNotFound{Page: wiki.Page{Title: "123456789"}}},
+			{origin: "Mike Tyson", destination: "123456789", expected: errors.PageNotFound{Page: wiki.Page{Title: "123456789"}}},
 			{origin: "Mike Tyson", destination: "Michael Jordan", expected: errors.DestinationUnreachable{Destination: "Michael Jordan"}},
 		}
 
 		for id, testCase := range testCases {
 			var (
-				racer           = New(crawler.NewForward(mockWiki), &validator.InputValidator{mockWiki})
+				racer           = New(crawler.NewForward(mockWiki), &validator.InputValidator{Wiki: mockWiki})
 				result          = make(chan *Result)
 				ctx, cancelFunc = context.WithTimeout(context.Background(), timeout)
 			)
@@ -172,7 +173,7 @@ func TestFindPath(t *testing.T) {
 
 func TestTimedFindPath(t *testing.T) {
 	var (
-		racer        = New(crawler.NewForward(mockWiki), &validator.InputValidator{mockWiki})
+		racer        = New(crawler.NewForward(mockWiki), &validator.InputValidator{Wiki: mockWiki})
 		ctx          = context.Background()
 		origin       = "Mike Tyson"
 		destination  = "Segment"
@@ -188,3 +189,31 @@ func TestTimedFindPath(t *testing.T) {
 		t.Errorf("Mismatch path.\nExpected %q\nActual: %q", expectedPath, actual.Path)
 	}
 }
+
+func TestFindPathPages(t *testing.T) {
+	log.Instance().SetBackend(log.QuietBackend)
+
+	racer := New(crawler.NewForward(mockWiki), &validator.InputValidator{Wiki: mockWiki})
+	actual := racer.FindPath(context.Background(), "Mike Tyson", "Apepi")
+	if actual.Err != nil {
+		t.Fatal("Unexpected error: ", actual.Err)
+	}
+
+	titles := []string{}
+	for _, page := range actual.Pages {
+		titles = append(titles, page.Title)
+	}
+
+	expected := []string{"Mike Tyson", "Alexander the Great", "Apepi"}
+	if !reflect.DeepEqual(titles, expected) {
+		t.Errorf("Mismatch pages.\nExpected: %v\nActual: %v", expected, titles)
+	}
+
+	if id := actual.Pages[0].ID; id != 1003 {
+		t.Errorf("Mismatch ID of the origin page. Expected 1003. Actual %d", id)
+	}
+
+	if actual.Visited < len(expected) || actual.Batches < 1 {
+		t.Errorf("Mismatch crawl statistics. Visited=%d Batches=%d", actual.Visited, actual.Batches)
+	}
+}
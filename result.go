@@ -3,6 +3,9 @@ package wikiracer
 import (
 	"fmt"
 	"time"
+
+	"github.com/ihcsim/wikiracer/errors"
+	"github.com/ihcsim/wikiracer/internal/wiki"
 )
 
 // Result captures the duration to discover the path from the origin page to the destination page.
@@ -10,11 +13,44 @@ type Result struct {
 	// Path represents an ordered sequence of pages from the origin page to the destination page.
 	Path []byte
 
+	// Pages are the pages of Path. The IDs of the pages are set if they are known.
+	Pages []*wiki.Page
+
 	// Duration captures the time taken to discover path.
 	Duration time.Duration
 
 	// Err are errors captured during the path discovery.
 	Err error
+
+	// Warnings are non-fatal diagnostics reported by the wiki during the path discovery.
+	Warnings errors.Warnings
+
+	// Skipped are the batches of pages that were skipped because of errors.
+	// If it's empty, the search was complete.
+	Skipped errors.List
+
+	// Visited is the number of pages visited during the path discovery.
+	Visited int
+
+	// Batches is the number of batches of pages requested from the wiki during the path discovery.
+	Batches int
+
+	// APICalls is the number of calls sent to the wiki during the path discovery.
+	APICalls int
+}
+
+// Hops returns the number of links followed from the origin page to the destination page.
+func (r Result) Hops() int {
+	if len(r.Pages) == 0 {
+		return 0
+	}
+	return len(r.Pages) - 1
+}
+
+// Shortest returns true if the path is proven to be the shortest path.
+// The crawler returns the first path it finds, which isn't necessarily the shortest. Only the paths with at most one hop are known to be the shortest.
+func (r Result) Shortest() bool {
+	return r.Err == nil && len(r.Pages) > 0 && r.Hops() <= 1
 }
 
 // String returns a string representation of a result.
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/ihcsim/wikiracer/errors"
+	"github.com/ihcsim/wikiracer/internal/wiki"
 )
 
 func TestResultString(t *testing.T) {
@@ -50,3 +51,27 @@ func TestResultString(t *testing.T) {
 		}
 	})
 }
+
+func TestResultHops(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		result   Result
+		hops     int
+		shortest bool
+	}{
+		{name: "Same Page", result: Result{Pages: []*wiki.Page{&wiki.Page{Title: "Mike Tyson"}}}, hops: 0, shortest: true},
+		{name: "Direct Link", result: Result{Pages: []*wiki.Page{&wiki.Page{Title: "Mike Tyson"}, &wiki.Page{Title: "Alexander the Great"}}}, hops: 1, shortest: true},
+		{name: "Many Hops", result: Result{Pages: []*wiki.Page{&wiki.Page{Title: "Mike Tyson"}, &wiki.Page{Title: "Alexander the Great"}, &wiki.Page{Title: "Apepi"}}}, hops: 2, shortest: false},
+		{name: "Error", result: Result{Err: errors.DestinationUnreachable{Destination: "Apepi"}}, hops: 0, shortest: false},
+	}
+
+	for _, testCase := range testCases {
+		if actual := testCase.result.Hops(); actual != testCase.hops {
+			t.Errorf("Test case %q failed. Mismatch hops. Expected %d. Actual %d", testCase.name, testCase.hops, actual)
+		}
+
+		if actual := testCase.result.Shortest(); actual != testCase.shortest {
+			t.Errorf("Test case %q failed. Mismatch shortest. Expected %t. Actual %t", testCase.name, testCase.shortest, actual)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/ihcsim/wikiracer/internal/wiki"
+	"github.com/ihcsim/wikiracer/internal/wiki/graph"
+	"github.com/ihcsim/wikiracer/internal/wiki/wikipedia"
+	"github.com/ihcsim/wikiracer/log"
+)
+
+// wikiBackend provides the wikis that the races run on.
+var wikiBackend = &backend{}
+
+// backend provides the wikis that the races run on. It's created at startup, and shared by all the races.
+// The live backend sends the requests of all the races through the same Wikipedia client, so they share its connections, its concurrency limiter and its login session.
+// The cached backend also shares a cache of pages among the races.
+// The offline backend races on a graph loaded from a file, without sending any request to Wikipedia.
+type backend struct {
+	// clients are the clients which fetch the pages with and without metadata. The metadata client is a copy of the other one. They are nil if the backend is offline.
+	clients map[bool]*wikipedia.Client
+
+	// sources are the wikis whose links are followed by the races which follow all the links of the current revisions. They are the clients, wrapped by the caches if the backend is cached.
+	sources map[bool]wiki.Wiki
+
+	// caches are the caches of the pages with and without metadata. They are nil if the backend isn't cached.
+	caches map[bool]*wiki.Cache
+
+	// cacheFile is the file that the caches are saved to at shutdown, and loaded from at startup.
+	cacheFile string
+
+	// dump is the graph of the offline backend. It's nil if the backend isn't offline.
+	dump *graph.Wiki
+}
+
+// persistedCaches is the JSON representation of the caches of the backend.
+type persistedCaches struct {
+	Pages         *wiki.Cache `json:"pages"`
+	MetadataPages *wiki.Cache `json:"metadata_pages"`
+}
+
+// newBackend returns the backend selected by cfg. The clients of the live and cached backends are created with options.
+func newBackend(cfg *config, options ...wikipedia.Option) (*backend, error) {
+	b := &backend{}
+	switch cfg.Backend {
+	case backendLive, backendCached:
+		client, err := wikipedia.NewClient(options...)
+		if err != nil {
+			return nil, err
+		}
+
+		b.clients = map[bool]*wikipedia.Client{false: client, true: client.Metadata()}
+		b.sources = map[bool]wiki.Wiki{false: b.clients[false], true: b.clients[true]}
+		if cfg.Backend == backendLive {
+			break
+		}
+
+		b.caches = map[bool]*wiki.Cache{
+			false: wiki.NewCache(cfg.CacheSize, cfg.CacheTTL),
+			true:  wiki.NewCache(cfg.CacheSize, cfg.CacheTTL),
+		}
+		for metadata, cache := range b.caches {
+			b.sources[metadata] = cache.Wrap(b.clients[metadata])
+		}
+
+		b.cacheFile = cfg.CacheFile
+		if err := b.load(); err != nil {
+			return nil, err
+		}
+
+	case backendOffline:
+		dump, err := graph.Load(cfg.Dump)
+		if err != nil {
+			return nil, err
+		}
+		b.dump = dump
+	}
+
+	return b, nil
+}
+
+// source returns the wiki whose links are followed by a race, and the randomizer which draws its random pages.
+// metadata, links and asOf are the options of the race. See linkSource.
+// Only the links of the current revisions of the pages are cached.
+func (b *backend) source(metadata bool, links, asOf string) (wiki.Wiki, wiki.Randomizer, error) {
+	if b.dump != nil {
+		if (links != "" && links != linksAll) || asOf != "" {
+			return nil, nil, invalidParameter{fmt.Errorf("The %s and %s parameters aren't supported by the offline backend", queryParameterLinks, queryParameterAsOf)}
+		}
+		return b.dump, b.dump, nil
+	}
+
+	client := b.clients[metadata]
+	source, err := linkSource(client, links, asOf)
+	if err != nil {
+		return nil, nil, invalidParameter{err}
+	}
+
+	if source == wiki.Wiki(client) {
+		source = b.sources[metadata]
+	}
+
+	return source, client, nil
+}
+
+// randomizer returns the randomizer which draws the random pages of the races.
+func (b *backend) randomizer() wiki.Randomizer {
+	if b.dump != nil {
+		return b.dump
+	}
+	return b.clients[false]
+}
+
+// load restores the caches saved to the cache file. A missing cache file is ignored.
+func (b *backend) load() error {
+	if b.cacheFile == "" {
+		return nil
+	}
+
+	content, err := ioutil.ReadFile(b.cacheFile)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(content, &persistedCaches{Pages: b.caches[false], MetadataPages: b.caches[true]}); err != nil {
+		return fmt.Errorf("Invalid cache file %s: %s", b.cacheFile, err)
+	}
+
+	log.Instance().Infof("Loaded %d pages from %s", b.caches[false].Len()+b.caches[true].Len(), b.cacheFile)
+	return nil
+}
+
+// save writes the caches to the cache file. The file is replaced atomically.
+func (b *backend) save() error {
+	if b.cacheFile == "" || b.caches == nil {
+		return nil
+	}
+
+	content, err := json.Marshal(&persistedCaches{Pages: b.caches[false], MetadataPages: b.caches[true]})
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(b.cacheFile), filepath.Base(b.cacheFile))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	log.Instance().Infof("Saving %d pages to %s", b.caches[false].Len()+b.caches[true].Len(), b.cacheFile)
+	return os.Rename(tmp.Name(), b.cacheFile)
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ihcsim/wikiracer/log"
+)
+
+const (
+	// envPrefix is the prefix of the env vars of the settings.
+	envPrefix = "WIKIRACER_"
+
+	// envConfig is the env var that holds the path of the JSON config file. It's overridden by the -config flag.
+	envConfig = "WIKIRACER_CONFIG"
+
+	// the backends that the races can run on.
+	backendLive    = "live"
+	backendCached  = "cached"
+	backendOffline = "offline"
+
+	// crawlerForward is the uni-directional crawler. It's the only supported crawler.
+	crawlerForward = "forward"
+)
+
+// config is the configuration of the server.
+type config struct {
+	Listen      string
+	PprofListen string
+	DrainPeriod time.Duration
+
+	Timeout     time.Duration
+	MaxTimeout  time.Duration
+	MaxPages    int
+	MaxAPICalls int
+
+	Backend   string
+	Endpoint  string
+	UserAgent string
+	Dump      string
+	CacheSize int
+	CacheTTL  time.Duration
+	CacheFile string
+
+	ResultCacheSize int
+	ResultCacheTTL  time.Duration
+
+	Crawler string
+	Workers int
+
+	MaxRaces       int
+	RaceQueue      int
+	QueueTimeout   time.Duration
+	RateLimit      int
+	RateBurst      int
+	APIKeys        string
+	TrustedProxies string
+
+	LogLevel  string
+	LogFormat string
+}
+
+func defaultConfig() *config {
+	return &config{
+		Listen:          ":8080",
+		PprofListen:     ":6060",
+		DrainPeriod:     30 * time.Second,
+		Timeout:         180 * time.Second,
+		MaxTimeout:      10 * time.Minute,
+		Backend:         backendLive,
+		CacheSize:       100000,
+		CacheTTL:        time.Hour,
+		ResultCacheSize: 10000,
+		ResultCacheTTL:  10 * time.Minute,
+		Crawler:         crawlerForward,
+		MaxRaces:        16,
+		RaceQueue:       64,
+		QueueTimeout:    30 * time.Second,
+		RateLimit:       60,
+		RateBurst:       10,
+		LogLevel:        "INFO",
+		LogFormat:       log.FormatText,
+	}
+}
+
+// setting is a configuration setting, which can be set with a command-line flag, an env var, or a key of the JSON config file.
+// The env var is the name in upper case with the WIKIRACER_ prefix, and the key is the name. Dashes are replaced with underscores in both.
+type setting struct {
+	name  string
+	usage string
+	value func(*config) flag.Value
+}
+
+var settings = []setting{
+	{"listen", "The address that the server listens on", func(c *config) flag.Value { return (*stringValue)(&c.Listen) }},
+	{"pprof-listen", "The address that the profiling server listens on. The profiling server is disabled if it's empty", func(c *config) flag.Value { return (*stringValue)(&c.PprofListen) }},
+	{"drain-period", "The time that the running races are given to complete when the server shuts down", func(c *config) flag.Value { return (*durationValue)(&c.DrainPeriod) }},
+	{"timeout", "The default timeout of a race", func(c *config) flag.Value { return (*durationValue)(&c.Timeout) }},
+	{"max-timeout", "The maximum timeout of a race", func(c *config) flag.Value { return (*durationValue)(&c.MaxTimeout) }},
+	{"max-pages", "The maximum number of pages that a race visits. It's unbounded if it's 0", func(c *config) flag.Value { return (*intValue)(&c.MaxPages) }},
+	{"max-api-calls", "The maximum number of calls that a race sends to the wiki. It's unbounded if it's 0", func(c *config) flag.Value { return (*intValue)(&c.MaxAPICalls) }},
+	{"backend", "The wiki that the races run on. One of live, cached and offline", func(c *config) flag.Value { return (*stringValue)(&c.Backend) }},
+	{"endpoint", "The URL of the api.php of the live wiki. Defaults to the English Wikipedia", func(c *config) flag.Value { return (*stringValue)(&c.Endpoint) }},
+	{"user-agent", "The User-Agent of the requests to the live wiki, with the contact information of the operator", func(c *config) flag.Value { return (*stringValue)(&c.UserAgent) }},
+	{"dump", "The path of the JSON or DOT graph that the offline backend loads", func(c *config) flag.Value { return (*stringValue)(&c.Dump) }},
+	{"cache-size", "The maximum number of pages that the cached backend holds", func(c *config) flag.Value { return (*intValue)(&c.CacheSize) }},
+	{"cache-ttl", "The duration that a page is cached for by the cached backend", func(c *config) flag.Value { return (*durationValue)(&c.CacheTTL) }},
+	{"cache-file", "The file that the cached backend saves its pages to at shutdown, and loads them from at startup", func(c *config) flag.Value { return (*stringValue)(&c.CacheFile) }},
+	{"result-cache-size", "The maximum number of race results that the server caches", func(c *config) flag.Value { return (*intValue)(&c.ResultCacheSize) }},
+	{"result-cache-ttl", "The duration that a race result is cached for. The result cache is disabled if it's 0", func(c *config) flag.Value { return (*durationValue)(&c.ResultCacheTTL) }},
+	{"crawler", "The crawling algorithm. Only forward is supported", func(c *config) flag.Value { return (*stringValue)(&c.Crawler) }},
+	{"workers", "The maximum number of concurrent requests that a race sends to the wiki. It's unbounded if it's 0", func(c *config) flag.Value { return (*intValue)(&c.Workers) }},
+	{"max-races", "The maximum number of races which run concurrently. It's unbounded if it's 0", func(c *config) flag.Value { return (*intValue)(&c.MaxRaces) }},
+	{"race-queue", "The maximum number of races which wait for a running race to complete. The other races are rejected", func(c *config) flag.Value { return (*intValue)(&c.RaceQueue) }},
+	{"queue-timeout", "The maximum time that a race waits for a running race to complete. It waits until the client gives up if it's 0", func(c *config) flag.Value { return (*durationValue)(&c.QueueTimeout) }},
+	{"rate-limit", "The number of races per minute that a client can start. It's unlimited if it's 0", func(c *config) flag.Value { return (*intValue)(&c.RateLimit) }},
+	{"rate-burst", "The number of races that a client can start at once, before it's rate limited", func(c *config) flag.Value { return (*intValue)(&c.RateBurst) }},
+	{"api-keys", "The comma-separated API keys which are rate limited on their own, instead of by the IP of the client", func(c *config) flag.Value { return (*stringValue)(&c.APIKeys) }},
+	{"trusted-proxies", "The comma-separated IPs and CIDRs of the reverse proxies whose X-Forwarded-For and X-Real-IP headers identify the clients", func(c *config) flag.Value { return (*stringValue)(&c.TrustedProxies) }},
+	{"log-level", "The log level. One of CRITICAL, ERROR, WARNING, NOTICE, INFO and DEBUG", func(c *config) flag.Value { return (*stringValue)(&c.LogLevel) }},
+	{"log-format", "The log format. One of text and json", func(c *config) flag.Value { return (*stringValue)(&c.LogFormat) }},
+}
+
+func (s setting) env() string {
+	return envPrefix + strings.ToUpper(s.key())
+}
+
+func (s setting) key() string {
+	return strings.Replace(s.name, "-", "_", -1)
+}
+
+// loadConfig returns the configuration specified by the command-line args, the env vars returned by getenv, and the JSON config file.
+// The flags take precedence over the env vars, which take precedence over the config file.
+func loadConfig(args []string, getenv func(string) string) (*config, error) {
+	var (
+		flags  = flag.NewFlagSet("wikiracer", flag.ExitOnError)
+		path   = flags.String("config", "", "The path of the JSON config file. Overrides the "+envConfig+" env var")
+		parsed = defaultConfig()
+	)
+	for _, s := range settings {
+		flags.Var(s.value(parsed), s.name, fmt.Sprintf("%s (env %s)", s.usage, s.env()))
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := defaultConfig()
+	if *path == "" {
+		*path = getenv(envConfig)
+	}
+
+	if *path != "" {
+		if err := cfg.load(*path); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range settings {
+		if value := getenv(s.env()); value != "" {
+			if err := s.value(cfg).Set(value); err != nil {
+				return nil, fmt.Errorf("Invalid %s: %s", s.env(), err)
+			}
+		}
+	}
+
+	flags.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.name == f.Name {
+				s.value(cfg).Set(s.value(parsed).String())
+			}
+		}
+	})
+
+	return cfg, cfg.validate()
+}
+
+// load reads the settings of the JSON config file at path. Durations are strings like "90s", and numbers can be strings or JSON numbers.
+func (c *config) load(path string) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(content, &values); err != nil {
+		return fmt.Errorf("Invalid config file %s: %s", path, err)
+	}
+
+	for key, raw := range values {
+		var found bool
+		for _, s := range settings {
+			if s.key() != key {
+				continue
+			}
+			found = true
+
+			value := string(raw)
+			var str string
+			if err := json.Unmarshal(raw, &str); err == nil {
+				value = str
+			}
+
+			if err := s.value(c).Set(value); err != nil {
+				return fmt.Errorf("Invalid %s in config file %s: %s", key, path, err)
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("Unknown setting %q in config file %s", key, path)
+		}
+	}
+
+	return nil
+}
+
+func (c *config) validate() error {
+	switch c.Backend {
+	case backendLive, backendCached:
+	case backendOffline:
+		if c.Dump == "" {
+			return fmt.Errorf("The offline backend requires a dump")
+		}
+	default:
+		return fmt.Errorf("Unknown backend: %q", c.Backend)
+	}
+
+	if c.Crawler != crawlerForward {
+		return fmt.Errorf("Unknown crawler: %q", c.Crawler)
+	}
+
+	if c.Timeout <= 0 || c.MaxTimeout < c.Timeout {
+		return fmt.Errorf("The timeout must be positive, and at most the max timeout. Timeout: %s, Max timeout: %s", c.Timeout, c.MaxTimeout)
+	}
+
+	if c.MaxPages < 0 || c.MaxAPICalls < 0 {
+		return fmt.Errorf("The max pages and the max API calls can't be negative")
+	}
+
+	if c.Backend == backendCached && c.CacheSize <= 0 {
+		return fmt.Errorf("The cache size must be positive")
+	}
+
+	if c.ResultCacheTTL < 0 || (c.ResultCacheTTL > 0 && c.ResultCacheSize <= 0) {
+		return fmt.Errorf("The result cache TTL can't be negative, and the result cache size must be positive if the result cache is enabled")
+	}
+
+	if c.DrainPeriod < 0 {
+		return fmt.Errorf("The drain period can't be negative")
+	}
+
+	if c.Workers < 0 {
+		return fmt.Errorf("The number of workers can't be negative")
+	}
+
+	if c.MaxRaces < 0 || c.RaceQueue < 0 || c.QueueTimeout < 0 {
+		return fmt.Errorf("The max races, the race queue and the queue timeout can't be negative")
+	}
+
+	if c.RateLimit < 0 || (c.RateLimit > 0 && c.RateBurst < 1) {
+		return fmt.Errorf("The rate limit can't be negative, and the rate burst must be positive if the rate limit is set")
+	}
+
+	if _, err := parseNetworks(c.TrustedProxies); err != nil {
+		return fmt.Errorf("Invalid trusted proxies: %s", err)
+	}
+
+	if c.LogFormat != log.FormatText && c.LogFormat != log.FormatJSON {
+		return fmt.Errorf("Unknown log format: %q", c.LogFormat)
+	}
+
+	return nil
+}
+
+type stringValue string
+
+func (v *stringValue) Set(s string) error {
+	*v = stringValue(s)
+	return nil
+}
+
+func (v *stringValue) String() string {
+	return string(*v)
+}
+
+type intValue int
+
+func (v *intValue) Set(s string) error {
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return err
+	}
+	*v = intValue(i)
+	return nil
+}
+
+func (v *intValue) String() string {
+	return strconv.Itoa(int(*v))
+}
+
+type durationValue time.Duration
+
+func (v *durationValue) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*v = durationValue(d)
+	return nil
+}
+
+func (v *durationValue) String() string {
+	return time.Duration(*v).String()
+}
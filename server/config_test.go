@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wikiracer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "config.json")
+	content := `{"listen": ":9000", "timeout": "30s", "workers": 8, "backend": "cached", "cache_size": "500", "log_format": "json"}`
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var testCases = []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		expected func(*config)
+	}{
+		{
+			name:     "Defaults",
+			expected: func(c *config) {},
+		},
+		{
+			name: "Config File",
+			args: []string{"-config", file},
+			expected: func(c *config) {
+				c.Listen, c.Timeout, c.Workers, c.Backend, c.CacheSize, c.LogFormat = ":9000", 30*time.Second, 8, backendCached, 500, "json"
+			},
+		},
+		{
+			name: "Config File From Env",
+			env:  map[string]string{envConfig: file},
+			expected: func(c *config) {
+				c.Listen, c.Timeout, c.Workers, c.Backend, c.CacheSize, c.LogFormat = ":9000", 30*time.Second, 8, backendCached, 500, "json"
+			},
+		},
+		{
+			name: "Env Overrides File",
+			args: []string{"-config", file},
+			env:  map[string]string{"WIKIRACER_LISTEN": ":9001", "WIKIRACER_CACHE_TTL": "5m", "WIKIRACER_ENDPOINT": "http://localhost/w/api.php"},
+			expected: func(c *config) {
+				c.Listen, c.Timeout, c.Workers, c.Backend, c.CacheSize, c.LogFormat = ":9001", 30*time.Second, 8, backendCached, 500, "json"
+				c.CacheTTL, c.Endpoint = 5*time.Minute, "http://localhost/w/api.php"
+			},
+		},
+		{
+			name: "Flags Override Env",
+			args: []string{"-config", file, "-listen", ":9002", "-workers", "0", "-log-level", "DEBUG"},
+			env:  map[string]string{"WIKIRACER_LISTEN": ":9001", "WIKIRACER_LOG_LEVEL": "ERROR"},
+			expected: func(c *config) {
+				c.Listen, c.Timeout, c.Workers, c.Backend, c.CacheSize, c.LogFormat = ":9002", 30*time.Second, 0, backendCached, 500, "json"
+				c.LogLevel = "DEBUG"
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		actual, err := loadConfig(testCase.args, func(key string) string {
+			return testCase.env[key]
+		})
+		if err != nil {
+			t.Errorf("Test case %q failed. Unexpected error: %s", testCase.name, err)
+			continue
+		}
+
+		expected := defaultConfig()
+		testCase.expected(expected)
+		if !reflect.DeepEqual(expected, actual) {
+			t.Errorf("Test case %q failed.\nExpected: %+v\nActual: %+v", testCase.name, expected, actual)
+		}
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wikiracer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	unknown := filepath.Join(dir, "unknown.json")
+	if err := ioutil.WriteFile(unknown, []byte(`{"port": 8080}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var testCases = []struct {
+		name string
+		args []string
+		env  map[string]string
+	}{
+		{name: "Missing File", args: []string{"-config", filepath.Join(dir, "missing.json")}},
+		{name: "Unknown Setting", args: []string{"-config", unknown}},
+		{name: "Invalid Duration", env: map[string]string{"WIKIRACER_TIMEOUT": "soon"}},
+		{name: "Unknown Backend", args: []string{"-backend", "dump"}},
+		{name: "Offline Without Dump", args: []string{"-backend", backendOffline}},
+		{name: "Unknown Crawler", args: []string{"-crawler", "bidirectional"}},
+		{name: "Timeout Above Maximum", args: []string{"-timeout", "1h"}},
+		{name: "Negative Workers", args: []string{"-workers", "-1"}},
+		{name: "Result Cache Without Size", args: []string{"-result-cache-size", "0"}},
+		{name: "Negative Max Pages", args: []string{"-max-pages", "-1"}},
+		{name: "Negative Max Races", args: []string{"-max-races", "-1"}},
+		{name: "Rate Limit Without Burst", args: []string{"-rate-limit", "10", "-rate-burst", "0"}},
+		{name: "Unknown Log Format", args: []string{"-log-format", "xml"}},
+	}
+
+	for _, testCase := range testCases {
+		if _, err := loadConfig(testCase.args, func(key string) string { return testCase.env[key] }); err == nil {
+			t.Errorf("Test case %q failed. Expected an error", testCase.name)
+		}
+	}
+}
+
+func TestOfflineBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wikiracer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	dump := filepath.Join(dir, "wiki.dot")
+	if err := ioutil.WriteFile(dump, []byte(`digraph { A -> B -> C; }`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(b *backend) {
+		wikiBackend = b
+	}(wikiBackend)
+
+	cfg := defaultConfig()
+	cfg.Backend, cfg.Dump = backendOffline, dump
+	if wikiBackend, err = newBackend(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	racer, origin, destination, err := setupRace(map[string][]string{queryParameterOrigin: {"A"}, queryParameterDestination: {"C"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result := racer.FindPath(ctx, origin, destination)
+	if expected := "A -> B -> C"; string(result.Path) != expected || result.Err != nil {
+		t.Errorf("Mismatch result. Expected: %q. Actual: %s", expected, result)
+	}
+
+	if _, _, _, err := setupRace(map[string][]string{queryParameterOrigin: {"A"}, queryParameterDestination: {"C"}, queryParameterLinks: {linksProse}}); err == nil {
+		t.Error("Expected the prose links to be rejected by the offline backend")
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/ihcsim/wikiracer/internal/crawler"
+)
+
+const (
+	queryParameterSkip         = "skip"
+	queryParameterAllow        = "allow"
+	queryParameterDeny         = "deny"
+	queryParameterDenyCategory = "deny_category"
+
+	skipDisambiguation = "disambiguation"
+	skipDates          = "dates"
+	skipLists          = "lists"
+)
+
+// parseFilters builds the crawler filters specified by the query parameters.
+// It returns true if any of the filters relies on the pages' metadata.
+func parseFilters(query url.Values) ([]crawler.Filter, bool, error) {
+	var (
+		filters  []crawler.Filter
+		metadata bool
+	)
+
+	for _, value := range query[queryParameterSkip] {
+		for _, skip := range strings.Split(value, ",") {
+			switch strings.TrimSpace(skip) {
+			case skipDisambiguation:
+				filters = append(filters, crawler.SkipDisambiguation)
+				metadata = true
+			case skipDates:
+				filters = append(filters, crawler.SkipDates)
+			case skipLists:
+				filters = append(filters, crawler.SkipLists)
+			case "":
+			default:
+				return nil, false, fmt.Errorf("Unknown %s filter: %q", queryParameterSkip, skip)
+			}
+		}
+	}
+
+	allow, err := compile(query[queryParameterAllow])
+	if err != nil {
+		return nil, false, err
+	}
+
+	deny, err := compile(query[queryParameterDeny])
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(allow) > 0 || len(deny) > 0 {
+		filters = append(filters, crawler.Titles(allow, deny))
+	}
+
+	if categories := query[queryParameterDenyCategory]; len(categories) > 0 {
+		filters = append(filters, crawler.Categories(categories...))
+		metadata = true
+	}
+
+	return filters, metadata, nil
+}
+
+func compile(patterns []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		regex, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid title pattern %q: %s", pattern, err)
+		}
+		compiled = append(compiled, regex)
+	}
+
+	return compiled, nil
+}
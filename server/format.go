@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ihcsim/wikiracer"
+	"github.com/ihcsim/wikiracer/errors"
+	"github.com/ihcsim/wikiracer/internal/puzzle"
+)
+
+const (
+	queryParameterFormat = "format"
+
+	// the response formats.
+	formatText = "text"
+	formatJSON = "json"
+
+	contentTypeJSON = "application/json"
+)
+
+// pathResponse is the JSON representation of a race result.
+type pathResponse struct {
+	Origin      string      `json:"origin"`
+	Destination string      `json:"destination"`
+	Path        []pathEntry `json:"path"`
+	Hops        int         `json:"hops"`
+	DurationMs  int64       `json:"duration_ms"`
+	Shortest    bool        `json:"shortest"`
+	Stats       crawlStats  `json:"stats"`
+}
+
+type pathEntry struct {
+	Title string `json:"title"`
+	ID    int    `json:"id,omitempty"`
+}
+
+type crawlStats struct {
+	PagesVisited   int      `json:"pages_visited"`
+	Batches        int      `json:"batches"`
+	SkippedBatches int      `json:"skipped_batches"`
+	APICalls       int      `json:"api_calls"`
+	Warnings       []string `json:"warnings"`
+}
+
+// puzzleResponse is the JSON representation of a puzzle.
+type puzzleResponse struct {
+	Origin      string `json:"origin"`
+	Destination string `json:"destination"`
+	Hops        int    `json:"hops"`
+}
+
+// errorResponse is the JSON representation of an error.
+type errorResponse struct {
+	Error errorBody `json:"error"`
+}
+
+type errorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+
+	// Budget is only set if the budget of the race ran out.
+	Budget *budgetResponse `json:"budget,omitempty"`
+}
+
+// budgetResponse is the JSON representation of an exhausted budget.
+type budgetResponse struct {
+	Exhausted    string `json:"exhausted"`
+	Limit        int    `json:"limit"`
+	PagesVisited int    `json:"pages_visited"`
+	APICalls     int    `json:"api_calls"`
+}
+
+// newErrorBody returns the JSON representation of err, with the given machine-readable code.
+func newErrorBody(code string, err error) *errorBody {
+	body := &errorBody{Code: code, Message: err.Error()}
+	if e, ok := err.(errors.BudgetExhausted); ok {
+		body.Budget = &budgetResponse{Exhausted: e.Budget, Limit: e.Limit, PagesVisited: e.PagesVisited, APICalls: e.APICalls}
+	}
+	return body
+}
+
+// responseFormat returns the format selected by the format query parameter, or by the Accept header.
+// Plain text is the default format.
+func responseFormat(req *http.Request) (string, error) {
+	switch format := req.URL.Query().Get(queryParameterFormat); format {
+	case formatText, formatJSON:
+		return format, nil
+	case "":
+	default:
+		return formatText, invalidParameter{fmt.Errorf("Unknown %s: %q", queryParameterFormat, format)}
+	}
+
+	for _, accept := range strings.Split(req.Header.Get("Accept"), ",") {
+		if mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept)); err == nil && mediaType == contentTypeJSON {
+			return formatJSON, nil
+		}
+	}
+
+	return formatText, nil
+}
+
+// writeResult writes the result of the race from origin to destination in the given format.
+func writeResult(w http.ResponseWriter, format, origin, destination string, result *wikiracer.Result) {
+	if format != formatJSON {
+		response(w, http.StatusOK, []byte(fmt.Sprintf("%s", result)))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newPathResponse(origin, destination, result))
+}
+
+// writePuzzle writes the puzzle in the given format.
+func writePuzzle(w http.ResponseWriter, format string, p *puzzle.Puzzle) {
+	if format != formatJSON {
+		response(w, http.StatusOK, []byte(p.String()))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, &puzzleResponse{Origin: p.Origin, Destination: p.Destination, Hops: p.Hops})
+}
+
+func newPathResponse(origin, destination string, result *wikiracer.Result) *pathResponse {
+	body := &pathResponse{
+		Origin:      origin,
+		Destination: destination,
+		Path:        []pathEntry{},
+		Hops:        result.Hops(),
+		DurationMs:  result.Duration.Nanoseconds() / 1e6,
+		Shortest:    result.Shortest(),
+		Stats: crawlStats{
+			PagesVisited:   result.Visited,
+			Batches:        result.Batches,
+			SkippedBatches: len(result.Skipped),
+			APICalls:       result.APICalls,
+			Warnings:       []string{},
+		},
+	}
+
+	for _, page := range result.Pages {
+		body.Path = append(body.Path, pathEntry{Title: page.Title, ID: page.ID})
+	}
+
+	for _, warning := range result.Warnings {
+		body.Stats.Warnings = append(body.Stats.Warnings, warning.String())
+	}
+
+	return body
+}
+
+// writeError writes err in the given format, with the status code that matches its type.
+// The machine-readable code of the error is only included in the JSON format.
+func writeError(w http.ResponseWriter, format string, err error) {
+	status, code := classify(err)
+	if e, ok := err.(tooManyRequests); ok {
+		w.Header().Set("Retry-After", retryAfterSeconds(e.retryAfter))
+	} else if status == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+	}
+
+	if format != formatJSON {
+		response(w, status, []byte(err.Error()))
+		return
+	}
+
+	writeJSON(w, status, errorResponse{Error: *newErrorBody(code, err)})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	content, err := json.Marshal(body)
+	if err != nil {
+		response(w, http.StatusInternalServerError, []byte(err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypeJSON)
+	response(w, status, content)
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ihcsim/wikiracer/internal/puzzle"
+	"github.com/ihcsim/wikiracer/test"
+	"github.com/ihcsim/wikiracer/test/apiserver"
+)
+
+func TestResponseFormat(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		format   string
+		accept   string
+		expected string
+		invalid  bool
+	}{
+		{name: "Default", expected: formatText},
+		{name: "Accept JSON", accept: "text/html, application/json;q=0.9", expected: formatJSON},
+		{name: "Accept Text", accept: "text/plain", expected: formatText},
+		{name: "Format JSON", format: formatJSON, expected: formatJSON},
+		{name: "Format Text", format: formatText, accept: contentTypeJSON, expected: formatText},
+		{name: "Unknown Format", format: "xml", invalid: true},
+	}
+
+	for _, testCase := range testCases {
+		req := httptest.NewRequest(http.MethodGet, "/wikiracer?"+url.Values{queryParameterFormat: []string{testCase.format}}.Encode(), nil)
+		if testCase.accept != "" {
+			req.Header.Set("Accept", testCase.accept)
+		}
+
+		actual, err := responseFormat(req)
+		if testCase.invalid {
+			if _, ok := err.(invalidParameter); !ok {
+				t.Errorf("Test case %q failed. Expected an invalidParameter error. Actual %v", testCase.name, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Test case %q failed. Unexpected error: %s", testCase.name, err)
+		}
+
+		if actual != testCase.expected {
+			t.Errorf("Test case %q failed. Mismatch format. Expected %q. Actual %q", testCase.name, testCase.expected, actual)
+		}
+	}
+}
+
+func TestWriteResult(t *testing.T) {
+	stub := httptest.NewServer(apiserver.New(test.NewMockWiki()))
+	defer stub.Close()
+	defer stubBackend(t, stub.URL)()
+
+	server := httptest.NewServer(http.HandlerFunc(timedFindPath))
+	defer server.Close()
+
+	t.Run("JSON", func(t *testing.T) {
+		for _, format := range []struct {
+			query  string
+			accept string
+		}{
+			{query: formatJSON},
+			{accept: contentTypeJSON},
+		} {
+			res := requestRace(t, server.URL, url.Values{queryParameterOrigin: []string{"Mike Tyson"}, queryParameterDestination: []string{"Apepi"}, queryParameterFormat: []string{format.query}}, format.accept)
+			defer res.Body.Close()
+
+			if res.StatusCode != http.StatusOK {
+				t.Errorf("Mismatch status code. Expected %d. Actual %d", http.StatusOK, res.StatusCode)
+			}
+
+			if actual := res.Header.Get("Content-Type"); actual != contentTypeJSON {
+				t.Errorf("Mismatch content type. Expected %q. Actual %q", contentTypeJSON, actual)
+			}
+
+			// the fields are decoded by name, so that a renamed JSON field is detected.
+			var body map[string]interface{}
+			if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, field := range []string{"origin", "destination", "path", "hops", "duration_ms", "shortest", "stats"} {
+				if _, ok := body[field]; !ok {
+					t.Errorf("Missing field %q in %v", field, body)
+				}
+			}
+
+			if body["origin"] != "Mike Tyson" || body["destination"] != "Apepi" || body["hops"] != float64(2) {
+				t.Errorf("Mismatch race. Actual %v", body)
+			}
+
+			if _, ok := body["shortest"].(bool); !ok {
+				t.Errorf("Mismatch shortest. Expected a boolean. Actual %v", body["shortest"])
+			}
+
+			if _, ok := body["duration_ms"].(float64); !ok {
+				t.Errorf("Mismatch duration. Expected a number. Actual %v", body["duration_ms"])
+			}
+
+			expected := []interface{}{
+				map[string]interface{}{"title": "Mike Tyson", "id": float64(1003)},
+				map[string]interface{}{"title": "Alexander the Great", "id": float64(1000)},
+				map[string]interface{}{"title": "Apepi"},
+			}
+			if !reflect.DeepEqual(body["path"], expected) {
+				t.Errorf("Mismatch path.\nExpected: %v\nActual: %v", expected, body["path"])
+			}
+
+			stats, _ := body["stats"].(map[string]interface{})
+			for _, field := range []string{"pages_visited", "batches", "skipped_batches", "api_calls", "warnings"} {
+				if _, ok := stats[field]; !ok {
+					t.Errorf("Missing stats field %q in %v", field, stats)
+				}
+			}
+		}
+	})
+
+	t.Run("Text", func(t *testing.T) {
+		res := requestRace(t, server.URL, url.Values{queryParameterOrigin: []string{"Mike Tyson"}, queryParameterDestination: []string{"Apepi"}, queryParameterFormat: []string{formatText}}, contentTypeJSON)
+		defer res.Body.Close()
+
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if expected := `Path: "Mike Tyson -> Alexander the Great -> Apepi"`; res.StatusCode != http.StatusOK || !strings.HasPrefix(string(body), expected) {
+			t.Errorf("Mismatch response.\nExpected: %d %s\nActual: %d %s", http.StatusOK, expected, res.StatusCode, body)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		var testCases = []struct {
+			name   string
+			query  url.Values
+			status int
+			code   string
+		}{
+			{name: "Unknown Format", query: url.Values{queryParameterOrigin: []string{"Mike Tyson"}, queryParameterDestination: []string{"Apepi"}, queryParameterFormat: []string{"xml"}}, status: http.StatusBadRequest},
+			{name: "Page Not Found", query: url.Values{queryParameterOrigin: []string{"Mike Tyson"}, queryParameterDestination: []string{"Missing Page"}, queryParameterFormat: []string{formatJSON}}, status: http.StatusNotFound, code: codePageNotFound},
+			{name: "Empty Input", query: url.Values{queryParameterOrigin: []string{"Mike Tyson"}, queryParameterFormat: []string{formatJSON}}, status: http.StatusBadRequest, code: codeInvalidInput},
+		}
+
+		for _, testCase := range testCases {
+			res := requestRace(t, server.URL, testCase.query, "")
+			defer res.Body.Close()
+
+			if res.StatusCode != testCase.status {
+				t.Errorf("Test case %q failed. Mismatch status code. Expected %d. Actual %d", testCase.name, testCase.status, res.StatusCode)
+			}
+
+			// an unknown format is reported in the default text format.
+			if testCase.code == "" {
+				continue
+			}
+
+			var actual errorResponse
+			if err := json.NewDecoder(res.Body).Decode(&actual); err != nil {
+				t.Fatal(err)
+			}
+
+			if actual.Error.Code != testCase.code || actual.Error.Message == "" {
+				t.Errorf("Test case %q failed. Mismatch error. Expected code %q. Actual %+v", testCase.name, testCase.code, actual.Error)
+			}
+		}
+	})
+}
+
+func TestWritePuzzle(t *testing.T) {
+	p := &puzzle.Puzzle{Origin: "Mike Tyson", Destination: "Apepi", Hops: 2}
+
+	t.Run("JSON", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		writePuzzle(recorder, formatJSON, p)
+
+		if actual := recorder.Header().Get("Content-Type"); actual != contentTypeJSON {
+			t.Errorf("Mismatch content type. Expected %q. Actual %q", contentTypeJSON, actual)
+		}
+
+		var body map[string]interface{}
+		if err := json.NewDecoder(recorder.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := map[string]interface{}{"origin": "Mike Tyson", "destination": "Apepi", "hops": float64(2)}
+		if !reflect.DeepEqual(body, expected) {
+			t.Errorf("Mismatch body.\nExpected: %v\nActual: %v", expected, body)
+		}
+	})
+
+	t.Run("Text", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		writePuzzle(recorder, formatText, p)
+
+		if expected := `Origin: "Mike Tyson", Destination: "Apepi", Hops: 2`; recorder.Body.String() != expected {
+			t.Errorf("Mismatch body.\nExpected: %s\nActual: %s", expected, recorder.Body)
+		}
+	})
+
+	t.Run("Invalid Hops", func(t *testing.T) {
+		stub := httptest.NewServer(apiserver.New(test.NewMockWiki()))
+		defer stub.Close()
+		defer stubBackend(t, stub.URL)()
+
+		req := httptest.NewRequest(http.MethodGet, "/puzzle?hops=0", nil)
+		req.Header.Set("Accept", contentTypeJSON)
+		recorder := httptest.NewRecorder()
+		generatePuzzle(recorder, req)
+
+		var actual errorResponse
+		if err := json.NewDecoder(recorder.Body).Decode(&actual); err != nil {
+			t.Fatal(err)
+		}
+
+		if recorder.Code != http.StatusBadRequest || actual.Error.Code != codeInvalidInput {
+			t.Errorf("Mismatch error. Expected %d %q. Actual %d %+v", http.StatusBadRequest, codeInvalidInput, recorder.Code, actual.Error)
+		}
+	})
+}
+
+// requestRace sends a race request with the given query and Accept header to the server at endpoint.
+func requestRace(t *testing.T, endpoint string, query url.Values, accept string) *http.Response {
+	req, err := http.NewRequest(http.MethodGet, endpoint+"/wikiracer?"+query.Encode(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ihcsim/wikiracer/internal/metrics"
+)
+
+const (
+	// headerAPIKey is the header that identifies the clients with an API key. The clients without a known API key are identified by their IP.
+	headerAPIKey = "X-API-Key"
+
+	// headerForwardedFor and headerRealIP are the headers that the reverse proxies use to forward the IP of the clients.
+	headerForwardedFor = "X-Forwarded-For"
+	headerRealIP       = "X-Real-IP"
+
+	// busyRetryAfter is the value of the Retry-After header, when a race is rejected because too many races are running.
+	busyRetryAfter = 5 * time.Second
+
+	// sweepInterval is the interval between the removals of the idle token buckets.
+	sweepInterval = time.Minute
+
+	// the reasons why a request is rejected.
+	reasonRateLimit   = "rate_limit"
+	reasonConcurrency = "concurrency"
+)
+
+var (
+	rejectedRequests = metrics.NewCounter("wikiracer_rejected_requests_total", "Number of requests rejected by the limits of the server, by reason.", "reason")
+	queuedRaces      = metrics.NewGauge("wikiracer_queued_races", "Number of races waiting for a running race to complete.")
+)
+
+// serverLimits are the limits applied to the requests which start a race. They are unbounded until main configures them.
+var serverLimits = &limits{}
+
+// tooManyRequests is the error used when a request is rejected by the limits of the server.
+type tooManyRequests struct {
+	reason     string
+	retryAfter time.Duration
+}
+
+// Error returns the string representation of the tooManyRequests error.
+func (e tooManyRequests) Error() string {
+	if e.reason == reasonRateLimit {
+		return "Too many requests. Retry in " + retryAfterSeconds(e.retryAfter) + "s"
+	}
+	return "Too many races are running. Retry in " + retryAfterSeconds(e.retryAfter) + "s"
+}
+
+// limits caps the number of races which run concurrently, and the rate of the races started by every client.
+// When all the race slots are taken, up to queueSize races wait for a slot, for up to queueTimeout. The other races are rejected.
+type limits struct {
+	// slots has a slot for every race which can run concurrently. It's nil if the number of races is unbounded.
+	slots        chan struct{}
+	queueSize    int
+	queueTimeout time.Duration
+
+	mux    sync.Mutex
+	queued int
+
+	rate    *rateLimiter
+	apiKeys map[string]bool
+
+	// trustedProxies are the networks of the reverse proxies whose forwarding headers identify the clients.
+	trustedProxies []*net.IPNet
+}
+
+func newLimits(cfg *config) *limits {
+	l := &limits{
+		queueSize:    cfg.RaceQueue,
+		queueTimeout: cfg.QueueTimeout,
+		apiKeys:      map[string]bool{},
+	}
+
+	if cfg.MaxRaces > 0 {
+		l.slots = make(chan struct{}, cfg.MaxRaces)
+	}
+
+	if cfg.RateLimit > 0 {
+		l.rate = newRateLimiter(float64(cfg.RateLimit)/60, cfg.RateBurst)
+	}
+
+	for _, key := range strings.Split(cfg.APIKeys, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			l.apiKeys[key] = true
+		}
+	}
+
+	// the trusted proxies are validated when the config is loaded.
+	l.trustedProxies, _ = parseNetworks(cfg.TrustedProxies)
+
+	return l
+}
+
+// admit checks the rate limit of the client of req, and waits for a race slot.
+// The returned function releases the slot. It must be called when the race completes.
+func (l *limits) admit(req *http.Request) (func(), error) {
+	if l.rate != nil {
+		if wait, ok := l.rate.take(l.client(req), time.Now()); !ok {
+			rejectedRequests.Inc(reasonRateLimit)
+			return nil, tooManyRequests{reason: reasonRateLimit, retryAfter: wait}
+		}
+	}
+
+	return l.acquire(req.Context())
+}
+
+// acquire waits for a race slot, unless the queue is full. It returns an error if the queue timeout passes, or if ctx is canceled, before a slot is available.
+func (l *limits) acquire(ctx context.Context) (func(), error) {
+	if l.slots == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return l.release, nil
+	default:
+	}
+
+	l.mux.Lock()
+	if l.queued >= l.queueSize {
+		l.mux.Unlock()
+		rejectedRequests.Inc(reasonConcurrency)
+		return nil, tooManyRequests{reason: reasonConcurrency, retryAfter: busyRetryAfter}
+	}
+	l.queued++
+	l.mux.Unlock()
+	queuedRaces.Inc()
+
+	defer func() {
+		l.mux.Lock()
+		l.queued--
+		l.mux.Unlock()
+		queuedRaces.Dec()
+	}()
+
+	// a zero queue timeout waits until the client gives up.
+	var expired <-chan time.Time
+	if l.queueTimeout > 0 {
+		timer := time.NewTimer(l.queueTimeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return l.release, nil
+	case <-expired:
+		rejectedRequests.Inc(reasonConcurrency)
+		return nil, tooManyRequests{reason: reasonConcurrency, retryAfter: busyRetryAfter}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (l *limits) release() {
+	<-l.slots
+}
+
+// client returns the key of the token bucket of the client of req. It's the API key of the client if it's known, or its IP otherwise.
+// If the request is sent by a trusted proxy, the IP of the client is the last untrusted IP of the X-Forwarded-For header, or the X-Real-IP header if all the IPs are trusted.
+// The IPs before the last untrusted IP are ignored, since the client can forge them.
+func (l *limits) client(req *http.Request) string {
+	if key := req.Header.Get(headerAPIKey); l.apiKeys[key] {
+		return "key:" + key
+	}
+
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+
+	if !l.trusted(host) {
+		return "ip:" + host
+	}
+
+	// every proxy appends the IP that it received the request from.
+	forwarded := strings.Split(strings.Join(req.Header[headerForwardedFor], ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		if ip := strings.TrimSpace(forwarded[i]); ip != "" && !l.trusted(ip) {
+			return "ip:" + ip
+		}
+	}
+
+	if ip := strings.TrimSpace(req.Header.Get(headerRealIP)); ip != "" {
+		return "ip:" + ip
+	}
+
+	return "ip:" + host
+}
+
+// trusted returns true if ip belongs to one of the trusted proxies.
+func (l *limits) trusted(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+
+	for _, network := range l.trustedProxies {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseNetworks parses the comma-separated IPs and CIDRs of networks. An IP is a network of one address.
+func parseNetworks(networks string) ([]*net.IPNet, error) {
+	var parsed []*net.IPNet
+	for _, network := range strings.Split(networks, ",") {
+		if network = strings.TrimSpace(network); network == "" {
+			continue
+		}
+
+		if !strings.Contains(network, "/") {
+			ip := net.ParseIP(network)
+			if ip == nil {
+				return nil, fmt.Errorf("Invalid IP: %q", network)
+			}
+
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			parsed = append(parsed, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(network)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, ipNet)
+	}
+
+	return parsed, nil
+}
+
+// rateLimiter has a token bucket for every client. A bucket holds up to burst tokens, and is refilled at rate tokens per second.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+
+	mux     sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: map[string]*bucket{},
+		swept:   time.Now(),
+	}
+}
+
+// take takes a token from the bucket of client at now. If the bucket is empty, it returns false, and the time until the next token.
+func (r *rateLimiter) take(client string, now time.Time) (time.Duration, bool) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if now.Sub(r.swept) >= sweepInterval {
+		r.sweep(now)
+	}
+
+	b, ok := r.buckets[client]
+	if !ok {
+		b = &bucket{tokens: r.burst, last: now}
+		r.buckets[client] = b
+	}
+
+	b.tokens = math.Min(r.burst, b.tokens+now.Sub(b.last).Seconds()*r.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / r.rate * float64(time.Second)), false
+	}
+
+	b.tokens--
+	return 0, true
+}
+
+// sweep removes the buckets which are full at now. They are recreated full when they are needed. The caller must hold the mutex.
+func (r *rateLimiter) sweep(now time.Time) {
+	for client, b := range r.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*r.rate >= r.burst {
+			delete(r.buckets, client)
+		}
+	}
+	r.swept = now
+}
+
+// retryAfterSeconds returns the value of the Retry-After header for d. It's rounded up to the next second.
+func retryAfterSeconds(d time.Duration) string {
+	seconds := int(math.Ceil(d.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return strconv.Itoa(seconds)
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ihcsim/wikiracer/test"
+	"github.com/ihcsim/wikiracer/test/apiserver"
+)
+
+func TestRateLimiter(t *testing.T) {
+	var (
+		limiter = newRateLimiter(1, 2)
+		start   = time.Now()
+	)
+
+	var testCases = []struct {
+		client  string
+		elapsed time.Duration
+		allowed bool
+		wait    time.Duration
+	}{
+		{client: "ip:10.0.0.1", allowed: true},
+		{client: "ip:10.0.0.1", allowed: true},
+		{client: "ip:10.0.0.1", allowed: false, wait: time.Second},
+		{client: "ip:10.0.0.2", allowed: true},
+		{client: "ip:10.0.0.1", elapsed: 500 * time.Millisecond, allowed: false, wait: 500 * time.Millisecond},
+		{client: "ip:10.0.0.1", elapsed: time.Second, allowed: true},
+		{client: "ip:10.0.0.1", elapsed: time.Second, allowed: false, wait: time.Second},
+	}
+
+	for id, testCase := range testCases {
+		wait, allowed := limiter.take(testCase.client, start.Add(testCase.elapsed))
+		if allowed != testCase.allowed {
+			t.Errorf("Test case %d failed. Mismatch allowed. Expected %t. Actual %t", id, testCase.allowed, allowed)
+		}
+
+		if wait != testCase.wait {
+			t.Errorf("Test case %d failed. Mismatch wait. Expected %s. Actual %s", id, testCase.wait, wait)
+		}
+	}
+
+	limiter.sweep(start.Add(time.Hour))
+	if len(limiter.buckets) != 0 {
+		t.Errorf("Mismatch number of buckets after sweep. Expected 0. Actual %d", len(limiter.buckets))
+	}
+}
+
+func TestAcquire(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.MaxRaces, cfg.RaceQueue, cfg.QueueTimeout = 1, 1, 100*time.Millisecond
+	l := newLimits(cfg)
+
+	release, err := l.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the second race waits in the queue until the first one completes.
+	acquired := make(chan error, 1)
+	go func() {
+		release, err := l.acquire(context.Background())
+		if err == nil {
+			release()
+		}
+		acquired <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+
+	// the queue is full.
+	if _, err := l.acquire(context.Background()); err == nil {
+		t.Error("Expected the race to be rejected when the queue is full")
+	} else if e, ok := err.(tooManyRequests); !ok || e.reason != reasonConcurrency {
+		t.Errorf("Mismatch error. Expected tooManyRequests. Actual %#v", err)
+	}
+
+	release()
+	if err := <-acquired; err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+
+	// the queued race gives up when the queue timeout passes.
+	release, err = l.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	if _, err := l.acquire(context.Background()); err == nil {
+		t.Error("Expected the race to be rejected when the queue timeout passes")
+	}
+}
+
+func TestClient(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.APIKeys, cfg.TrustedProxies = "secret", "10.0.0.1, 10.1.0.0/16"
+	l := newLimits(cfg)
+
+	var testCases = []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		expected   string
+	}{
+		{name: "Direct", remoteAddr: "192.0.2.1:5000", expected: "ip:192.0.2.1"},
+		{name: "API Key", remoteAddr: "192.0.2.1:5000", headers: map[string]string{headerAPIKey: "secret"}, expected: "key:secret"},
+		{name: "Untrusted Proxy", remoteAddr: "192.0.2.1:5000", headers: map[string]string{headerForwardedFor: "198.51.100.7"}, expected: "ip:192.0.2.1"},
+		{name: "Trusted Proxy", remoteAddr: "10.0.0.1:5000", headers: map[string]string{headerForwardedFor: "198.51.100.7"}, expected: "ip:198.51.100.7"},
+		{name: "Chained Proxies", remoteAddr: "10.0.0.1:5000", headers: map[string]string{headerForwardedFor: "198.51.100.7, 10.1.2.3"}, expected: "ip:198.51.100.7"},
+		{name: "Forged Forwarded IP", remoteAddr: "10.0.0.1:5000", headers: map[string]string{headerForwardedFor: "203.0.113.9, 198.51.100.7"}, expected: "ip:198.51.100.7"},
+		{name: "Real IP", remoteAddr: "10.0.0.1:5000", headers: map[string]string{headerRealIP: "198.51.100.7"}, expected: "ip:198.51.100.7"},
+		{name: "No Forwarding Headers", remoteAddr: "10.0.0.1:5000", expected: "ip:10.0.0.1"},
+	}
+
+	for _, testCase := range testCases {
+		req := httptest.NewRequest(http.MethodGet, "/wikiracer", nil)
+		req.RemoteAddr = testCase.remoteAddr
+		for header, value := range testCase.headers {
+			req.Header.Set(header, value)
+		}
+
+		if actual := l.client(req); actual != testCase.expected {
+			t.Errorf("Test case %q failed. Mismatch client. Expected %q. Actual %q", testCase.name, testCase.expected, actual)
+		}
+	}
+
+	if _, err := parseNetworks("10.0.0.1, proxy"); err == nil {
+		t.Error("Expected error didn't occur")
+	}
+}
+
+func TestTooManyRequests(t *testing.T) {
+	stub := httptest.NewServer(apiserver.New(test.NewMockWiki()))
+	defer stub.Close()
+	defer stubBackend(t, stub.URL)()
+
+	defer func(l *limits) {
+		serverLimits = l
+	}(serverLimits)
+
+	cfg := defaultConfig()
+	cfg.RateLimit, cfg.RateBurst, cfg.APIKeys = 1, 1, "secret"
+	serverLimits = newLimits(cfg)
+
+	server := httptest.NewServer(http.HandlerFunc(timedFindPath))
+	defer server.Close()
+
+	var (
+		query   = url.Values{queryParameterOrigin: []string{"Mike Tyson"}, queryParameterDestination: []string{"Apepi"}, queryParameterFormat: []string{formatJSON}}
+		invalid = url.Values{queryParameterOrigin: []string{"Mike Tyson"}, queryParameterFormat: []string{formatJSON}}
+	)
+
+	// the invalid requests are rejected before they spend the rate limit of the client.
+	var testCases = []struct {
+		name       string
+		query      url.Values
+		apiKey     string
+		status     int
+		retryAfter string
+	}{
+		{name: "Invalid", query: invalid, status: http.StatusBadRequest},
+		{name: "First", status: http.StatusOK},
+		{name: "Rate Limited", status: http.StatusTooManyRequests, retryAfter: "60"},
+		{name: "Unknown API Key", apiKey: "guess", status: http.StatusTooManyRequests, retryAfter: "60"},
+		{name: "Known API Key", apiKey: "secret", status: http.StatusOK},
+	}
+
+	for _, testCase := range testCases {
+		if testCase.query == nil {
+			testCase.query = query
+		}
+
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/wikiracer?"+testCase.query.Encode(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if testCase.apiKey != "" {
+			req.Header.Set(headerAPIKey, testCase.apiKey)
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+
+		if res.StatusCode != testCase.status {
+			t.Errorf("Test case %q failed. Mismatch status code. Expected %d. Actual %d", testCase.name, testCase.status, res.StatusCode)
+		}
+
+		if actual := res.Header.Get("Retry-After"); actual != testCase.retryAfter {
+			t.Errorf("Test case %q failed. Mismatch Retry-After. Expected %q. Actual %q", testCase.name, testCase.retryAfter, actual)
+		}
+	}
+}
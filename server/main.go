@@ -4,14 +4,20 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/ihcsim/wikiracer"
 	"github.com/ihcsim/wikiracer/errors"
 	"github.com/ihcsim/wikiracer/internal/crawler"
+	"github.com/ihcsim/wikiracer/internal/metrics"
+	"github.com/ihcsim/wikiracer/internal/puzzle"
 	"github.com/ihcsim/wikiracer/internal/validator"
+	"github.com/ihcsim/wikiracer/internal/wiki"
 	"github.com/ihcsim/wikiracer/internal/wiki/wikipedia"
 	"github.com/ihcsim/wikiracer/log"
 
@@ -21,76 +27,421 @@ import (
 const (
 	queryParameterOrigin      = "origin"
 	queryParameterDestination = "destination"
+	queryParameterHops        = "hops"
+	queryParameterLinks       = "links"
+	queryParameterAsOf        = "as_of"
+	queryParameterTimeout     = "timeout"
+	queryParameterMaxPages    = "max_pages"
+	queryParameterMaxAPICalls = "max_api_calls"
 
-	serverPort = "8080"
-	pprofPort  = "6060"
+	// the values of the links query parameter, which select the links that a player can follow.
+	linksAll   = "all"
+	linksProse = "prose"
+	linksLead  = "lead"
+
+	// randomTitle can be used as the origin or destination to race from or to a random page.
+	randomTitle = "random"
+
+	// metricsPath serves the metrics in the Prometheus text exposition format.
+	metricsPath = "/metrics"
+
+	// envRecord and envReplay are the environment variables that hold the path of a fixture file, to record the requests sent to the wiki, or to replay them.
+	envRecord = "WIKIRACER_RECORD"
+	envReplay = "WIKIRACER_REPLAY"
 )
 
-var timeout = 180 * time.Second
+var (
+	// defaultTimeout is the timeout of the races which don't set the timeout query parameter. maxTimeout caps the timeout query parameter.
+	defaultTimeout = 180 * time.Second
+	maxTimeout     = 10 * time.Minute
+
+	// maxBudget caps the budget set by the max_pages and max_api_calls query parameters. It's also the budget of the races which don't set them.
+	maxBudget crawler.Budget
+
+	// workers is the maximum number of concurrent requests that a race sends to the wiki. It's unbounded if it's 0.
+	workers int
+)
 
 func main() {
-	go func() {
-		log.Instance().Infof("Starting profiling server at port %s...", pprofPort)
-		if err := http.ListenAndServe(":"+pprofPort, nil); err != nil {
+	cfg, err := loadConfig(os.Args[1:], os.Getenv)
+	if err != nil {
+		log.Instance().Fatal(err)
+	}
+
+	if err := log.SetFormat(cfg.LogFormat); err != nil {
+		log.Instance().Fatal(err)
+	}
+
+	if err := log.SetLevel(cfg.LogLevel); err != nil {
+		log.Instance().Fatal(err)
+	}
+
+	defaultTimeout, maxTimeout = cfg.Timeout, cfg.MaxTimeout
+	maxBudget = crawler.Budget{MaxPages: cfg.MaxPages, MaxAPICalls: cfg.MaxAPICalls}
+	workers = cfg.Workers
+	serverLimits = newLimits(cfg)
+	results = newResultCache(cfg.ResultCacheSize, cfg.ResultCacheTTL)
+
+	if cfg.PprofListen != "" {
+		go func() {
+			log.Instance().Infof("Starting profiling server at %s...", cfg.PprofListen)
+			if err := http.ListenAndServe(cfg.PprofListen, nil); err != nil {
+				log.Instance().Fatal(err)
+			}
+		}()
+	}
+
+	// options are the options of the Wikipedia client shared by all the races.
+	var options []wikipedia.Option
+	if cfg.Endpoint != "" {
+		log.Instance().Infof("Using wiki at %s", cfg.Endpoint)
+		options = append(options, wikipedia.WithEndpoint(cfg.Endpoint))
+	}
+
+	transport := wikipedia.DefaultTransport
+	transport.UserAgent = cfg.UserAgent
+	options = append(options, wikipedia.WithTransport(transport))
+
+	credentials, err := wikipedia.CredentialsFromEnv()
+	if err != nil {
+		log.Instance().Fatal(err)
+	}
+
+	if credentials != nil {
+		log.Instance().Infof("Logging in as %s", credentials.Username)
+		options = append(options, wikipedia.WithCredentials(*credentials))
+	}
+
+	switch record, replay := os.Getenv(envRecord), os.Getenv(envReplay); {
+	case record != "" && replay != "":
+		log.Instance().Fatalf("Only one of %s and %s can be set", envRecord, envReplay)
+	case record != "":
+		log.Instance().Infof("Recording requests to %s", record)
+		options = append(options, wikipedia.WithFixture(wikipedia.RecordFixture(record)))
+	case replay != "":
+		fixture, err := wikipedia.ReplayFixture(replay)
+		if err != nil {
 			log.Instance().Fatal(err)
 		}
-	}()
 
-	interrupt := make(chan os.Signal, 1)
-	signal.Notify(interrupt)
+		log.Instance().Infof("Replaying requests from %s", replay)
+		options = append(options, wikipedia.WithFixture(fixture))
+	}
+
+	log.Instance().Infof("Using the %s backend", cfg.Backend)
+	if wikiBackend, err = newBackend(cfg, options...); err != nil {
+		log.Instance().Fatal(err)
+	}
+
+	log.Instance().Infof("Starting up server at %s...", cfg.Listen)
+	http.HandleFunc("/wikiracer", timedFindPath)
+	http.HandleFunc(streamPath, streamFindPath)
+	http.HandleFunc("/puzzle", generatePuzzle)
+	http.HandleFunc(racesPath, handleRaces)
+	http.HandleFunc(racesPath+"/", handleRace)
+	http.Handle(metricsPath, metrics.Default)
+	server := &http.Server{Addr: cfg.Listen}
+
+	stopped := make(chan struct{})
 	go func() {
-		if signal := <-interrupt; signal == os.Interrupt {
-			log.Instance().Info("Stopping server...")
-			os.Exit(0)
+		defer close(stopped)
+
+		interrupt := make(chan os.Signal, 1)
+		signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+		received := <-interrupt
+
+		log.Instance().Infof("Received %s. Stopping server...", received)
+		if err := shutdown(server, cfg.DrainPeriod); err != nil {
+			log.Instance().Errorf("Failed to stop server gracefully. Reason: %q", err)
 		}
 	}()
 
-	log.Instance().Infof("Starting up server at port %s...", serverPort)
-	http.HandleFunc("/wikiracer", timedFindPath)
-	if err := http.ListenAndServe(":"+serverPort, nil); err != nil {
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
 		log.Instance().Fatal(err)
 	}
+
+	<-stopped
+	log.Instance().Info("Server stopped")
 }
 
 func timedFindPath(w http.ResponseWriter, req *http.Request) {
-	wiki, err := wikipedia.NewClient()
+	format, err := responseFormat(req)
+	if err != nil {
+		log.Instance().Errorf("Invalid format. Reason: %q", err)
+		writeError(w, format, err)
+		return
+	}
+
+	// the cached results aren't subjected to the limits, because they don't start a race.
+	query := req.URL.Query()
+	if result, ok := results.get(query, query.Get(queryParameterOrigin), query.Get(queryParameterDestination)); ok {
+		origin, destination := query.Get(queryParameterOrigin), query.Get(queryParameterDestination)
+		log.Instance().Infof("%q -> %q: Served from the result cache", origin, destination)
+		w.Header().Set(headerCache, cacheHit)
+		writeResult(w, format, origin, destination, result)
+		return
+	}
+
+	racer, origin, destination, err := setupRace(query)
+	if err != nil {
+		log.Instance().Errorf("%q -> %q: Failed. Reason: %q", origin, destination, err)
+		writeError(w, format, err)
+		return
+	}
+
+	timeout, err := raceTimeout(query)
+	if err != nil {
+		log.Instance().Errorf("%q -> %q: Failed. Reason: %q", origin, destination, err)
+		writeError(w, format, err)
+		return
+	}
+
+	release, origin, destination, err := admitRace(req, origin, destination)
 	if err != nil {
-		response(w, http.StatusInternalServerError, []byte(err.Error()))
+		log.Instance().Errorf("%q -> %q: Rejected. Reason: %q", origin, destination, err)
+		writeError(w, format, err)
 		return
 	}
+	defer release()
 
+	ctx, cancel := context.WithTimeout(raceContext, timeout)
+	defer cancel()
+
+	result := racer.TimedFindPath(ctx, origin, destination)
+	result.Err = interrupted(result.Err)
+	if result.Err != nil {
+		log.Instance().Errorf("%q -> %q: Failed. Reason: %q", origin, destination, result.Err)
+		writeError(w, format, result.Err)
+		return
+	}
+
+	results.add(query, origin, destination, result)
+	logResult(origin, destination, result)
+	writeResult(w, format, origin, destination, result)
+}
+
+// setupRace validates the query parameters, and creates the racer that they specify. It returns the racer with the origin and destination of the race.
+// The random origin and destination are resolved by admitRace, so that the invalid requests don't send any request to the wiki.
+func setupRace(query url.Values) (*wikiracer.WikiRacer, string, string, error) {
 	var (
-		crawler   = crawler.NewForward(wiki)
-		validator = validator.NewInputValidator(wiki)
+		origin      = query.Get(queryParameterOrigin)
+		destination = query.Get(queryParameterDestination)
 	)
 
-	racer := wikiracer.New(crawler, validator)
+	filters, metadata, err := parseFilters(query)
+	if err != nil {
+		return nil, origin, destination, invalidParameter{err}
+	}
+
+	budget, err := raceBudget(query)
+	if err != nil {
+		return nil, origin, destination, err
+	}
+
+	source, _, err := wikiBackend.source(metadata, query.Get(queryParameterLinks), query.Get(queryParameterAsOf))
+	if err != nil {
+		return nil, origin, destination, err
+	}
 
 	var (
-		ctx, cancel = context.WithTimeout(context.Background(), timeout)
-		origin      = req.URL.Query().Get(queryParameterOrigin)
-		destination = req.URL.Query().Get(queryParameterDestination)
+		crawler   = crawler.NewForward(source, crawler.WithTolerance(crawler.DefaultTolerance), crawler.WithFilters(filters...), crawler.WithWorkers(workers), crawler.WithBudget(budget))
+		validator = validator.NewInputValidator(source)
 	)
-	defer cancel()
 
 	log.Instance().Infof("%q -> %q: Starting...", origin, destination)
 	if origin == "" || destination == "" {
-		err := errors.InvalidEmptyInput{origin, destination}.Error()
-		log.Instance().Errorf("%q -> %q: Failed. Reason: %q", origin, destination, err)
-		response(w, http.StatusBadRequest, []byte(err))
+		return nil, origin, destination, errors.InvalidEmptyInput{Origin: origin, Destination: destination}
+	}
+
+	return wikiracer.New(crawler, validator), origin, destination, nil
+}
+
+// admitRace admits the race of req to the limits of the server, and resolves its random origin and destination.
+// It's called once the parameters of the race are validated, so that the invalid requests don't spend the rate limit of the client, nor a race slot.
+// The returned function releases the race slot. It must be called when the race completes.
+func admitRace(req *http.Request, origin, destination string) (func(), string, string, error) {
+	release, err := serverLimits.admit(req)
+	if err != nil {
+		return nil, origin, destination, err
+	}
+
+	if origin == randomTitle || destination == randomTitle {
+		origin, destination, err = resolveRandom(wikiBackend.randomizer(), origin, destination)
+		if err != nil {
+			release()
+			return nil, origin, destination, err
+		}
+		log.Instance().Infof("%q -> %q: Resolved random pages", origin, destination)
+	}
+
+	return release, origin, destination, nil
+}
+
+// raceTimeout returns the timeout set by the timeout query parameter, capped by maxTimeout. The timeout is a duration like 5s, or a number of seconds.
+// defaultTimeout is returned if the parameter isn't set.
+func raceTimeout(query url.Values) (time.Duration, error) {
+	value := query.Get(queryParameterTimeout)
+	if value == "" {
+		return defaultTimeout, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		seconds, e := strconv.Atoi(value)
+		if e != nil {
+			return 0, invalidParameter{fmt.Errorf("Invalid %s: %q", queryParameterTimeout, value)}
+		}
+		d = time.Duration(seconds) * time.Second
+	}
+
+	if d <= 0 {
+		return 0, invalidParameter{fmt.Errorf("The %s must be positive: %q", queryParameterTimeout, value)}
+	}
+
+	if d > maxTimeout {
+		d = maxTimeout
+	}
+	return d, nil
+}
+
+// raceBudget returns the budget set by the max_pages and max_api_calls query parameters, capped by maxBudget.
+// The limits of maxBudget are used for the parameters which aren't set.
+func raceBudget(query url.Values) (crawler.Budget, error) {
+	budget := maxBudget
+	for _, limit := range []struct {
+		parameter string
+		value     *int
+	}{
+		{parameter: queryParameterMaxPages, value: &budget.MaxPages},
+		{parameter: queryParameterMaxAPICalls, value: &budget.MaxAPICalls},
+	} {
+		value := query.Get(limit.parameter)
+		if value == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return budget, invalidParameter{fmt.Errorf("The %s must be a positive number: %q", limit.parameter, value)}
+		}
+
+		if *limit.value == 0 || n < *limit.value {
+			*limit.value = n
+		}
+	}
+
+	return budget, nil
+}
+
+// logResult logs the diagnostics of a successful race.
+func logResult(origin, destination string, result *wikiracer.Result) {
+	if len(result.Skipped) > 0 {
+		log.Instance().Warningf("%q -> %q: Incomplete search. %s", origin, destination, result.Skipped)
+	}
+
+	if len(result.Warnings) > 0 {
+		log.Instance().Warningf("%q -> %q: %s", origin, destination, result.Warnings)
+	}
+
+	log.Instance().Infof("%q -> %q: SUCCESS. %s", origin, destination, result)
+}
+
+func generatePuzzle(w http.ResponseWriter, req *http.Request) {
+	format, err := responseFormat(req)
+	if err != nil {
+		writeError(w, format, err)
 		return
 	}
 
-	result := racer.TimedFindPath(ctx, origin, destination)
-	if result.Err != nil {
-		err := result.Err.Error()
-		log.Instance().Errorf("%q -> %q: Failed. Reason: %q", origin, destination, err)
-		response(w, http.StatusInternalServerError, []byte(err))
+	source, randomizer, err := wikiBackend.source(false, "", "")
+	if err != nil {
+		writeError(w, format, err)
 		return
 	}
 
-	log.Instance().Infof("%q -> %q: SUCCESS. %s", origin, destination, result)
-	response(w, http.StatusOK, []byte(fmt.Sprintf("%s", result)))
+	hops, err := strconv.Atoi(req.URL.Query().Get(queryParameterHops))
+	if err != nil || hops < 1 {
+		err := errors.InvalidHops{Hops: hops}
+		log.Instance().Errorf("Puzzle generation failed. Reason: %q", err)
+		writeError(w, format, err)
+		return
+	}
+
+	release, err := serverLimits.admit(req)
+	if err != nil {
+		log.Instance().Errorf("Puzzle generation rejected. Reason: %q", err)
+		writeError(w, format, err)
+		return
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(raceContext, defaultTimeout)
+	defer cancel()
+
+	generator := puzzle.NewGenerator(source, randomizer)
+	p, err := generator.Generate(ctx, hops)
+	if err != nil {
+		log.Instance().Errorf("Puzzle generation failed. Reason: %q", err)
+		writeError(w, format, err)
+		return
+	}
+
+	log.Instance().Infof("Puzzle generated. %s", p)
+	writePuzzle(w, format, p)
+}
+
+// linkSource returns the wiki whose links are followed by the crawler, according to the game rules selected by mode.
+// If asOf is set, the wiki returns the revisions of the pages as of that date. Historical races only support the default mode.
+func linkSource(client *wikipedia.Client, mode, asOf string) (wiki.Wiki, error) {
+	if asOf != "" {
+		if mode != "" && mode != linksAll {
+			return nil, fmt.Errorf("The %s mode %q isn't supported in historical races", queryParameterLinks, mode)
+		}
+
+		for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+			if at, err := time.Parse(layout, asOf); err == nil {
+				return wikipedia.NewHistorical(client, at), nil
+			}
+		}
+		return nil, fmt.Errorf("Invalid %s date: %q", queryParameterAsOf, asOf)
+	}
+
+	switch mode {
+	case "", linksAll:
+		return client, nil
+	case linksProse:
+		return wikipedia.NewProse(client, false), nil
+	case linksLead:
+		return wikipedia.NewProse(client, true), nil
+	}
+
+	return nil, fmt.Errorf("Unknown %s mode: %q", queryParameterLinks, mode)
+}
+
+// resolveRandom replaces the origin and destination with randomly selected pages, if they are set to randomTitle.
+func resolveRandom(r wiki.Randomizer, origin, destination string) (string, string, error) {
+	// draw one more page than needed, in case the origin and destination collide.
+	pages, err := r.RandomPages(3)
+	if err != nil {
+		return origin, destination, err
+	}
+
+	for _, page := range pages {
+		if origin == randomTitle && page.Title != destination {
+			origin = page.Title
+			continue
+		}
+
+		if destination == randomTitle && page.Title != origin {
+			destination = page.Title
+		}
+	}
+
+	if origin == randomTitle || destination == randomTitle {
+		return origin, destination, errors.PageNotFound{Page: wiki.Page{Title: randomTitle}}
+	}
+
+	return origin, destination, nil
 }
 
 func response(w http.ResponseWriter, status int, content []byte) {
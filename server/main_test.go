@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ihcsim/wikiracer/errors"
+	"github.com/ihcsim/wikiracer/internal/crawler"
+	"github.com/ihcsim/wikiracer/internal/wiki/wikipedia"
+	"github.com/ihcsim/wikiracer/log"
+	"github.com/ihcsim/wikiracer/test"
+	"github.com/ihcsim/wikiracer/test/apiserver"
+)
+
+// TestMain silences the logs once, since the crawl goroutines of a race can still log after the race has returned.
+func TestMain(m *testing.M) {
+	log.Instance().SetBackend(log.QuietBackend)
+	os.Exit(m.Run())
+}
+
+func TestRaceTimeout(t *testing.T) {
+	var testCases = []struct {
+		value    string
+		expected time.Duration
+		invalid  bool
+	}{
+		{value: "", expected: defaultTimeout},
+		{value: "5s", expected: 5 * time.Second},
+		{value: "90", expected: 90 * time.Second},
+		{value: "24h", expected: maxTimeout},
+		{value: "0", invalid: true},
+		{value: "-5s", invalid: true},
+		{value: "soon", invalid: true},
+	}
+
+	for _, testCase := range testCases {
+		actual, err := raceTimeout(url.Values{queryParameterTimeout: []string{testCase.value}})
+		if testCase.invalid {
+			if _, ok := err.(invalidParameter); !ok {
+				t.Errorf("Test case %q failed. Expected an invalidParameter error. Actual %v", testCase.value, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Test case %q failed. Unexpected error: %s", testCase.value, err)
+		}
+
+		if actual != testCase.expected {
+			t.Errorf("Test case %q failed. Mismatch timeout. Expected %s. Actual %s", testCase.value, testCase.expected, actual)
+		}
+	}
+}
+
+func TestRaceBudget(t *testing.T) {
+	defer func(b crawler.Budget) {
+		maxBudget = b
+	}(maxBudget)
+	maxBudget = crawler.Budget{MaxPages: 1000}
+
+	var testCases = []struct {
+		name     string
+		query    url.Values
+		expected crawler.Budget
+		invalid  bool
+	}{
+		{name: "Defaults", query: url.Values{}, expected: crawler.Budget{MaxPages: 1000}},
+		{name: "Below Maximum", query: url.Values{queryParameterMaxPages: []string{"50"}, queryParameterMaxAPICalls: []string{"10"}}, expected: crawler.Budget{MaxPages: 50, MaxAPICalls: 10}},
+		{name: "Above Maximum", query: url.Values{queryParameterMaxPages: []string{"5000"}}, expected: crawler.Budget{MaxPages: 1000}},
+		{name: "Zero", query: url.Values{queryParameterMaxAPICalls: []string{"0"}}, invalid: true},
+		{name: "Not A Number", query: url.Values{queryParameterMaxPages: []string{"many"}}, invalid: true},
+	}
+
+	for _, testCase := range testCases {
+		actual, err := raceBudget(testCase.query)
+		if testCase.invalid {
+			if _, ok := err.(invalidParameter); !ok {
+				t.Errorf("Test case %q failed. Expected an invalidParameter error. Actual %v", testCase.name, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Test case %q failed. Unexpected error: %s", testCase.name, err)
+		}
+
+		if !reflect.DeepEqual(actual, testCase.expected) {
+			t.Errorf("Test case %q failed. Mismatch budget. Expected %+v. Actual %+v", testCase.name, testCase.expected, actual)
+		}
+	}
+}
+
+func TestBudgetExhausted(t *testing.T) {
+	stub := httptest.NewServer(apiserver.New(test.NewMockWiki()))
+	defer stub.Close()
+	defer stubBackend(t, stub.URL)()
+
+	defer func(b crawler.Budget) {
+		maxBudget = b
+	}(maxBudget)
+	maxBudget = crawler.Budget{}
+
+	server := httptest.NewServer(http.HandlerFunc(timedFindPath))
+	defer server.Close()
+
+	query := url.Values{
+		queryParameterOrigin:      []string{"Mike Tyson"},
+		queryParameterDestination: []string{"Michael Jordan"},
+		queryParameterMaxPages:    []string{"3"},
+		queryParameterFormat:      []string{formatJSON},
+	}
+	res, err := http.Get(server.URL + "/wikiracer?" + query.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Mismatch status code. Expected %d. Actual %d", http.StatusUnprocessableEntity, res.StatusCode)
+	}
+
+	var actual errorResponse
+	if err := json.NewDecoder(res.Body).Decode(&actual); err != nil {
+		t.Fatal(err)
+	}
+
+	if actual.Error.Code != codeBudgetExhausted {
+		t.Errorf("Mismatch error code. Expected %q. Actual %q", codeBudgetExhausted, actual.Error.Code)
+	}
+
+	if budget := actual.Error.Budget; budget == nil || budget.Exhausted != errors.BudgetPages || budget.Limit != 3 || budget.PagesVisited < 3 || budget.APICalls < 1 {
+		t.Errorf("Mismatch budget. Actual %+v", budget)
+	}
+}
+
+// stubBackend replaces wikiBackend with a live backend whose client sends its requests to endpoint.
+// The returned function restores the previous backend. It must be deferred by the caller.
+func stubBackend(t *testing.T, endpoint string) func() {
+	previous := wikiBackend
+
+	b, err := newBackend(defaultConfig(), wikipedia.WithEndpoint(endpoint))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wikiBackend = b
+
+	return func() {
+		wikiBackend = previous
+	}
+}
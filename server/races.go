@@ -0,0 +1,398 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ihcsim/wikiracer"
+	"github.com/ihcsim/wikiracer/log"
+)
+
+const (
+	racesPath            = "/races"
+	queryParameterStatus = "status"
+
+	// raceRetention is how long a race is kept after it's completed.
+	raceRetention = 10 * time.Minute
+
+	// the statuses of a race.
+	raceRunning   = "running"
+	raceSucceeded = "succeeded"
+	raceFailed    = "failed"
+	raceCanceled  = "canceled"
+)
+
+// races are the asynchronous races started with the /races endpoint.
+var races = newRaceStore(raceRetention)
+
+// race is an asynchronous race.
+type race struct {
+	id          string
+	origin      string
+	destination string
+	created     time.Time
+	cancel      context.CancelFunc
+
+	// query holds the options of the race, which key its result in the result cache.
+	query url.Values
+
+	// the fields below are guarded by the mutex of the store.
+	status   string
+	finished time.Time
+	result   *wikiracer.Result
+}
+
+// raceResponse is the JSON representation of an asynchronous race.
+type raceResponse struct {
+	ID          string        `json:"id"`
+	Status      string        `json:"status"`
+	Origin      string        `json:"origin"`
+	Destination string        `json:"destination"`
+	Created     time.Time     `json:"created"`
+	Finished    *time.Time    `json:"finished,omitempty"`
+	Result      *pathResponse `json:"result,omitempty"`
+	Error       *errorBody    `json:"error,omitempty"`
+}
+
+// raceNotFound is the error used when a race doesn't exist, or has expired.
+type raceNotFound struct {
+	ID string
+}
+
+// Error returns the string representation of the raceNotFound error.
+func (e raceNotFound) Error() string {
+	return fmt.Sprintf("%s: %s", "Race not found", e.ID)
+}
+
+// raceStore keeps track of the asynchronous races. The completed races are removed after the retention period.
+type raceStore struct {
+	mux       sync.Mutex
+	races     map[string]*race
+	retention time.Duration
+
+	// running tracks the goroutines of the running races.
+	running sync.WaitGroup
+}
+
+func newRaceStore(retention time.Duration) *raceStore {
+	return &raceStore{
+		races:     map[string]*race{},
+		retention: retention,
+	}
+}
+
+// start runs the race from origin to destination in the background, with the given timeout, and returns its JSON representation.
+// query holds the options of the race. release is called when the race completes.
+func (s *raceStore) start(racer *wikiracer.WikiRacer, query url.Values, origin, destination string, timeout time.Duration, release func()) (*raceResponse, error) {
+	id, err := raceID()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(raceContext, timeout)
+	r := &race{
+		id:          id,
+		origin:      origin,
+		destination: destination,
+		created:     time.Now(),
+		cancel:      cancel,
+		query:       query,
+		status:      raceRunning,
+	}
+
+	s.mux.Lock()
+	s.expire()
+	s.races[id] = r
+	res := r.response()
+	s.mux.Unlock()
+
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		defer release()
+		defer cancel()
+
+		result := racer.TimedFindPath(ctx, origin, destination)
+		result.Err = interrupted(result.Err)
+		s.finish(r, result)
+	}()
+
+	return res, nil
+}
+
+// succeeded records a race from origin to destination which is served from the result cache, and returns its JSON representation.
+func (s *raceStore) succeeded(origin, destination string, result *wikiracer.Result) (*raceResponse, error) {
+	id, err := raceID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	r := &race{
+		id:          id,
+		origin:      origin,
+		destination: destination,
+		created:     now,
+		cancel:      func() {},
+		status:      raceSucceeded,
+		finished:    now,
+		result:      result,
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.expire()
+	s.races[id] = r
+	return r.response(), nil
+}
+
+// finish records the result of the race. The result of a canceled race is discarded, and the result of a successful race is added to the result cache.
+func (s *raceStore) finish(r *race, result *wikiracer.Result) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if r.status != raceRunning {
+		return
+	}
+
+	r.finished = time.Now()
+	r.result = result
+	r.status = raceSucceeded
+	if result.Err != nil {
+		r.status = raceFailed
+		log.Instance().Errorf("Race %s: %q -> %q: Failed. Reason: %q", r.id, r.origin, r.destination, result.Err)
+		return
+	}
+
+	results.add(r.query, r.origin, r.destination, result)
+	logResult(r.origin, r.destination, result)
+}
+
+// get returns the JSON representation of the race.
+func (s *raceStore) get(id string) (*raceResponse, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.expire()
+	r, ok := s.races[id]
+	if !ok {
+		return nil, raceNotFound{ID: id}
+	}
+
+	return r.response(), nil
+}
+
+// list returns the JSON representations of the races with the given status, in the order they were created.
+// If status is empty, all the races are returned.
+func (s *raceStore) list(status string) []*raceResponse {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.expire()
+	responses := []*raceResponse{}
+	for _, r := range s.races {
+		if status == "" || r.status == status {
+			responses = append(responses, r.response())
+		}
+	}
+
+	sort.Slice(responses, func(i, j int) bool {
+		return responses[i].Created.Before(responses[j].Created)
+	})
+	return responses
+}
+
+// delete cancels a running race. A completed race is removed.
+// It returns the JSON representation of the canceled race, or nil if the race was removed.
+func (s *raceStore) delete(id string) (*raceResponse, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.expire()
+	r, ok := s.races[id]
+	if !ok {
+		return nil, raceNotFound{ID: id}
+	}
+
+	if r.status != raceRunning {
+		delete(s.races, id)
+		return nil, nil
+	}
+
+	r.cancel()
+	r.status = raceCanceled
+	r.finished = time.Now()
+	log.Instance().Infof("Race %s: %q -> %q: Canceled", r.id, r.origin, r.destination)
+
+	return r.response(), nil
+}
+
+// wait blocks until all the running races complete, or until ctx is done.
+func (s *raceStore) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// expire removes the completed races whose retention period has passed. The caller must hold the mutex.
+func (s *raceStore) expire() {
+	for id, r := range s.races {
+		if r.status != raceRunning && time.Since(r.finished) > s.retention {
+			delete(s.races, id)
+		}
+	}
+}
+
+// response returns the JSON representation of the race. The caller must hold the mutex of the store.
+func (r *race) response() *raceResponse {
+	res := &raceResponse{
+		ID:          r.id,
+		Status:      r.status,
+		Origin:      r.origin,
+		Destination: r.destination,
+		Created:     r.created,
+	}
+
+	if r.status == raceRunning {
+		return res
+	}
+
+	finished := r.finished
+	res.Finished = &finished
+
+	switch {
+	case r.result == nil:
+	case r.result.Err != nil:
+		_, code := classify(r.result.Err)
+		res.Error = newErrorBody(code, r.result.Err)
+	default:
+		res.Result = newPathResponse(r.origin, r.destination, r.result)
+	}
+
+	return res
+}
+
+func raceID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// handleRaces starts a race with a POST request, and lists the races with a GET request.
+// The parameters of the race are the same as the /wikiracer endpoint's. They can be sent in the query, or in a form-encoded body.
+// A race whose result is cached is created as a succeeded race, without running it.
+func handleRaces(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		if err := req.ParseForm(); err != nil {
+			writeError(w, formatJSON, invalidParameter{err})
+			return
+		}
+
+		if result, ok := results.get(req.Form, req.Form.Get(queryParameterOrigin), req.Form.Get(queryParameterDestination)); ok {
+			origin, destination := req.Form.Get(queryParameterOrigin), req.Form.Get(queryParameterDestination)
+			res, err := races.succeeded(origin, destination, result)
+			if err != nil {
+				writeError(w, formatJSON, err)
+				return
+			}
+
+			log.Instance().Infof("Race %s: %q -> %q: Served from the result cache", res.ID, origin, destination)
+			w.Header().Set("Location", racesPath+"/"+res.ID)
+			w.Header().Set(headerCache, cacheHit)
+			writeJSON(w, http.StatusCreated, res)
+			return
+		}
+
+		racer, origin, destination, err := setupRace(req.Form)
+		if err != nil {
+			log.Instance().Errorf("%q -> %q: Failed. Reason: %q", origin, destination, err)
+			writeError(w, formatJSON, err)
+			return
+		}
+
+		timeout, err := raceTimeout(req.Form)
+		if err != nil {
+			log.Instance().Errorf("%q -> %q: Failed. Reason: %q", origin, destination, err)
+			writeError(w, formatJSON, err)
+			return
+		}
+
+		release, origin, destination, err := admitRace(req, origin, destination)
+		if err != nil {
+			log.Instance().Errorf("%q -> %q: Rejected. Reason: %q", origin, destination, err)
+			writeError(w, formatJSON, err)
+			return
+		}
+
+		res, err := races.start(racer, req.Form, origin, destination, timeout, release)
+		if err != nil {
+			release()
+			writeError(w, formatJSON, err)
+			return
+		}
+
+		log.Instance().Infof("Race %s: %q -> %q: Started", res.ID, origin, destination)
+		w.Header().Set("Location", racesPath+"/"+res.ID)
+		writeJSON(w, http.StatusAccepted, res)
+
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, races.list(req.URL.Query().Get(queryParameterStatus)))
+
+	default:
+		w.Header().Set("Allow", strings.Join([]string{http.MethodGet, http.MethodPost}, ", "))
+		response(w, http.StatusMethodNotAllowed, []byte(http.StatusText(http.StatusMethodNotAllowed)))
+	}
+}
+
+// handleRace returns the status and result of a race with a GET request, and cancels it with a DELETE request.
+func handleRace(w http.ResponseWriter, req *http.Request) {
+	id := strings.TrimPrefix(req.URL.Path, racesPath+"/")
+
+	switch req.Method {
+	case http.MethodGet:
+		res, err := races.get(id)
+		if err != nil {
+			writeError(w, formatJSON, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, res)
+
+	case http.MethodDelete:
+		res, err := races.delete(id)
+		if err != nil {
+			writeError(w, formatJSON, err)
+			return
+		}
+
+		if res == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeJSON(w, http.StatusOK, res)
+
+	default:
+		w.Header().Set("Allow", strings.Join([]string{http.MethodGet, http.MethodDelete}, ", "))
+		response(w, http.StatusMethodNotAllowed, []byte(http.StatusText(http.StatusMethodNotAllowed)))
+	}
+}
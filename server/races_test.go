@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ihcsim/wikiracer/test"
+	"github.com/ihcsim/wikiracer/test/apiserver"
+)
+
+func TestRaces(t *testing.T) {
+	stub := httptest.NewServer(apiserver.New(test.NewMockWiki()))
+	defer stub.Close()
+	defer stubBackend(t, stub.URL)()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(racesPath, handleRaces)
+	mux.HandleFunc(racesPath+"/", handleRace)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	t.Run("Completed", func(t *testing.T) {
+		started := postRace(t, server.URL, "Mike Tyson", "Apepi", http.StatusAccepted)
+		if started.Status != raceRunning {
+			t.Errorf("Mismatch status. Expected %q. Actual %q", raceRunning, started.Status)
+		}
+
+		actual := pollRace(t, server.URL, started.ID)
+		if actual.Status != raceSucceeded || actual.Result == nil || actual.Result.Hops != 2 {
+			t.Errorf("Mismatch race. Got %+v", actual)
+		}
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		started := postRace(t, server.URL, "Mike Tyson", "Red link", http.StatusAccepted)
+
+		actual := pollRace(t, server.URL, started.ID)
+		if actual.Status != raceFailed || actual.Error == nil || actual.Error.Code != codePageNotFound {
+			t.Errorf("Mismatch race. Got %+v", actual)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		postRace(t, server.URL, "Mike Tyson", "", http.StatusBadRequest)
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		// the destination is unreachable, so the race runs until it times out.
+		started := postRace(t, server.URL, "Mike Tyson", "Michael Jordan", http.StatusAccepted)
+
+		var running []*raceResponse
+		getJSON(t, server.URL+racesPath+"?status=running", http.StatusOK, &running)
+		if len(running) != 1 || running[0].ID != started.ID {
+			t.Errorf("Mismatch running races. Got %+v", running)
+		}
+
+		var canceled raceResponse
+		request(t, http.MethodDelete, server.URL+racesPath+"/"+started.ID, http.StatusOK, &canceled)
+		if canceled.Status != raceCanceled {
+			t.Errorf("Mismatch status. Expected %q. Actual %q", raceCanceled, canceled.Status)
+		}
+
+		// a completed race is removed.
+		request(t, http.MethodDelete, server.URL+racesPath+"/"+started.ID, http.StatusNoContent, nil)
+		request(t, http.MethodGet, server.URL+racesPath+"/"+started.ID, http.StatusNotFound, nil)
+	})
+
+	t.Run("Cached", func(t *testing.T) {
+		defer func(c *resultCache) {
+			results = c
+		}(results)
+		results = newResultCache(10, time.Minute)
+
+		started := postRace(t, server.URL, "Mike Tyson", "Apepi", http.StatusAccepted)
+		pollRace(t, server.URL, started.ID)
+
+		// the repeated race is created as a succeeded race, with the cached result.
+		cached := postRace(t, server.URL, "mike_Tyson", "Apepi", http.StatusCreated)
+		if cached.ID == started.ID || cached.Status != raceSucceeded || cached.Finished == nil || cached.Result == nil || cached.Result.Hops != 2 {
+			t.Errorf("Mismatch race. Got %+v", cached)
+		}
+
+		var actual raceResponse
+		getJSON(t, server.URL+racesPath+"/"+cached.ID, http.StatusOK, &actual)
+		if actual.Status != raceSucceeded || actual.Result == nil {
+			t.Errorf("Mismatch race. Got %+v", actual)
+		}
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		defer func(store *raceStore) {
+			races = store
+		}(races)
+		races = newRaceStore(100 * time.Millisecond)
+
+		started := postRace(t, server.URL, "Mike Tyson", "Apepi", http.StatusAccepted)
+		pollRace(t, server.URL, started.ID)
+		time.Sleep(200 * time.Millisecond)
+
+		request(t, http.MethodGet, server.URL+racesPath+"/"+started.ID, http.StatusNotFound, nil)
+	})
+}
+
+func postRace(t *testing.T, server, origin, destination string, status int) *raceResponse {
+	form := url.Values{queryParameterOrigin: []string{origin}, queryParameterDestination: []string{destination}}
+	res, err := http.Post(server+racesPath, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != status {
+		t.Fatalf("Mismatch status code. Expected %d. Actual %d", status, res.StatusCode)
+	}
+
+	var race raceResponse
+	if err := json.NewDecoder(res.Body).Decode(&race); err != nil {
+		t.Fatal(err)
+	}
+
+	if (status == http.StatusAccepted || status == http.StatusCreated) && res.Header.Get("Location") != racesPath+"/"+race.ID {
+		t.Errorf("Mismatch location. Got %q", res.Header.Get("Location"))
+	}
+	return &race
+}
+
+// pollRace waits for the race to complete.
+func pollRace(t *testing.T, server, id string) *raceResponse {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		var race raceResponse
+		getJSON(t, server+racesPath+"/"+id, http.StatusOK, &race)
+		if race.Status != raceRunning {
+			return &race
+		}
+	}
+
+	t.Fatalf("Race %s didn't complete", id)
+	return nil
+}
+
+func getJSON(t *testing.T, url string, status int, body interface{}) {
+	request(t, http.MethodGet, url, status, body)
+}
+
+func request(t *testing.T, method, url string, status int, body interface{}) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != status {
+		t.Fatalf("Mismatch status code of %s %s. Expected %d. Actual %d", method, url, status, res.StatusCode)
+	}
+
+	if body != nil {
+		if err := json.NewDecoder(res.Body).Decode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
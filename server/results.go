@@ -0,0 +1,163 @@
+package main
+
+import (
+	"container/list"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ihcsim/wikiracer"
+	"github.com/ihcsim/wikiracer/internal/metrics"
+)
+
+// headerCache is set to cacheHit in the responses served by the result cache.
+const (
+	headerCache = "X-Cache"
+	cacheHit    = "HIT"
+)
+
+var (
+	resultCacheHits   = metrics.NewCounter("wikiracer_result_cache_hits_total", "Number of races served by the result cache.")
+	resultCacheMisses = metrics.NewCounter("wikiracer_result_cache_misses_total", "Number of races which weren't found in the result cache.")
+)
+
+// results are the results of the recent successful races. The cache is disabled until main configures it.
+var results = newResultCache(0, 0)
+
+// resultCache is a LRU cache of the results of the successful races, which expire after the TTL of the cache.
+// The results are keyed by the normalized origin and destination of the races, and by the options which change the links that are followed.
+// The timeout and the budget of a race aren't part of the key, because they don't change the validity of a path.
+type resultCache struct {
+	size int
+	ttl  time.Duration
+
+	mux     sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+type resultEntry struct {
+	key     string
+	result  *wikiracer.Result
+	expires time.Time
+}
+
+// newResultCache returns a cache which holds up to size results for ttl. It's disabled if ttl isn't positive.
+func newResultCache(size int, ttl time.Duration) *resultCache {
+	return &resultCache{
+		size:    size,
+		ttl:     ttl,
+		entries: map[string]*list.Element{},
+		lru:     list.New(),
+	}
+}
+
+// get returns the cached result of the race from origin to destination, with the options of query.
+func (c *resultCache) get(query url.Values, origin, destination string) (*wikiracer.Result, bool) {
+	key, ok := c.key(query, origin, destination)
+	if !ok {
+		return nil, false
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	element, ok := c.entries[key]
+	if ok && time.Now().After(element.Value.(*resultEntry).expires) {
+		c.lru.Remove(element)
+		delete(c.entries, key)
+		ok = false
+	}
+
+	if !ok {
+		resultCacheMisses.Inc()
+		return nil, false
+	}
+
+	resultCacheHits.Inc()
+	c.lru.MoveToFront(element)
+	return element.Value.(*resultEntry).result, true
+}
+
+// add caches the result of the race from origin to destination, with the options of query. Failed races aren't cached.
+func (c *resultCache) add(query url.Values, origin, destination string, result *wikiracer.Result) {
+	key, ok := c.key(query, origin, destination)
+	if !ok || result.Err != nil {
+		return
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	entry := &resultEntry{key: key, result: result, expires: time.Now().Add(c.ttl)}
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.lru.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(entry)
+	if c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*resultEntry).key)
+	}
+}
+
+// key returns the key of the race from origin to destination, with the options of query.
+// It returns false if the cache is disabled, or if the origin or the destination is random.
+func (c *resultCache) key(query url.Values, origin, destination string) (string, bool) {
+	if c.ttl <= 0 || origin == randomTitle || destination == randomTitle {
+		return "", false
+	}
+
+	options := url.Values{}
+	if links := query.Get(queryParameterLinks); links != "" && links != linksAll {
+		options.Set(queryParameterLinks, links)
+	}
+
+	if asOf := query.Get(queryParameterAsOf); asOf != "" {
+		options.Set(queryParameterAsOf, asOf)
+	}
+
+	var skips []string
+	for _, value := range query[queryParameterSkip] {
+		for _, skip := range strings.Split(value, ",") {
+			if skip = strings.TrimSpace(skip); skip != "" {
+				skips = append(skips, skip)
+			}
+		}
+	}
+
+	for parameter, values := range map[string][]string{
+		queryParameterSkip:         skips,
+		queryParameterAllow:        query[queryParameterAllow],
+		queryParameterDeny:         query[queryParameterDeny],
+		queryParameterDenyCategory: query[queryParameterDenyCategory],
+	} {
+		values = append([]string{}, values...)
+		sort.Strings(values)
+		for i, value := range values {
+			if i == 0 || value != values[i-1] {
+				options.Add(parameter, value)
+			}
+		}
+	}
+
+	return strings.Join([]string{normalizeTitle(origin), normalizeTitle(destination), options.Encode()}, "\n"), true
+}
+
+// normalizeTitle returns title the way Wikipedia normalizes it. Underscores are spaces, the spaces are collapsed, and the first letter is capitalized.
+func normalizeTitle(title string) string {
+	title = strings.Join(strings.Fields(strings.Replace(title, "_", " ", -1)), " ")
+
+	first, size := utf8.DecodeRuneInString(title)
+	if first == utf8.RuneError {
+		return title
+	}
+	return string(unicode.ToUpper(first)) + title[size:]
+}
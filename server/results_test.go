@@ -0,0 +1,155 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ihcsim/wikiracer"
+	"github.com/ihcsim/wikiracer/errors"
+	"github.com/ihcsim/wikiracer/test"
+	"github.com/ihcsim/wikiracer/test/apiserver"
+)
+
+func TestResultCacheKey(t *testing.T) {
+	cache := newResultCache(10, time.Minute)
+
+	var testCases = []struct {
+		name  string
+		a, b  url.Values
+		equal bool
+	}{
+		{
+			name:  "Normalized Titles",
+			a:     url.Values{queryParameterOrigin: []string{"mike_Tyson"}, queryParameterDestination: []string{"Greek  language"}},
+			b:     url.Values{queryParameterOrigin: []string{"Mike Tyson"}, queryParameterDestination: []string{"Greek language"}},
+			equal: true,
+		},
+		{
+			name:  "Reordered Filters",
+			a:     url.Values{queryParameterOrigin: []string{"A"}, queryParameterDestination: []string{"B"}, queryParameterSkip: []string{"lists,dates"}, queryParameterDeny: []string{"^X", "^Y"}},
+			b:     url.Values{queryParameterOrigin: []string{"A"}, queryParameterDestination: []string{"B"}, queryParameterSkip: []string{"dates", "lists"}, queryParameterDeny: []string{"^Y", "^X"}},
+			equal: true,
+		},
+		{
+			name:  "Ignored Options",
+			a:     url.Values{queryParameterOrigin: []string{"A"}, queryParameterDestination: []string{"B"}, queryParameterLinks: []string{linksAll}, queryParameterTimeout: []string{"5s"}, queryParameterMaxPages: []string{"10"}},
+			b:     url.Values{queryParameterOrigin: []string{"A"}, queryParameterDestination: []string{"B"}},
+			equal: true,
+		},
+		{
+			name: "Different Links",
+			a:    url.Values{queryParameterOrigin: []string{"A"}, queryParameterDestination: []string{"B"}, queryParameterLinks: []string{linksProse}},
+			b:    url.Values{queryParameterOrigin: []string{"A"}, queryParameterDestination: []string{"B"}},
+		},
+		{
+			name: "Reversed",
+			a:    url.Values{queryParameterOrigin: []string{"A"}, queryParameterDestination: []string{"B"}},
+			b:    url.Values{queryParameterOrigin: []string{"B"}, queryParameterDestination: []string{"A"}},
+		},
+	}
+
+	for _, testCase := range testCases {
+		a, _ := cache.key(testCase.a, testCase.a.Get(queryParameterOrigin), testCase.a.Get(queryParameterDestination))
+		b, _ := cache.key(testCase.b, testCase.b.Get(queryParameterOrigin), testCase.b.Get(queryParameterDestination))
+		if (a == b) != testCase.equal {
+			t.Errorf("Test case %q failed. Expected equal keys: %t.\nKey A: %q\nKey B: %q", testCase.name, testCase.equal, a, b)
+		}
+	}
+
+	if _, ok := cache.key(url.Values{}, randomTitle, "B"); ok {
+		t.Error("Expected the races from a random page not to be cached")
+	}
+}
+
+func TestResultCache(t *testing.T) {
+	var (
+		cache  = newResultCache(2, 50*time.Millisecond)
+		query  = url.Values{}
+		result = &wikiracer.Result{Path: []byte("A -> B")}
+	)
+
+	cache.add(query, "A", "B", result)
+	cache.add(query, "A", "C", &wikiracer.Result{Err: errors.DestinationUnreachable{Destination: "C"}})
+	if actual, ok := cache.get(query, "A", "B"); !ok || actual != result {
+		t.Errorf("Expected the result to be cached")
+	}
+
+	if _, ok := cache.get(query, "A", "C"); ok {
+		t.Errorf("Expected the failed race not to be cached")
+	}
+
+	// B is the most recently used result when D is added.
+	cache.add(query, "A", "C", result)
+	cache.get(query, "A", "B")
+	cache.add(query, "A", "D", result)
+	if _, ok := cache.get(query, "A", "C"); ok {
+		t.Errorf("Expected the least recently used result to be evicted")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if _, ok := cache.get(query, "A", "B"); ok {
+		t.Errorf("Expected the result to expire")
+	}
+
+	if _, ok := newResultCache(10, 0).key(query, "A", "B"); ok {
+		t.Errorf("Expected the cache to be disabled")
+	}
+}
+
+func TestCachedRace(t *testing.T) {
+	var (
+		calls int64
+		api   = apiserver.New(test.NewMockWiki())
+	)
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt64(&calls, 1)
+		api.ServeHTTP(w, req)
+	}))
+	defer stub.Close()
+	defer stubBackend(t, stub.URL)()
+
+	defer func(c *resultCache) {
+		results = c
+	}(results)
+	results = newResultCache(10, time.Minute)
+
+	server := httptest.NewServer(http.HandlerFunc(timedFindPath))
+	defer server.Close()
+
+	var testCases = []struct {
+		name   string
+		origin string
+		cached bool
+	}{
+		{name: "Miss", origin: "Mike Tyson"},
+		{name: "Hit", origin: "Mike Tyson", cached: true},
+		{name: "Normalized Hit", origin: "mike_Tyson", cached: true},
+	}
+
+	for _, testCase := range testCases {
+		before := atomic.LoadInt64(&calls)
+
+		query := url.Values{queryParameterOrigin: []string{testCase.origin}, queryParameterDestination: []string{"Apepi"}}
+		res, err := http.Get(server.URL + "/wikiracer?" + query.Encode())
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("Test case %q failed. Mismatch status code. Expected %d. Actual %d", testCase.name, http.StatusOK, res.StatusCode)
+		}
+
+		if cached := res.Header.Get(headerCache) == cacheHit; cached != testCase.cached {
+			t.Errorf("Test case %q failed. Mismatch cache hit. Expected %t. Actual %t", testCase.name, testCase.cached, cached)
+		}
+
+		if sent := atomic.LoadInt64(&calls) - before; testCase.cached && sent != 0 {
+			t.Errorf("Test case %q failed. Expected no request to the wiki. Actual %d", testCase.name, sent)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/ihcsim/wikiracer/errors"
+	"github.com/ihcsim/wikiracer/log"
+)
+
+// shutdownGrace is the time given to the handlers of the races canceled at the end of the drain period, to respond to their clients.
+const shutdownGrace = 5 * time.Second
+
+// raceContext is the parent context of all the races. It's canceled when the drain period of a shutdown passes.
+var raceContext, cancelRaces = context.WithCancel(context.Background())
+
+// shuttingDown is the error used when a race is canceled because the server is shutting down.
+type shuttingDown struct{}
+
+// Error returns the string representation of the shuttingDown error.
+func (e shuttingDown) Error() string {
+	return "The server is shutting down"
+}
+
+// shutdown stops the server from accepting new connections, and waits up to the drain period for the running races to complete.
+// The races which are still running when the drain period passes are canceled, and their clients are sent a shuttingDown error.
+func shutdown(server *http.Server, drain time.Duration) error {
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), drain)
+	defer cancelDrain()
+
+	go func() {
+		<-drainCtx.Done()
+		if drainCtx.Err() == context.DeadlineExceeded {
+			log.Instance().Warningf("Drain period of %s passed. Canceling the running races...", drain)
+			cancelRaces()
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), drain+shutdownGrace)
+	defer cancel()
+
+	err := server.Shutdown(ctx)
+	if e := races.wait(ctx); err == nil {
+		err = e
+	}
+
+	if e := wikiBackend.save(); err == nil {
+		err = e
+	}
+
+	return err
+}
+
+// withRaceContext returns a copy of ctx which is also canceled when raceContext is canceled.
+func withRaceContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-raceContext.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, cancel
+}
+
+// interrupted replaces the error of a race which was canceled by a shutdown with the shuttingDown error.
+func interrupted(err error) error {
+	if _, ok := err.(errors.DestinationUnreachable); ok && raceContext.Err() != nil {
+		return shuttingDown{}
+	}
+	return err
+}
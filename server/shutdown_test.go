@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ihcsim/wikiracer/test"
+	"github.com/ihcsim/wikiracer/test/apiserver"
+)
+
+func TestShutdown(t *testing.T) {
+	stub := httptest.NewServer(apiserver.New(test.NewMockWiki(), apiserver.WithLatency(100*time.Millisecond)))
+	defer stub.Close()
+	defer stubBackend(t, stub.URL)()
+
+	var testCases = []struct {
+		name        string
+		destination string
+		drain       time.Duration
+		status      int
+		code        string
+	}{
+		{name: "Drained", destination: "Apepi", drain: 5 * time.Second, status: http.StatusOK},
+		{name: "Canceled", destination: "Michael Jordan", drain: 200 * time.Millisecond, status: http.StatusServiceUnavailable, code: codeShuttingDown},
+	}
+
+	for _, testCase := range testCases {
+		func() {
+			defer func() {
+				raceContext, cancelRaces = context.WithCancel(context.Background())
+			}()
+
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			server := &http.Server{Handler: http.HandlerFunc(timedFindPath)}
+			go server.Serve(listener)
+
+			responses := make(chan *http.Response, 1)
+			go func() {
+				query := url.Values{queryParameterOrigin: []string{"Mike Tyson"}, queryParameterDestination: []string{testCase.destination}, queryParameterFormat: []string{formatJSON}}
+				res, err := http.Get("http://" + listener.Addr().String() + "/wikiracer?" + query.Encode())
+				if err != nil {
+					t.Error(err)
+				}
+				responses <- res
+			}()
+
+			// wait for the race to start.
+			time.Sleep(50 * time.Millisecond)
+			if err := shutdown(server, testCase.drain); err != nil {
+				t.Errorf("Test case %q failed. Unexpected error: %s", testCase.name, err)
+			}
+
+			res := <-responses
+			if res == nil {
+				return
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != testCase.status {
+				t.Errorf("Test case %q failed. Mismatch status code. Expected %d. Actual %d", testCase.name, testCase.status, res.StatusCode)
+			}
+
+			if testCase.code != "" {
+				var actual errorResponse
+				if err := json.NewDecoder(res.Body).Decode(&actual); err != nil {
+					t.Fatal(err)
+				}
+
+				if actual.Error.Code != testCase.code {
+					t.Errorf("Test case %q failed. Mismatch error code. Expected %q. Actual %q", testCase.name, testCase.code, actual.Error.Code)
+				}
+			}
+		}()
+	}
+}
+
+func TestCacheFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wikiracer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := defaultConfig()
+	cfg.Backend, cfg.CacheFile = backendCached, filepath.Join(dir, "cache.json")
+
+	b, err := newBackend(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := b.caches[false].Wrap(test.NewMockWiki()).FindPages("Mike Tyson|Apepi", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := newBackend(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if actual := restored.caches[false].Len(); actual != 2 {
+		t.Errorf("Mismatch number of cached pages. Expected 2. Actual %d", actual)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ihcsim/wikiracer/errors"
+	"github.com/ihcsim/wikiracer/internal/wiki/wikipedia"
+)
+
+const (
+	// the machine-readable codes of the errors.
+	codeInvalidParameter       = "invalid_parameter"
+	codeInvalidInput           = "invalid_input"
+	codePageNotFound           = "page_not_found"
+	codePuzzleUnavailable      = "puzzle_unavailable"
+	codeRaceNotFound           = "race_not_found"
+	codeDestinationUnreachable = "destination_unreachable"
+	codeBudgetExhausted        = "budget_exhausted"
+	codeRateLimited            = "rate_limited"
+	codeTooManyRequests        = "too_many_requests"
+	codeShuttingDown           = "shutting_down"
+	codeUpstreamTimeout        = "upstream_timeout"
+	codeUpstreamError          = "upstream_error"
+	codeInternalError          = "internal_error"
+
+	// the error code used by the MediaWiki API when the replication lag of its databases is too high.
+	maxLagCode = "maxlag"
+
+	// retryAfter is the value of the Retry-After header, when the wiki is throttling the server.
+	retryAfter = 30 * time.Second
+)
+
+// classify returns the HTTP status code and the machine-readable code that match the type of err.
+// Invalid inputs are client errors, and aren't reported with the 5xx status codes.
+func classify(err error) (int, string) {
+	switch cast := err.(type) {
+	case invalidParameter:
+		return http.StatusBadRequest, codeInvalidParameter
+
+	case errors.InvalidEmptyInput, errors.InvalidHops:
+		return http.StatusBadRequest, codeInvalidInput
+
+	case errors.PageNotFound:
+		return http.StatusNotFound, codePageNotFound
+
+	case errors.PuzzleUnavailable:
+		return http.StatusNotFound, codePuzzleUnavailable
+
+	case raceNotFound:
+		return http.StatusNotFound, codeRaceNotFound
+
+	// the racer gives up on the destination when the timeout expires.
+	case errors.DestinationUnreachable:
+		return http.StatusGatewayTimeout, codeDestinationUnreachable
+
+	case tooManyRequests:
+		return http.StatusTooManyRequests, codeTooManyRequests
+
+	// the request is valid, but the destination can't be found within the budget of the race.
+	case errors.BudgetExhausted:
+		return http.StatusUnprocessableEntity, codeBudgetExhausted
+
+	case shuttingDown:
+		return http.StatusServiceUnavailable, codeShuttingDown
+
+	case *wikipedia.RateLimited:
+		return http.StatusServiceUnavailable, codeRateLimited
+
+	case *wikipedia.ServerError:
+		if cast.Code == maxLagCode {
+			return http.StatusServiceUnavailable, codeRateLimited
+		}
+		return http.StatusInternalServerError, codeUpstreamError
+
+	case *wikipedia.BadRequest, *wikipedia.LoginFailed:
+		return http.StatusInternalServerError, codeUpstreamError
+
+	case errors.BatchSkipped:
+		return classify(cast.Err)
+
+	case errors.List:
+		// the list is only as retryable as its least retryable member.
+		status, code := http.StatusServiceUnavailable, codeRateLimited
+		for _, e := range cast {
+			if s, c := classify(e); s != http.StatusServiceUnavailable {
+				status, code = s, c
+			}
+		}
+		return status, code
+
+	case interface {
+		Timeout() bool
+	}:
+		if cast.Timeout() {
+			return http.StatusGatewayTimeout, codeUpstreamTimeout
+		}
+	}
+
+	return http.StatusInternalServerError, codeInternalError
+}
+
+// invalidParameter is the error used when a query parameter is invalid.
+type invalidParameter struct {
+	error
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/ihcsim/wikiracer/errors"
+	"github.com/ihcsim/wikiracer/internal/wiki"
+	"github.com/ihcsim/wikiracer/internal/wiki/wikipedia"
+)
+
+func TestClassify(t *testing.T) {
+	var testCases = []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{name: "Page Not Found", err: errors.PageNotFound{Page: wiki.Page{Title: "Missing Page"}}, status: http.StatusNotFound, code: codePageNotFound},
+		{name: "Empty Input", err: errors.InvalidEmptyInput{Origin: "Mike Tyson"}, status: http.StatusBadRequest, code: codeInvalidInput},
+		{name: "Invalid Hops", err: errors.InvalidHops{Hops: 0}, status: http.StatusBadRequest, code: codeInvalidInput},
+		{name: "Destination Unreachable", err: errors.DestinationUnreachable{Destination: "Michael Jordan"}, status: http.StatusGatewayTimeout, code: codeDestinationUnreachable},
+		{name: "Rate Limited", err: &wikipedia.RateLimited{Code: "ratelimited"}, status: http.StatusServiceUnavailable, code: codeRateLimited},
+		{name: "Lagged", err: &wikipedia.ServerError{Code: maxLagCode}, status: http.StatusServiceUnavailable, code: codeRateLimited},
+		{name: "Server Error", err: &wikipedia.ServerError{Code: "internal_api_error_DBQueryError"}, status: http.StatusInternalServerError, code: codeUpstreamError},
+		{name: "Skipped Rate Limited Batch", err: errors.BatchSkipped{Titles: "Mike Tyson", Err: &wikipedia.RateLimited{Code: "ratelimited"}}, status: http.StatusServiceUnavailable, code: codeRateLimited},
+		{name: "Skipped Missing Batch", err: errors.BatchSkipped{Titles: "Missing Page", Err: errors.PageNotFound{Page: wiki.Page{Title: "Missing Page"}}}, status: http.StatusNotFound, code: codePageNotFound},
+		{
+			name:   "Retryable List",
+			err:    errors.List{&wikipedia.RateLimited{Code: "ratelimited"}, errors.BatchSkipped{Titles: "Mike Tyson", Err: &wikipedia.ServerError{Code: maxLagCode}}},
+			status: http.StatusServiceUnavailable,
+			code:   codeRateLimited,
+		},
+		{
+			name:   "Mixed List",
+			err:    errors.List{&wikipedia.RateLimited{Code: "ratelimited"}, &wikipedia.BadRequest{Code: "badvalue"}},
+			status: http.StatusInternalServerError,
+			code:   codeUpstreamError,
+		},
+		{name: "Unknown", err: fmt.Errorf("Unexpected error"), status: http.StatusInternalServerError, code: codeInternalError},
+	}
+
+	for _, testCase := range testCases {
+		status, code := classify(testCase.err)
+		if status != testCase.status || code != testCase.code {
+			t.Errorf("Test case %q failed. Mismatch classification.\nExpected: %d %s\nActual: %d %s", testCase.name, testCase.status, testCase.code, status, code)
+		}
+	}
+}
+
+func TestWriteErrorRetryAfter(t *testing.T) {
+	var testCases = []struct {
+		name       string
+		err        error
+		retryAfter string
+	}{
+		{name: "Rate Limited", err: &wikipedia.RateLimited{Code: "ratelimited"}, retryAfter: strconv.Itoa(int(retryAfter.Seconds()))},
+		{name: "Page Not Found", err: errors.PageNotFound{Page: wiki.Page{Title: "Missing Page"}}},
+	}
+
+	for _, testCase := range testCases {
+		w := httptest.NewRecorder()
+		writeError(w, formatJSON, testCase.err)
+
+		if actual := w.Header().Get("Retry-After"); actual != testCase.retryAfter {
+			t.Errorf("Test case %q failed. Mismatch Retry-After header. Expected %q. Actual %q", testCase.name, testCase.retryAfter, actual)
+		}
+	}
+}
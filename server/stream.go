@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ihcsim/wikiracer"
+	"github.com/ihcsim/wikiracer/internal/wiki"
+	"github.com/ihcsim/wikiracer/log"
+)
+
+const (
+	streamPath = "/wikiracer/stream"
+
+	contentTypeEventStream = "text/event-stream"
+
+	// progressInterval is the interval between the progress events of a stream.
+	progressInterval = 500 * time.Millisecond
+
+	// the types of the server-sent events.
+	eventProgress = "progress"
+	eventPath     = "path"
+	eventResult   = "result"
+	eventError    = "error"
+)
+
+// progressResponse is the JSON representation of the progress of a race.
+type progressResponse struct {
+	PagesVisited int   `json:"pages_visited"`
+	Depth        int   `json:"depth"`
+	APICalls     int   `json:"api_calls"`
+	ElapsedMs    int64 `json:"elapsed_ms"`
+}
+
+// candidateResponse is the JSON representation of a path found during a race.
+type candidateResponse struct {
+	Path []pathEntry `json:"path"`
+	Hops int         `json:"hops"`
+}
+
+// progress collects the events of a crawl. The paths are buffered until they are sent to the client.
+type progress struct {
+	mux      sync.Mutex
+	start    time.Time
+	visited  int
+	depth    int
+	requests int
+	paths    [][]*wiki.Page
+
+	// found is signaled when a path is buffered.
+	found chan struct{}
+}
+
+func newProgress() *progress {
+	return &progress{
+		start: time.Now(),
+		found: make(chan struct{}, 1),
+	}
+}
+
+// observe is the wiki.Observer of the crawler.
+func (p *progress) observe(e wiki.Event) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	switch e.Type {
+	case wiki.EventVisit:
+		p.visited++
+		if e.Depth > p.depth {
+			p.depth = e.Depth
+		}
+
+	case wiki.EventRequest:
+		p.requests++
+
+	case wiki.EventPath:
+		p.paths = append(p.paths, e.Path)
+		select {
+		case p.found <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// snapshot returns the current progress, and the paths found since the last snapshot.
+func (p *progress) snapshot() (*progressResponse, [][]*wiki.Page) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	paths := p.paths
+	p.paths = nil
+
+	return &progressResponse{
+		PagesVisited: p.visited,
+		Depth:        p.depth,
+		APICalls:     p.requests,
+		ElapsedMs:    time.Since(p.start).Nanoseconds() / 1e6,
+	}, paths
+}
+
+// streamFindPath races from origin to destination, and streams the progress of the race as server-sent events.
+// A progress event is sent every progressInterval, a path event is sent for every path found, and the stream ends with a result or an error event.
+// Invalid requests are rejected with a JSON error, before the stream starts.
+func streamFindPath(w http.ResponseWriter, req *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, formatJSON, fmt.Errorf("Streaming isn't supported"))
+		return
+	}
+
+	query := req.URL.Query()
+	if result, ok := results.get(query, query.Get(queryParameterOrigin), query.Get(queryParameterDestination)); ok {
+		origin, destination := query.Get(queryParameterOrigin), query.Get(queryParameterDestination)
+		log.Instance().Infof("%q -> %q: Served from the result cache", origin, destination)
+		w.Header().Set("Content-Type", contentTypeEventStream)
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set(headerCache, cacheHit)
+		w.WriteHeader(http.StatusOK)
+		writeEvent(w, eventResult, newPathResponse(origin, destination, result))
+		flusher.Flush()
+		return
+	}
+
+	racer, origin, destination, err := setupRace(query)
+	if err != nil {
+		log.Instance().Errorf("%q -> %q: Failed. Reason: %q", origin, destination, err)
+		writeError(w, formatJSON, err)
+		return
+	}
+
+	timeout, err := raceTimeout(query)
+	if err != nil {
+		log.Instance().Errorf("%q -> %q: Failed. Reason: %q", origin, destination, err)
+		writeError(w, formatJSON, err)
+		return
+	}
+
+	release, origin, destination, err := admitRace(req, origin, destination)
+	if err != nil {
+		log.Instance().Errorf("%q -> %q: Rejected. Reason: %q", origin, destination, err)
+		writeError(w, formatJSON, err)
+		return
+	}
+	defer release()
+
+	// the race is canceled when the client disconnects, or when the server shuts down.
+	ctx, cancel := withRaceContext(req.Context())
+	defer cancel()
+
+	ctx, cancelTimeout := context.WithTimeout(ctx, timeout)
+	defer cancelTimeout()
+
+	progress := newProgress()
+	racer.Observe(progress.observe)
+
+	finished := make(chan *wikiracer.Result, 1)
+	go func() {
+		result := racer.TimedFindPath(ctx, origin, destination)
+		result.Err = interrupted(result.Err)
+		finished <- result
+	}()
+
+	w.Header().Set("Content-Type", contentTypeEventStream)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			current, paths := progress.snapshot()
+			writeCandidates(w, paths)
+			writeEvent(w, eventProgress, current)
+
+		case <-progress.found:
+			_, paths := progress.snapshot()
+			writeCandidates(w, paths)
+
+		case result := <-finished:
+			current, paths := progress.snapshot()
+			writeCandidates(w, paths)
+			writeEvent(w, eventProgress, current)
+
+			if result.Err != nil {
+				log.Instance().Errorf("%q -> %q: Failed. Reason: %q", origin, destination, result.Err)
+				_, code := classify(result.Err)
+				writeEvent(w, eventError, errorResponse{Error: *newErrorBody(code, result.Err)})
+			} else {
+				results.add(query, origin, destination, result)
+				logResult(origin, destination, result)
+				writeEvent(w, eventResult, newPathResponse(origin, destination, result))
+			}
+			flusher.Flush()
+			return
+		}
+
+		flusher.Flush()
+	}
+}
+
+func writeCandidates(w http.ResponseWriter, paths [][]*wiki.Page) {
+	for _, path := range paths {
+		candidate := &candidateResponse{Path: []pathEntry{}, Hops: len(path) - 1}
+		for _, page := range path {
+			candidate.Path = append(candidate.Path, pathEntry{Title: page.Title, ID: page.ID})
+		}
+		writeEvent(w, eventPath, candidate)
+	}
+}
+
+// writeEvent writes a server-sent event of the given type, whose data is the JSON representation of body.
+func writeEvent(w http.ResponseWriter, event string, body interface{}) {
+	content, err := json.Marshal(body)
+	if err != nil {
+		log.Instance().Errorf("Failed to encode %s event. Reason: %q", event, err)
+		return
+	}
+
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, content)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ihcsim/wikiracer/test"
+	"github.com/ihcsim/wikiracer/test/apiserver"
+)
+
+func TestStreamFindPath(t *testing.T) {
+	stub := httptest.NewServer(apiserver.New(test.NewMockWiki()))
+	defer stub.Close()
+	defer stubBackend(t, stub.URL)()
+
+	server := httptest.NewServer(http.HandlerFunc(streamFindPath))
+	defer server.Close()
+
+	t.Run("Found", func(t *testing.T) {
+		events := streamEvents(t, server.URL, "Mike Tyson", "Apepi", http.StatusOK)
+		if len(events) < 3 {
+			t.Fatalf("Expected at least 3 events. Got %+v", events)
+		}
+
+		last := events[len(events)-1]
+		if last.name != eventResult {
+			t.Fatalf("Mismatch last event. Expected %q. Actual %q", eventResult, last.name)
+		}
+
+		var result pathResponse
+		if err := json.Unmarshal([]byte(last.data), &result); err != nil {
+			t.Fatal(err)
+		}
+		if result.Hops != 2 {
+			t.Errorf("Mismatch hops. Expected 2. Actual %d", result.Hops)
+		}
+
+		var (
+			candidates int
+			progress   progressResponse
+		)
+		for _, event := range events {
+			switch event.name {
+			case eventPath:
+				candidates++
+			case eventProgress:
+				if err := json.Unmarshal([]byte(event.data), &progress); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+
+		if candidates == 0 {
+			t.Error("Expected path events to be sent")
+		}
+
+		if progress.PagesVisited == 0 || progress.APICalls == 0 || progress.Depth != 2 {
+			t.Errorf("Mismatch progress. Got %+v", progress)
+		}
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		events := streamEvents(t, server.URL, "Mike Tyson", "Red link", http.StatusOK)
+		last := events[len(events)-1]
+		if last.name != eventError {
+			t.Fatalf("Mismatch last event. Expected %q. Actual %q", eventError, last.name)
+		}
+
+		var actual errorResponse
+		if err := json.Unmarshal([]byte(last.data), &actual); err != nil {
+			t.Fatal(err)
+		}
+		if actual.Error.Code != codePageNotFound {
+			t.Errorf("Mismatch error code. Expected %q. Actual %q", codePageNotFound, actual.Error.Code)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		streamEvents(t, server.URL, "Mike Tyson", "", http.StatusBadRequest)
+	})
+}
+
+type event struct {
+	name string
+	data string
+}
+
+// streamEvents reads the server-sent events of a race, until the stream ends.
+func streamEvents(t *testing.T, server, origin, destination string, status int) []*event {
+	query := url.Values{queryParameterOrigin: []string{origin}, queryParameterDestination: []string{destination}}
+	res, err := http.Get(server + "?" + query.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != status {
+		t.Fatalf("Mismatch status code. Expected %d. Actual %d", status, res.StatusCode)
+	}
+
+	if status != http.StatusOK {
+		return nil
+	}
+
+	if contentType := res.Header.Get("Content-Type"); contentType != contentTypeEventStream {
+		t.Fatalf("Mismatch content type. Expected %q. Actual %q", contentTypeEventStream, contentType)
+	}
+
+	var (
+		events  []*event
+		current = &event{}
+		scanner = bufio.NewScanner(res.Body)
+	)
+	for scanner.Scan() {
+		switch line := scanner.Text(); {
+		case strings.HasPrefix(line, "event: "):
+			current.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			current.data = strings.TrimPrefix(line, "data: ")
+		case line == "":
+			events = append(events, current)
+			current = &event{}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
@@ -0,0 +1,54 @@
+package apiserver
+
+// response is the JSON response of api.php, in formatversion=2.
+type response struct {
+	Batchcomplete bool              `json:"batchcomplete,omitempty"`
+	Continue      map[string]string `json:"continue,omitempty"`
+	Query         *query            `json:"query,omitempty"`
+	Errors        []*responseError  `json:"errors,omitempty"`
+}
+
+type query struct {
+	Redirects []*redirect   `json:"redirects,omitempty"`
+	Pages     []*page       `json:"pages,omitempty"`
+	Random    []*randomPage `json:"random,omitempty"`
+}
+
+type redirect struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type page struct {
+	Pageid     int               `json:"pageid,omitempty"`
+	Ns         int               `json:"ns"`
+	Title      string            `json:"title"`
+	Missing    bool              `json:"missing,omitempty"`
+	Links      []*link           `json:"links,omitempty"`
+	Categories []*category       `json:"categories,omitempty"`
+	Length     int               `json:"length,omitempty"`
+	Pageprops  map[string]string `json:"pageprops,omitempty"`
+}
+
+type link struct {
+	Ns    int    `json:"ns"`
+	Title string `json:"title"`
+}
+
+type category struct {
+	Ns    int    `json:"ns"`
+	Title string `json:"title"`
+}
+
+type randomPage struct {
+	ID    int    `json:"id"`
+	Ns    int    `json:"ns"`
+	Title string `json:"title"`
+}
+
+type responseError struct {
+	Code   string                 `json:"code"`
+	Text   string                 `json:"text"`
+	Data   map[string]interface{} `json:"data,omitempty"`
+	Module string                 `json:"module"`
+}
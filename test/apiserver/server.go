@@ -0,0 +1,395 @@
+// Package apiserver provides a stand-in for the MediaWiki api.php endpoint, which serves the pages of any wiki.Wiki.
+// It's used to test the Wikipedia client, and the wikiracer server, end to end without network access.
+package apiserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ihcsim/wikiracer/errors"
+	"github.com/ihcsim/wikiracer/internal/wiki"
+)
+
+const (
+	// DefaultLinkLimit is the number of links returned per response, if the server isn't configured with WithLinkLimit.
+	DefaultLinkLimit = 500
+
+	separator = "|"
+)
+
+// Server serves the pages of a wiki.Wiki in the JSON format of the MediaWiki API, with formatversion=2 and errorformat=plaintext.
+// It supports the 'links', 'categories', 'info' and 'pageprops' prop modules, the 'random' list module, the 'plcontinue' pagination, the 'redirects' and 'maxlag' parameters.
+type Server struct {
+	wiki      wiki.Wiki
+	linkLimit int
+	redirects map[string]string
+	latency   time.Duration
+	lag       time.Duration
+
+	rateLimit  int
+	rateWindow time.Duration
+
+	mux         sync.Mutex
+	requests    int
+	windowStart time.Time
+	windowCount int
+}
+
+// Option can be used to configure the Server.
+type Option func(*Server)
+
+// WithLinkLimit sets the maximum number of links returned per response. The remaining links are paginated with 'plcontinue'.
+func WithLinkLimit(limit int) Option {
+	return func(s *Server) {
+		s.linkLimit = limit
+	}
+}
+
+// WithRedirects sets the redirects of the wiki, keyed by the redirected title.
+// The redirects are resolved if the query sets the 'redirects' parameter.
+func WithRedirects(redirects map[string]string) Option {
+	return func(s *Server) {
+		for from, to := range redirects {
+			s.redirects[from] = to
+		}
+	}
+}
+
+// WithLatency delays every response by latency.
+func WithLatency(latency time.Duration) Option {
+	return func(s *Server) {
+		s.latency = latency
+	}
+}
+
+// WithRateLimit rejects the requests with the 429 status code, once more than requests requests are received within window.
+func WithRateLimit(requests int, window time.Duration) Option {
+	return func(s *Server) {
+		s.rateLimit = requests
+		s.rateWindow = window
+	}
+}
+
+// WithLag sets the replication lag of the wiki. Requests whose 'maxlag' parameter is lower than lag are rejected with a 'maxlag' error.
+func WithLag(lag time.Duration) Option {
+	return func(s *Server) {
+		s.lag = lag
+	}
+}
+
+// New returns a new instance of Server which serves the pages of w.
+func New(w wiki.Wiki, options ...Option) *Server {
+	s := &Server{
+		wiki:      w,
+		linkLimit: DefaultLinkLimit,
+		redirects: map[string]string{},
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
+}
+
+// Requests returns the number of requests received by the server.
+func (s *Server) Requests() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.requests
+}
+
+// ServeHTTP handles a request to api.php.
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if err := req.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if s.latency > 0 {
+		time.Sleep(s.latency)
+	}
+
+	if s.throttled() {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "Error: 429, Too Many Requests", http.StatusTooManyRequests)
+		return
+	}
+
+	if maxlag, err := strconv.ParseFloat(req.Form.Get("maxlag"), 64); err == nil && s.lag.Seconds() > maxlag {
+		lag := s.lag.Seconds()
+		w.Header().Set("Retry-After", "5")
+		w.Header().Set("X-Database-Lag", strconv.Itoa(int(lag)))
+		s.respond(w, &response{Errors: []*responseError{{
+			Code:   "maxlag",
+			Text:   fmt.Sprintf("Waiting for 127.0.0.1: %g seconds lagged.", lag),
+			Data:   map[string]interface{}{"host": "127.0.0.1", "lag": lag, "type": "db"},
+			Module: "main",
+		}}})
+		return
+	}
+
+	switch action := req.Form.Get("action"); action {
+	case "query":
+		s.query(w, req)
+	default:
+		s.respond(w, &response{Errors: []*responseError{{
+			Code:   "badvalue",
+			Text:   fmt.Sprintf("Unrecognized value for parameter \"action\": %s.", action),
+			Module: "main",
+		}}})
+	}
+}
+
+// throttled returns true if the request exceeds the rate limit.
+func (s *Server) throttled() bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.requests++
+	if s.rateLimit <= 0 {
+		return false
+	}
+
+	if now := time.Now(); now.Sub(s.windowStart) > s.rateWindow {
+		s.windowStart = now
+		s.windowCount = 0
+	}
+	s.windowCount++
+
+	return s.windowCount > s.rateLimit
+}
+
+func (s *Server) query(w http.ResponseWriter, req *http.Request) {
+	if req.Form.Get("list") == "random" {
+		s.random(w, req)
+		return
+	}
+
+	titles := req.Form.Get("titles")
+	if titles == "" {
+		s.respond(w, &response{Errors: []*responseError{{Code: "missingparam", Text: "The \"titles\" parameter must be set.", Module: "main"}}})
+		return
+	}
+
+	var (
+		result    = &query{}
+		requested = []string{}
+	)
+	for _, title := range strings.Split(titles, separator) {
+		if to, ok := s.redirects[title]; ok && req.Form.Get("redirects") != "" {
+			result.Redirects = append(result.Redirects, &redirect{From: title, To: to})
+			title = to
+		}
+		requested = append(requested, title)
+	}
+
+	pages, err := s.find(requested)
+	if err != nil {
+		s.respond(w, &response{Errors: []*responseError{{Code: "internal_api_error", Text: err.Error(), Module: "main"}}})
+		return
+	}
+
+	var (
+		props   = map[string]bool{}
+		entries = []linkEntry{}
+	)
+	for _, prop := range strings.Split(req.Form.Get("prop"), separator) {
+		props[prop] = true
+	}
+
+	for _, p := range pages {
+		if p.missing {
+			continue
+		}
+
+		links := append([]string{}, p.Links...)
+		sort.Strings(links)
+		for _, title := range links {
+			entries = append(entries, linkEntry{pageID: p.ID, title: title})
+		}
+	}
+
+	start := 0
+	if plcontinue := req.Form.Get("plcontinue"); plcontinue != "" {
+		token, err := parseContinue(plcontinue)
+		if err != nil {
+			s.respond(w, &response{Errors: []*responseError{{Code: "badcontinue", Text: "Invalid continue param. You should pass the original value returned by the previous query.", Module: "query"}}})
+			return
+		}
+
+		for start < len(entries) && entries[start].before(token) {
+			start++
+		}
+	}
+
+	limit := s.linkLimit
+	if pllimit, err := strconv.Atoi(req.Form.Get("pllimit")); err == nil && pllimit < limit {
+		limit = pllimit
+	}
+
+	end := start + limit
+	if end > len(entries) {
+		end = len(entries)
+	}
+
+	batch := map[int][]*link{}
+	for _, entry := range entries[start:end] {
+		batch[entry.pageID] = append(batch[entry.pageID], &link{Ns: 0, Title: entry.title})
+	}
+
+	for _, p := range pages {
+		if p.missing {
+			result.Pages = append(result.Pages, &page{Ns: 0, Title: p.Title, Missing: true})
+			continue
+		}
+
+		out := &page{Pageid: p.ID, Ns: p.Namespace, Title: p.Title}
+		if props["links"] {
+			out.Links = batch[p.ID]
+		}
+
+		// the metadata is returned in full with the first batch of links.
+		if start == 0 {
+			if props["categories"] {
+				for _, name := range p.Categories {
+					out.Categories = append(out.Categories, &category{Ns: 14, Title: "Category:" + name})
+				}
+			}
+
+			if props["info"] {
+				out.Length = p.Length
+			}
+
+			if props["pageprops"] && p.Disambiguation {
+				out.Pageprops = map[string]string{"disambiguation": ""}
+			}
+		}
+		result.Pages = append(result.Pages, out)
+	}
+
+	res := &response{Query: result}
+	if end < len(entries) {
+		res.Continue = map[string]string{
+			"plcontinue": entries[end].token(),
+			"continue":   "||",
+		}
+	} else {
+		res.Batchcomplete = true
+	}
+
+	s.respond(w, res)
+}
+
+// find returns the pages of the given titles, in the order of their IDs. The missing pages are returned last.
+func (s *Server) find(titles []string) ([]*foundPage, error) {
+	pages, err := s.wiki.FindPages(strings.Join(titles, separator), "")
+
+	var missing []string
+	for _, e := range errors.Flatten(err) {
+		switch cast := e.(type) {
+		case errors.PagesNotFound:
+			missing = cast.Titles
+		case errors.Warnings:
+		default:
+			return nil, e
+		}
+	}
+
+	found := []*foundPage{}
+	for _, p := range pages {
+		found = append(found, &foundPage{Page: p})
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].ID < found[j].ID
+	})
+
+	for _, title := range missing {
+		found = append(found, &foundPage{Page: &wiki.Page{Title: title}, missing: true})
+	}
+
+	return found, nil
+}
+
+func (s *Server) random(w http.ResponseWriter, req *http.Request) {
+	randomizer, ok := s.wiki.(wiki.Randomizer)
+	if !ok {
+		s.respond(w, &response{Errors: []*responseError{{Code: "badvalue", Text: "Unrecognized value for parameter \"list\": random.", Module: "main"}}})
+		return
+	}
+
+	count, err := strconv.Atoi(req.Form.Get("rnlimit"))
+	if err != nil {
+		count = 1
+	}
+
+	pages, err := randomizer.RandomPages(count)
+	if err != nil {
+		s.respond(w, &response{Errors: []*responseError{{Code: "internal_api_error", Text: err.Error(), Module: "main"}}})
+		return
+	}
+
+	result := &query{}
+	for _, p := range pages {
+		result.Random = append(result.Random, &randomPage{ID: p.ID, Ns: p.Namespace, Title: p.Title})
+	}
+
+	s.respond(w, &response{Batchcomplete: true, Query: result})
+}
+
+func (s *Server) respond(w http.ResponseWriter, res *response) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if len(res.Errors) > 0 {
+		w.Header().Set("MediaWiki-API-Error", res.Errors[0].Code)
+	}
+
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// foundPage is a page returned by the wiki, or a missing page.
+type foundPage struct {
+	*wiki.Page
+	missing bool
+}
+
+// linkEntry is a link of a page, in the order used by the pagination.
+type linkEntry struct {
+	pageID int
+	title  string
+}
+
+// token returns the 'plcontinue' value which resumes the query at this link.
+func (e linkEntry) token() string {
+	return fmt.Sprintf("%d|0|%s", e.pageID, strings.Replace(e.title, " ", "_", -1))
+}
+
+// before returns true if the link precedes the link identified by the 'plcontinue' token.
+func (e linkEntry) before(token linkEntry) bool {
+	if e.pageID != token.pageID {
+		return e.pageID < token.pageID
+	}
+	return e.title < token.title
+}
+
+func parseContinue(plcontinue string) (linkEntry, error) {
+	parts := strings.SplitN(plcontinue, separator, 3)
+	if len(parts) != 3 {
+		return linkEntry{}, fmt.Errorf("Invalid plcontinue %q", plcontinue)
+	}
+
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return linkEntry{}, err
+	}
+
+	return linkEntry{pageID: id, title: strings.Replace(parts[2], "_", " ", -1)}, nil
+}
@@ -0,0 +1,120 @@
+package test
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/ihcsim/wikiracer/internal/wiki"
+	"github.com/ihcsim/wikiracer/internal/wiki/graph"
+)
+
+// LoadGraph returns a MockWiki with the pages of the graph file at path. See graph.Load.
+// The redirects of the graph file are added to the redirects configured by the options.
+func LoadGraph(path string, options ...Option) (*MockWiki, error) {
+	g, err := graph.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return fromGraph(g, options), nil
+}
+
+// Chain returns a MockWiki of n pages, titled 'Page 1' to 'Page n', where every page links to the next page.
+func Chain(n int, options ...Option) *MockWiki {
+	pages := numbered(n)
+	for i := 0; i < n-1; i++ {
+		pages[i].Links = []string{pages[i+1].Title}
+	}
+	return FromPages(pages, options...)
+}
+
+// Grid returns a MockWiki of rows*columns pages, where every page links to its neighbours on the left, on the right, above and below.
+// The page on row r and column c is titled 'Page <r*columns+c+1>'.
+func Grid(rows, columns int, options ...Option) *MockWiki {
+	pages := numbered(rows * columns)
+	for r := 0; r < rows; r++ {
+		for c := 0; c < columns; c++ {
+			page := pages[r*columns+c]
+			if r > 0 {
+				page.Links = append(page.Links, pages[(r-1)*columns+c].Title)
+			}
+			if c > 0 {
+				page.Links = append(page.Links, pages[r*columns+c-1].Title)
+			}
+			if c < columns-1 {
+				page.Links = append(page.Links, pages[r*columns+c+1].Title)
+			}
+			if r < rows-1 {
+				page.Links = append(page.Links, pages[(r+1)*columns+c].Title)
+			}
+		}
+	}
+	return FromPages(pages, options...)
+}
+
+// Random returns an Erdős–Rényi random MockWiki of n pages, where every page links to every other page with probability p.
+// The same seed always generates the same wiki.
+func Random(n int, p float64, seed int64, options ...Option) *MockWiki {
+	var (
+		pages = numbered(n)
+		rnd   = rand.New(rand.NewSource(seed))
+	)
+	for _, from := range pages {
+		for _, to := range pages {
+			if from != to && rnd.Float64() < p {
+				from.Links = append(from.Links, to.Title)
+			}
+		}
+	}
+	return FromPages(pages, options...)
+}
+
+// ScaleFree returns a MockWiki of n pages generated by the Barabási–Albert preferential attachment model.
+// Every new page links to m existing pages, which are chosen with a probability proportional to their number of links, and they link back to it.
+// Like Wikipedia, a few hubs have many links, while most of the pages have few links. The same seed always generates the same wiki.
+func ScaleFree(n, m int, seed int64, options ...Option) *MockWiki {
+	var (
+		pages = numbered(n)
+		rnd   = rand.New(rand.NewSource(seed))
+
+		// targets has an entry for every link of every page, so that a uniform draw from it is proportional to the pages' degrees.
+		targets = []int{}
+	)
+
+	link := func(i, j int) {
+		pages[i].Links = append(pages[i].Links, pages[j].Title)
+		pages[j].Links = append(pages[j].Links, pages[i].Title)
+		targets = append(targets, i, j)
+	}
+
+	// the first m+1 pages are fully connected.
+	for i := 0; i <= m && i < n; i++ {
+		for j := 0; j < i; j++ {
+			link(i, j)
+		}
+	}
+
+	for i := m + 1; i < n; i++ {
+		chosen := map[int]struct{}{}
+		for len(chosen) < m {
+			chosen[targets[rnd.Intn(len(targets))]] = struct{}{}
+		}
+
+		// the links are added in a deterministic order, since the iteration order of maps is random.
+		for j := 0; j < i; j++ {
+			if _, ok := chosen[j]; ok {
+				link(i, j)
+			}
+		}
+	}
+
+	return FromPages(pages, options...)
+}
+
+// numbered returns n pages titled 'Page 1' to 'Page n', with matching IDs.
+func numbered(n int) []*wiki.Page {
+	pages := []*wiki.Page{}
+	for i := 1; i <= n; i++ {
+		pages = append(pages, &wiki.Page{ID: i, Title: fmt.Sprintf("Page %d", i)})
+	}
+	return pages
+}
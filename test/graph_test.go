@@ -0,0 +1,88 @@
+package test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestGenerators(t *testing.T) {
+	t.Run("Chain", func(t *testing.T) {
+		m := Chain(3)
+		for title, expected := range map[string][]string{"Page 1": []string{"Page 2"}, "Page 2": []string{"Page 3"}, "Page 3": nil} {
+			if actual := links(t, m, title); !reflect.DeepEqual(actual, expected) {
+				t.Errorf("Mismatch links of %q. Expected %v. Actual %v", title, expected, actual)
+			}
+		}
+	})
+
+	t.Run("Grid", func(t *testing.T) {
+		m := Grid(2, 3)
+		if actual := m.Len(); actual != 6 {
+			t.Errorf("Mismatch pages count. Expected 6. Actual %d", actual)
+		}
+
+		// Page 2 is in the middle of the first row.
+		expected := []string{"Page 1", "Page 3", "Page 5"}
+		if actual := links(t, m, "Page 2"); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Mismatch links. Expected %v. Actual %v", expected, actual)
+		}
+	})
+
+	t.Run("Random", func(t *testing.T) {
+		var edges int
+		m := Random(100, 0.05, 42)
+		for i := 1; i <= 100; i++ {
+			edges += len(links(t, m, title(i)))
+		}
+
+		// the expected number of links is 0.05 * 100 * 99 = 495.
+		if edges < 400 || edges > 600 {
+			t.Errorf("Mismatch links count. Expected about 495. Actual %d", edges)
+		}
+
+		if !reflect.DeepEqual(Random(100, 0.05, 42), m) {
+			t.Error("Expected the same seed to generate the same wiki")
+		}
+	})
+
+	t.Run("Scale-Free", func(t *testing.T) {
+		var (
+			m       = ScaleFree(1000, 2, 42)
+			edges   int
+			maxLink int
+		)
+		for i := 1; i <= 1000; i++ {
+			count := len(links(t, m, title(i)))
+			if count < 2 {
+				t.Errorf("Expected %q to have at least 2 links. Actual %d", title(i), count)
+			}
+
+			edges += count
+			if count > maxLink {
+				maxLink = count
+			}
+		}
+
+		// the hubs have many more links than the average page.
+		if average := edges / 1000; maxLink < 10*average {
+			t.Errorf("Expected hubs with at least %d links. Actual %d", 10*average, maxLink)
+		}
+
+		if !reflect.DeepEqual(ScaleFree(1000, 2, 42), m) {
+			t.Error("Expected the same seed to generate the same wiki")
+		}
+	})
+}
+
+func links(t *testing.T, m *MockWiki, title string) []string {
+	pages, err := m.FindPages(title, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pages[0].Links
+}
+
+func title(i int) string {
+	return fmt.Sprintf("Page %d", i)
+}
@@ -1,56 +1,243 @@
 package test
 
 import (
-	"strings"
+	"context"
+	"fmt"
+	"strconv"
+	"time"
 
-	"github.com/ihcsim/wikiracer/errors"
 	"github.com/ihcsim/wikiracer/internal/wiki"
+	"github.com/ihcsim/wikiracer/internal/wiki/graph"
 )
 
-const separator = "|"
-
-// MockWiki is an in-memory wiki
+// MockWiki is an in-memory wiki, which can simulate the pagination and the latency of the Wikipedia API.
 type MockWiki struct {
-	pages map[string]*wiki.Page
+	*graph.Wiki
+	batchSize int
+	latency   time.Duration
+}
+
+// Option can be used to configure the MockWiki.
+type Option func(*MockWiki)
+
+// WithBatchSize splits the links of the pages into batches of size links, like the pagination of the Wikipedia API.
+// The links aren't paginated if size is zero.
+func WithBatchSize(size int) Option {
+	return func(m *MockWiki) {
+		m.batchSize = size
+	}
+}
+
+// WithRedirects adds redirects to the mock wiki, keyed by the redirected title.
+// A redirected title is resolved to the page it redirects to.
+func WithRedirects(redirects map[string]string) Option {
+	return func(m *MockWiki) {
+		for from, to := range redirects {
+			m.AddRedirect(from, to)
+		}
+	}
+}
+
+// WithLatency delays every batch of pages by latency.
+func WithLatency(latency time.Duration) Option {
+	return func(m *MockWiki) {
+		m.latency = latency
+	}
 }
 
 // NewMockWiki returns a new instance of MockWiki
-func NewMockWiki() *MockWiki {
+func NewMockWiki(options ...Option) *MockWiki {
 	testData := map[string]*wiki.Page{
-		"1984 Summer Olympics": &wiki.Page{ID: 2000, Title: "1984 Summer Olympics", Namespace: 0, Links: []string{"7-Eleven", "Afghanistan"}},
-		"2010 Winter Olympics": &wiki.Page{ID: 2009, Title: "2010 Winter Olympics", Namespace: 0, Links: []string{"1984 Summer Olympics"}},
-		"7-Eleven":             &wiki.Page{ID: 2001, Title: "7-Eleven", Namespace: 0, Links: []string{"Big C", "Calgary", "Eurocash"}},
-		"Afghanistan":          &wiki.Page{ID: 2002, Title: "Afghanistan", Namespace: 0, Links: []string{}},
-		"Alexander the Great":  &wiki.Page{ID: 1000, Title: "Alexander the Great", Namespace: 0, Links: []string{"Apepi", "Greek language", "Diodotus I"}},
-		"Apepi":                &wiki.Page{ID: 1005, Title: "Apepi", Namespace: 0},
-		"Big C":                &wiki.Page{ID: 2003, Title: "Big C", Namespace: 0, Links: []string{"Vancouver"}},
-		"Calgary":              &wiki.Page{ID: 2004, Title: "Calgary", Namespace: 0},
-		"Eurocash":             &wiki.Page{ID: 2005, Title: "Eurocash", Namespace: 0, Links: []string{"Małpka Express", "Tea"}},
-		"Diodotus I":           &wiki.Page{ID: 1007, Title: "Diodotus I", Namespace: 0},
-		"Fruit anatomy":        &wiki.Page{ID: 1001, Title: "Fruit anatomy", Namespace: 0, Links: []string{"Segment"}},
-		"Greek language":       &wiki.Page{ID: 1002, Title: "Greek language", Namespace: 0, Links: []string{"Fruit anatomy"}},
-		"Małpka Express":       &wiki.Page{ID: 2006, Title: "Małpka Express", Namespace: 0},
-		"Michael Jordan":       &wiki.Page{ID: 1006, Title: "Michael Jordan", Namespace: 0},
-		"Mike Tyson":           &wiki.Page{ID: 1003, Title: "Mike Tyson", Namespace: 0, Links: []string{"Alexander the Great", "1984 Summer Olympics"}},
-		"Segment":              &wiki.Page{ID: 1004, Title: "Segment", Namespace: 0, Links: []string{"Vancouver"}},
-		"Tea":                  &wiki.Page{ID: 2007, Title: "Tea", Namespace: 0},
-		"Vancouver":            &wiki.Page{ID: 2008, Title: "Vancouver", Namespace: 0, Links: []string{"2010 Winter Olympics"}},
-	}
-	return &MockWiki{pages: testData}
-}
-
-// FindPages returns the page with the given title, if it exists.
-// Otherwise, it returns a 'page not found' error.
-func (m *MockWiki) FindPages(titles, nextBatch string) ([]*wiki.Page, error) {
+		"1984 Summer Olympics": &wiki.Page{ID: 2000, Title: "1984 Summer Olympics", Namespace: 0, Links: []string{"7-Eleven", "Afghanistan"}, Categories: []string{"1984 Summer Olympics", "Summer Olympic Games"}, Length: 161920},
+		"2010 Winter Olympics": &wiki.Page{ID: 2009, Title: "2010 Winter Olympics", Namespace: 0, Links: []string{"1984 Summer Olympics"}, Categories: []string{"2010 Winter Olympics", "Winter Olympic Games"}, Length: 112486},
+		"7-Eleven":             &wiki.Page{ID: 2001, Title: "7-Eleven", Namespace: 0, Links: []string{"Big C", "Calgary", "Eurocash"}, Categories: []string{"Convenience stores"}, Length: 48211},
+		"Afghanistan":          &wiki.Page{ID: 2002, Title: "Afghanistan", Namespace: 0, Links: []string{}, Categories: []string{"Landlocked countries"}, Length: 254033},
+		"Alexander the Great":  &wiki.Page{ID: 1000, Title: "Alexander the Great", Namespace: 0, Links: []string{"Apepi", "Greek language", "Diodotus I"}, Categories: []string{"356 BC births", "323 BC deaths"}, Length: 224510},
+		"Apepi":                &wiki.Page{ID: 1005, Title: "Apepi", Namespace: 0, Categories: []string{"Pharaohs of the Fifteenth Dynasty of Egypt"}, Length: 9805},
+		"Big C":                &wiki.Page{ID: 2003, Title: "Big C", Namespace: 0, Links: []string{"Vancouver"}, Categories: []string{"Convenience stores"}, Length: 6120},
+		"Calgary":              &wiki.Page{ID: 2004, Title: "Calgary", Namespace: 0, Categories: []string{"Cities in Alberta"}, Length: 146288},
+		"Eurocash":             &wiki.Page{ID: 2005, Title: "Eurocash", Namespace: 0, Links: []string{"Małpka Express", "Tea"}, Categories: []string{"Retail companies of Poland"}, Length: 3120},
+		"Diodotus I":           &wiki.Page{ID: 1007, Title: "Diodotus I", Namespace: 0, Categories: []string{"Greco-Bactrian kings"}, Length: 8912},
+		"Fruit anatomy":        &wiki.Page{ID: 1001, Title: "Fruit anatomy", Namespace: 0, Links: []string{"Segment"}, Categories: []string{"Fruit morphology"}, Length: 15823},
+		"Greek language":       &wiki.Page{ID: 1002, Title: "Greek language", Namespace: 0, Links: []string{"Fruit anatomy"}, Categories: []string{"Greek language", "Languages of Greece"}, Length: 98520},
+		"Małpka Express":       &wiki.Page{ID: 2006, Title: "Małpka Express", Namespace: 0, Categories: []string{"Convenience stores"}, Length: 2344},
+		"Michael Jordan":       &wiki.Page{ID: 1006, Title: "Michael Jordan", Namespace: 0, Categories: []string{"1963 births", "American men's basketball players"}, Length: 195338},
+		"Mike Tyson":           &wiki.Page{ID: 1003, Title: "Mike Tyson", Namespace: 0, Links: []string{"Alexander the Great", "1984 Summer Olympics"}, Categories: []string{"1966 births", "American male boxers"}, Length: 181410},
+		"Segment":              &wiki.Page{ID: 1004, Title: "Segment", Namespace: 0, Links: []string{"Vancouver"}, Categories: []string{"Disambiguation pages"}, Length: 1640, Disambiguation: true},
+		"Tea":                  &wiki.Page{ID: 2007, Title: "Tea", Namespace: 0, Categories: []string{"Tea"}, Length: 126944},
+		"Vancouver":            &wiki.Page{ID: 2008, Title: "Vancouver", Namespace: 0, Links: []string{"2010 Winter Olympics"}, Categories: []string{"Vancouver", "Port cities in Canada"}, Length: 204215},
+	}
 	pages := []*wiki.Page{}
-	for _, title := range strings.Split(titles, separator) {
-		page, exist := m.pages[title]
-		if !exist {
-			return nil, errors.PageNotFound{wiki.Page{Title: title}}
+	for _, page := range testData {
+		pages = append(pages, page)
+	}
+	return FromPages(pages, options...)
+}
+
+// FromPages returns a new instance of MockWiki with the given pages.
+func FromPages(pages []*wiki.Page, options ...Option) *MockWiki {
+	return fromGraph(graph.New(pages), options)
+}
+
+func fromGraph(g *graph.Wiki, options []Option) *MockWiki {
+	m := &MockWiki{Wiki: g}
+	for _, option := range options {
+		option(m)
+	}
+	return m
+}
+
+// FindPages returns the pages with the given titles, if they exist.
+// If some of the pages don't exist, the found pages are returned together with a 'pages not found' error.
+// If the mock wiki is configured with a batch size, the batches of links, starting from nextBatch, are merged before the pages are returned.
+func (m *MockWiki) FindPages(titles, nextBatch string) ([]*wiki.Page, error) {
+	pages, err := m.find(titles)
+	if m.batchSize <= 0 {
+		m.wait()
+		return pages, err
+	}
+
+	offset, parseErr := parseBatch(nextBatch)
+	if parseErr != nil {
+		return nil, parseErr
+	}
+
+	var results []*wiki.Page
+	for {
+		batch, next := m.batch(pages, offset)
+		m.wait()
+		results = merge(results, batch)
+
+		if next < 0 {
+			break
 		}
+		offset = next
+	}
 
-		pages = append(pages, page)
+	return results, err
+}
+
+// find returns the pages with the given titles, after resolving the redirects.
+func (m *MockWiki) find(titles string) ([]*wiki.Page, error) {
+	return m.Wiki.FindPages(titles, "")
+}
+
+// batch returns the pages with the batch of links which starts at offset, and the offset of the next batch.
+// Like the Wikipedia API, every batch includes all the pages. The next offset is -1 after the last batch.
+func (m *MockWiki) batch(pages []*wiki.Page, offset int) ([]*wiki.Page, int) {
+	var (
+		batch = []*wiki.Page{}
+		index int
+		end   = offset + m.batchSize
+	)
+	for _, page := range pages {
+		p := *page
+		p.Links = nil
+		for _, link := range page.Links {
+			if index >= offset && index < end {
+				p.Links = append(p.Links, link)
+			}
+			index++
+		}
+		batch = append(batch, &p)
 	}
 
-	return pages, nil
+	if end >= index {
+		return batch, -1
+	}
+	return batch, end
+}
+
+func (m *MockWiki) wait() {
+	if m.latency > 0 {
+		time.Sleep(m.latency)
+	}
+}
+
+// Stream returns a wiki.Streamer which sends the pages of the mock wiki in batches.
+// The mock wiki itself doesn't implement wiki.Streamer, so that the test wikis which embed it can override FindPages.
+func (m *MockWiki) Stream() *StreamingWiki {
+	return &StreamingWiki{m}
+}
+
+// StreamingWiki is a MockWiki which streams the batches of links.
+type StreamingWiki struct {
+	*MockWiki
+}
+
+// StreamPages sends the pages of the given titles to the returned channel, one batch at a time.
+// If the mock wiki isn't configured with a batch size, all the links are sent in a single batch.
+func (s *StreamingWiki) StreamPages(ctx context.Context, titles, nextBatch string) <-chan *wiki.Batch {
+	batches := make(chan *wiki.Batch)
+
+	go func() {
+		defer close(batches)
+
+		pages, err := s.find(titles)
+		if s.batchSize <= 0 {
+			s.wait()
+			select {
+			case batches <- &wiki.Batch{Pages: pages, Err: err}:
+			case <-ctx.Done():
+			}
+			return
+		}
+
+		offset, parseErr := parseBatch(nextBatch)
+		if parseErr != nil {
+			select {
+			case batches <- &wiki.Batch{Err: parseErr}:
+			case <-ctx.Done():
+			}
+			return
+		}
+
+		for {
+			pages, next := s.batch(pages, offset)
+			s.wait()
+
+			result := &wiki.Batch{Pages: pages, Err: err}
+			if next >= 0 {
+				result.Next = strconv.Itoa(next)
+			}
+
+			select {
+			case batches <- result:
+			case <-ctx.Done():
+				return
+			}
+
+			if next < 0 {
+				return
+			}
+			offset = next
+		}
+	}()
+
+	return batches
+}
+
+// parseBatch returns the offset of the link which nextBatch resumes from.
+func parseBatch(nextBatch string) (int, error) {
+	if nextBatch == "" {
+		return 0, nil
+	}
+
+	offset, err := strconv.Atoi(nextBatch)
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("Invalid nextBatch %q", nextBatch)
+	}
+	return offset, nil
+}
+
+// merge appends the links of the batch to the pages. The pages of every batch are in the same order.
+func merge(pages, batch []*wiki.Page) []*wiki.Page {
+	if pages == nil {
+		return batch
+	}
+
+	for i, page := range batch {
+		pages[i].Links = append(pages[i].Links, page.Links...)
+	}
+	return pages
 }
@@ -0,0 +1,107 @@
+package test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ihcsim/wikiracer/errors"
+	"github.com/ihcsim/wikiracer/internal/wiki"
+)
+
+func TestFindPages(t *testing.T) {
+	var testCases = []struct {
+		name      string
+		options   []Option
+		titles    string
+		nextBatch string
+		expected  []*wiki.Page
+		err       error
+	}{
+		{
+			name:     "Unpaginated",
+			titles:   "Mike Tyson|Segment",
+			expected: []*wiki.Page{&wiki.Page{ID: 1003, Title: "Mike Tyson", Links: []string{"Alexander the Great", "1984 Summer Olympics"}}, &wiki.Page{ID: 1004, Title: "Segment", Links: []string{"Vancouver"}}},
+		},
+		{
+			name:     "Paginated",
+			options:  []Option{WithBatchSize(1)},
+			titles:   "Mike Tyson|Segment",
+			expected: []*wiki.Page{&wiki.Page{ID: 1003, Title: "Mike Tyson", Links: []string{"Alexander the Great", "1984 Summer Olympics"}}, &wiki.Page{ID: 1004, Title: "Segment", Links: []string{"Vancouver"}}},
+		},
+		{
+			name:      "Next Batch",
+			options:   []Option{WithBatchSize(1)},
+			titles:    "Mike Tyson|Segment",
+			nextBatch: "1",
+			expected:  []*wiki.Page{&wiki.Page{ID: 1003, Title: "Mike Tyson", Links: []string{"1984 Summer Olympics"}}, &wiki.Page{ID: 1004, Title: "Segment", Links: []string{"Vancouver"}}},
+		},
+		{
+			name:     "Redirects",
+			options:  []Option{WithRedirects(map[string]string{"Iron Mike": "Mike Tyson"})},
+			titles:   "Iron Mike|Red link",
+			expected: []*wiki.Page{&wiki.Page{ID: 1003, Title: "Mike Tyson", Links: []string{"Alexander the Great", "1984 Summer Olympics"}}},
+			err:      errors.PagesNotFound{Titles: []string{"Red link"}},
+		},
+	}
+
+	for _, testCase := range testCases {
+		actual, err := NewMockWiki(testCase.options...).FindPages(testCase.titles, testCase.nextBatch)
+		if !reflect.DeepEqual(err, testCase.err) {
+			t.Errorf("Test case %q failed. Mismatch error.\nExpected: %v\nActual: %v", testCase.name, testCase.err, err)
+		}
+
+		// the metadata isn't compared.
+		for _, page := range actual {
+			page.Categories, page.Length, page.Disambiguation = nil, 0, false
+		}
+		if !reflect.DeepEqual(actual, testCase.expected) {
+			t.Errorf("Test case %q failed. Mismatch pages.\nExpected: %+v\nActual: %+v", testCase.name, testCase.expected, actual)
+		}
+	}
+}
+
+func TestStreamPages(t *testing.T) {
+	var (
+		latency = 10 * time.Millisecond
+		m       = NewMockWiki(WithBatchSize(2), WithLatency(latency))
+		start   = time.Now()
+		nexts   = []string{}
+		links   = []string{}
+	)
+
+	for batch := range m.Stream().StreamPages(context.Background(), "Alexander the Great|Mike Tyson", "") {
+		if batch.Err != nil {
+			t.Fatal(batch.Err)
+		}
+
+		nexts = append(nexts, batch.Next)
+		for _, page := range batch.Pages {
+			links = append(links, page.Links...)
+		}
+	}
+
+	expectedNexts := []string{"2", "4", ""}
+	if !reflect.DeepEqual(nexts, expectedNexts) {
+		t.Errorf("Mismatch batches.\nExpected: %v\nActual: %v", expectedNexts, nexts)
+	}
+
+	expectedLinks := []string{"Apepi", "Greek language", "Diodotus I", "Alexander the Great", "1984 Summer Olympics"}
+	if !reflect.DeepEqual(links, expectedLinks) {
+		t.Errorf("Mismatch links.\nExpected: %v\nActual: %v", expectedLinks, links)
+	}
+
+	if elapsed := time.Since(start); elapsed < 3*latency {
+		t.Errorf("Expected every batch to be delayed by %s. Elapsed %s", latency, elapsed)
+	}
+
+	// the stored pages aren't modified by the pagination.
+	pages, err := m.FindPages("Alexander the Great", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := len(pages[0].Links); actual != 3 {
+		t.Errorf("Mismatch links count. Expected 3. Actual %d", actual)
+	}
+}